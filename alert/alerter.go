@@ -0,0 +1,201 @@
+package alert
+
+import (
+	"sort"
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// Statuses of alert-events.
+const (
+	// StatusFiring is sent once when a group starts breaching a Rule.
+	StatusFiring = "firing"
+	// StatusResolved is sent once when a firing group stops breaching its Rule.
+	StatusResolved = "resolved"
+)
+
+// Event is an alert-event, sent when a group starts or stops breaching a Rule.
+type Event struct {
+	Rule   string `json:"rule"`
+	Status string `json:"status"`
+	Group  string `json:"group"`
+	// Key is the group's value, such as the SKU.
+	Key       string  `json:"key"`
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// Unit is the weight-unit of Value and Threshold for MetricWasteWeight.
+	Unit   string `json:"unit,omitempty"`
+	Window string `json:"window"`
+	// FiredAt is when the alert started firing, as a unix-timestamp.
+	FiredAt   int64 `json:"firedAt"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// ID identifies the alert the Event is for. The firing and resolved
+// Events of an alert have the same ID.
+func (e Event) ID() string {
+	return e.Rule + "/" + e.Key
+}
+
+// Alerter evaluates Rules, and remembers the firing alerts so each alert
+// only produces an Event when it starts firing and when it is resolved.
+// The firing alerts are stored in the alert-collection, so they are shared
+// by the service's replicas and kept over restarts. An Alerter is not safe
+// for concurrent use.
+type Alerter struct {
+	rules     []Rule
+	alertColl *mongo.Collection
+	// firing are the Events of the firing alerts, by their ID
+	firing map[string]Event
+}
+
+// NewAlerter creates an Alerter for the Rules, which must be valid. If the
+// alertColl is nil, the firing alerts are only kept in memory, which is
+// only correct for a single replica.
+func NewAlerter(rules []Rule, alertColl *mongo.Collection) *Alerter {
+	return &Alerter{
+		rules:     rules,
+		alertColl: alertColl,
+		firing:    map[string]Event{},
+	}
+}
+
+// Check evaluates every Rule against the waste-totals over its window,
+// and returns the Events for alerts that started firing or were resolved.
+// If the totals for a Rule cannot be read, its alerts are left unchanged,
+// and the error is returned after evaluating the remaining Rules.
+// With an alert-collection, the firing alerts are read from it first, and
+// only the Events this Alerter claimed in it are returned.
+func (a *Alerter) Check(itemWasteColl *mongo.Collection, now time.Time) ([]Event, error) {
+	if a.alertColl != nil {
+		firing, err := Firing(a.alertColl)
+		if err != nil {
+			err = errors.Wrap(err, "Error reading firing alerts")
+			return nil, err
+		}
+		a.firing = firing
+	}
+
+	// Rules having the same group and window share their totals
+	type totalsKey struct {
+		group  string
+		window time.Duration
+	}
+	cache := map[totalsKey][]report.WasteTotal{}
+
+	var checkErr error
+	events := []Event{}
+	for _, rule := range a.rules {
+		key := totalsKey{rule.Group, rule.window()}
+		totals, ok := cache[key]
+		if !ok {
+			var err error
+			totals, err = report.WasteTotals(
+				itemWasteColl, rule.Group, now.Add(-key.window).Unix(),
+			)
+			if err != nil {
+				if checkErr == nil {
+					checkErr = errors.Wrapf(err, "Error getting waste-totals for rule %s", rule.Name)
+				}
+				continue
+			}
+			cache[key] = totals
+		}
+		events = append(events, a.Evaluate(rule, totals, now)...)
+	}
+	if a.alertColl == nil {
+		return events, checkErr
+	}
+
+	// Other replicas may have sent the same Events
+	claimed := []Event{}
+	for _, e := range events {
+		var isClaimed bool
+		var err error
+		if e.Status == StatusFiring {
+			isClaimed, err = Fire(a.alertColl, e)
+		} else {
+			isClaimed, err = Resolve(a.alertColl, e)
+		}
+		if err != nil {
+			// The alert is evaluated again in the next Check
+			if checkErr == nil {
+				checkErr = err
+			}
+			continue
+		}
+		if isClaimed {
+			claimed = append(claimed, e)
+		}
+	}
+	return claimed, checkErr
+}
+
+// Evaluate compares the Rule against the totals of its groups. A firing
+// Event is returned for each group newly above the threshold, and a
+// resolved Event for each firing group that is no longer above it.
+// Groups with a blank key, such as WasteItems without a lot, are skipped.
+func (a *Alerter) Evaluate(rule Rule, totals []report.WasteTotal, now time.Time) []Event {
+	events := []Event{}
+	values := map[string]float64{}
+
+	for _, t := range totals {
+		if t.Key == "" {
+			continue
+		}
+		value := rule.value(t)
+		values[t.Key] = value
+		if value <= rule.Threshold {
+			continue
+		}
+
+		event := newEvent(rule, t.Key, value, now)
+		if _, isFiring := a.firing[event.ID()]; isFiring {
+			continue
+		}
+		event.Status = StatusFiring
+		event.FiredAt = event.Timestamp
+		a.firing[event.ID()] = event
+		events = append(events, event)
+	}
+
+	resolved := []Event{}
+	for id, fired := range a.firing {
+		if fired.Rule != rule.Name || values[fired.Key] > rule.Threshold {
+			continue
+		}
+		event := newEvent(rule, fired.Key, values[fired.Key], now)
+		event.Status = StatusResolved
+		event.FiredAt = fired.FiredAt
+		delete(a.firing, id)
+		resolved = append(resolved, event)
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].Key < resolved[j].Key
+	})
+	return append(events, resolved...)
+}
+
+func newEvent(rule Rule, key string, value float64, now time.Time) Event {
+	event := Event{
+		Rule:      rule.Name,
+		Group:     rule.Group,
+		Key:       key,
+		Metric:    rule.Metric,
+		Value:     value,
+		Threshold: rule.Threshold,
+		Window:    rule.Window,
+		Timestamp: now.Unix(),
+	}
+	if rule.Metric == MetricWasteWeight {
+		event.Unit = rule.Unit
+		if event.Unit == "" {
+			event.Unit = report.CanonicalUnit
+		}
+	}
+	return event
+}
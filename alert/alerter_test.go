@@ -0,0 +1,133 @@
+package alert
+
+import (
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/mongodb/mongo-go-driver/bson"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Alerter", func() {
+	ratioRule := Rule{
+		Name:      "sku-ratio",
+		Group:     "sku",
+		Metric:    MetricWasteRatio,
+		Threshold: 0.15,
+		Window:    "24h",
+	}
+	weightRule := Rule{
+		Name:      "lot-weight",
+		Group:     "lot",
+		Metric:    MetricWasteWeight,
+		Threshold: 100,
+		Window:    "24h",
+		Unit:      report.UnitPound,
+	}
+	now := time.Unix(1000, 0)
+
+	It("validates rules", func() {
+		Expect(ValidateRules([]Rule{ratioRule, weightRule})).To(Succeed())
+		Expect(ValidateRules([]Rule{ratioRule, ratioRule})).ToNot(Succeed())
+
+		invalid := ratioRule
+		invalid.Threshold = 15
+		Expect(invalid.Validate()).ToNot(Succeed())
+		invalid = weightRule
+		invalid.Window = "1d"
+		Expect(invalid.Validate()).ToNot(Succeed())
+		invalid = weightRule
+		invalid.Group = "name"
+		Expect(invalid.Validate()).ToNot(Succeed())
+	})
+
+	It("fires once per breaching group", func() {
+		alerter := NewAlerter([]Rule{ratioRule}, nil)
+		totals := []report.WasteTotal{
+			report.WasteTotal{Key: "sku1", WasteWeight: 20, TotalWeight: 100},
+			report.WasteTotal{Key: "sku2", WasteWeight: 10, TotalWeight: 100},
+			report.WasteTotal{WasteWeight: 90, TotalWeight: 100},
+		}
+
+		events := alerter.Evaluate(ratioRule, totals, now)
+		Expect(events).To(Equal([]Event{
+			Event{
+				Rule:      "sku-ratio",
+				Status:    StatusFiring,
+				Group:     "sku",
+				Key:       "sku1",
+				Metric:    MetricWasteRatio,
+				Value:     0.2,
+				Threshold: 0.15,
+				Window:    "24h",
+				FiredAt:   1000,
+				Timestamp: 1000,
+			},
+		}))
+
+		totals[0].WasteWeight = 30
+		Expect(alerter.Evaluate(ratioRule, totals, now.Add(time.Minute))).To(BeEmpty())
+	})
+
+	It("resolves alerts when the group recovers", func() {
+		alerter := NewAlerter([]Rule{ratioRule, weightRule}, nil)
+		totals := []report.WasteTotal{
+			report.WasteTotal{Key: "sku1", WasteWeight: 20, TotalWeight: 100},
+			report.WasteTotal{Key: "sku2", WasteWeight: 30, TotalWeight: 100},
+		}
+		Expect(alerter.Evaluate(ratioRule, totals, now)).To(HaveLen(2))
+		// Alerts of other rules are not resolved
+		Expect(alerter.Evaluate(weightRule, []report.WasteTotal{
+			report.WasteTotal{Key: "lot1", WasteWeight: 50},
+		}, now)).To(HaveLen(1))
+		Expect(alerter.Evaluate(weightRule, nil, now)).To(HaveLen(1))
+		Expect(alerter.Evaluate(weightRule, nil, now)).To(BeEmpty())
+
+		// sku1 recovers, and sku2 no longer has waste in the window
+		totals = []report.WasteTotal{
+			report.WasteTotal{Key: "sku1", WasteWeight: 10, TotalWeight: 100},
+		}
+		events := alerter.Evaluate(ratioRule, totals, now.Add(time.Hour))
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Key).To(Equal("sku1"))
+		Expect(events[0].Status).To(Equal(StatusResolved))
+		Expect(events[0].Value).To(Equal(0.1))
+		Expect(events[0].FiredAt).To(Equal(int64(1000)))
+		Expect(events[0].Timestamp).To(Equal(int64(4600)))
+		Expect(events[1].Key).To(Equal("sku2"))
+		Expect(events[1].Value).To(BeZero())
+
+		// A resolved alert can fire again
+		totals[0].WasteWeight = 50
+		events = alerter.Evaluate(ratioRule, totals, now.Add(2*time.Hour))
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Status).To(Equal(StatusFiring))
+	})
+
+	It("compares waste-weights in the rule's unit", func() {
+		alerter := NewAlerter([]Rule{weightRule}, nil)
+		events := alerter.Evaluate(weightRule, []report.WasteTotal{
+			report.WasteTotal{Key: "lot1", WasteWeight: 50},
+			report.WasteTotal{Key: "lot2", WasteWeight: 40},
+		}, now)
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Key).To(Equal("lot1"))
+		Expect(events[0].Value).To(BeNumerically("~", 110.23, 0.01))
+		Expect(events[0].Unit).To(Equal(report.UnitPound))
+	})
+
+	It("round-trips firing events through BSON", func() {
+		event := newEvent(weightRule, "lot1", 110.5, now)
+		event.Status = StatusFiring
+		event.FiredAt = event.Timestamp
+
+		in, err := bson.Marshal(event)
+		Expect(err).ToNot(HaveOccurred())
+		out := Event{}
+		err = bson.Unmarshal(in, &out)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal(event))
+		Expect(out.ID()).To(Equal("lot-weight/lot1"))
+	})
+})
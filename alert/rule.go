@@ -0,0 +1,115 @@
+// Package alert evaluates threshold-rules against the waste in
+// agg_itemwaste, such as "SKU waste-ratio above 15% over 24h", and
+// produces alert-events when a rule starts and stops being breached.
+package alert
+
+import (
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/pkg/errors"
+)
+
+// Metrics a Rule can be evaluated on.
+const (
+	// MetricWasteWeight is the summed waste-weight of a group.
+	MetricWasteWeight = "wasteWeight"
+	// MetricWasteRatio is the ratio of waste-weight to total-weight of a
+	// group, from 0 to 1.
+	MetricWasteRatio = "wasteRatio"
+)
+
+// Groups are the WasteItem fields a Rule can group the waste by.
+var Groups = []string{"sku", "lot", "storeID"}
+
+// Rule is an alert-rule, which is breached by each group having its metric
+// above the threshold over the rolling window.
+type Rule struct {
+	// Name identifies the rule in alert-events, and must be unique.
+	Name string `yaml:"name"`
+	// Group is the WasteItem field the waste is grouped by, one of Groups.
+	Group string `yaml:"group"`
+	// Metric is MetricWasteWeight or MetricWasteRatio.
+	Metric    string  `yaml:"metric"`
+	Threshold float64 `yaml:"threshold"`
+	// Window is the rolling window as a duration, such as "24h".
+	Window string `yaml:"window"`
+	// Unit is the weight-unit of the threshold for MetricWasteWeight.
+	// Defaults to the report.CanonicalUnit.
+	Unit string `yaml:"unit"`
+}
+
+// Validate checks the fields of the Rule.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("Rule-name is required")
+	}
+	validGroup := false
+	for _, g := range Groups {
+		validGroup = validGroup || r.Group == g
+	}
+	if !validGroup {
+		return errors.Errorf("Rule %s: Unknown group: %s", r.Name, r.Group)
+	}
+
+	switch r.Metric {
+	case MetricWasteWeight:
+		if !report.ValidUnit(r.Unit) {
+			return errors.Errorf("Rule %s: Unsupported weight-unit: %s", r.Name, r.Unit)
+		}
+	case MetricWasteRatio:
+		if r.Threshold >= 1 {
+			return errors.Errorf("Rule %s: Waste-ratio threshold must be below 1", r.Name)
+		}
+		if r.Unit != "" {
+			return errors.Errorf("Rule %s: Weight-unit is only allowed for %s", r.Name, MetricWasteWeight)
+		}
+	default:
+		return errors.Errorf("Rule %s: Unknown metric: %s", r.Name, r.Metric)
+	}
+	if r.Threshold <= 0 {
+		return errors.Errorf("Rule %s: Threshold must be positive", r.Name)
+	}
+
+	window, err := time.ParseDuration(r.Window)
+	if err != nil {
+		return errors.Wrapf(err, "Rule %s: Invalid window", r.Name)
+	}
+	if window <= 0 {
+		return errors.Errorf("Rule %s: Window must be positive", r.Name)
+	}
+	return nil
+}
+
+// ValidateRules validates each Rule, and checks that the rule-names are unique.
+func ValidateRules(rules []Rule) error {
+	names := map[string]bool{}
+	for _, r := range rules {
+		err := r.Validate()
+		if err != nil {
+			return err
+		}
+		if names[r.Name] {
+			return errors.Errorf("Duplicate rule-name: %s", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+// window returns the parsed Window. The Rule must be valid.
+func (r Rule) window() time.Duration {
+	window, _ := time.ParseDuration(r.Window)
+	return window
+}
+
+// value returns the Rule's metric for the WasteTotal, with waste-weights
+// in the Rule's unit.
+func (r Rule) value(total report.WasteTotal) float64 {
+	if r.Metric == MetricWasteRatio {
+		return total.WasteRatio()
+	}
+	// The unit is validated, so this can't fail
+	weight, _ := report.ConvertWeight(total.WasteWeight, report.CanonicalUnit, r.Unit)
+	return weight
+}
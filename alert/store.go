@@ -0,0 +1,102 @@
+package alert
+
+import (
+	"log"
+
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	mgo "github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/pkg/errors"
+)
+
+// duplicateKeyCode is the Mongo error-code for inserts violating
+// a unique index.
+const duplicateKeyCode = 11000
+
+// eventBSON is the stored firing Event.
+type eventBSON struct {
+	Rule      string  `bson:"rule"`
+	Status    string  `bson:"status"`
+	Group     string  `bson:"group"`
+	Key       string  `bson:"key"`
+	Metric    string  `bson:"metric"`
+	Value     float64 `bson:"value"`
+	Threshold float64 `bson:"threshold"`
+	Unit      string  `bson:"unit"`
+	Window    string  `bson:"window"`
+	FiredAt   int64   `bson:"firedAt"`
+	Timestamp int64   `bson:"timestamp"`
+}
+
+func (e Event) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(eventBSON(e))
+}
+
+func (e *Event) UnmarshalBSON(in []byte) error {
+	eb := &eventBSON{}
+	err := bson.Unmarshal(in, eb)
+	if err != nil {
+		err = errors.Wrap(err, "UnmarshalBSON Error")
+		return err
+	}
+	*e = Event(*eb)
+	return nil
+}
+
+// Firing returns the firing Events stored in the alert-collection,
+// by their ID.
+func Firing(alertColl *mongo.Collection) (map[string]Event, error) {
+	findResults, err := alertColl.Find(map[string]interface{}{})
+	if err != nil {
+		err = errors.Wrap(err, "Error finding firing alerts")
+		log.Println(err)
+		return nil, err
+	}
+
+	firing := map[string]Event{}
+	for _, v := range findResults {
+		e, assertOK := v.(*Event)
+		if !assertOK {
+			err = errors.New("Error asserting find-result to Event")
+			log.Println(err)
+			return nil, err
+		}
+		firing[e.ID()] = *e
+	}
+	return firing, nil
+}
+
+// Fire stores the firing Event, and returns false if its alert was already
+// stored as firing, such as by another replica. The unique index on the rule
+// and key makes this a claim, so only one replica sends each firing Event.
+func Fire(alertColl *mongo.Collection, e Event) (bool, error) {
+	_, err := alertColl.InsertOne(e)
+	if err == nil {
+		return true, nil
+	}
+	if writeErrs, ok := errors.Cause(err).(mgo.WriteErrors); ok {
+		for _, writeErr := range writeErrs {
+			if writeErr.Code == duplicateKeyCode {
+				return false, nil
+			}
+		}
+	}
+	err = errors.Wrapf(err, "Error storing firing alert %s", e.ID())
+	log.Println(err)
+	return false, err
+}
+
+// Resolve removes the firing alert of the resolved Event, and returns false
+// if it was not stored as firing, such as when another replica resolved it.
+func Resolve(alertColl *mongo.Collection, e Event) (bool, error) {
+	result, err := alertColl.DeleteMany(map[string]interface{}{
+		"rule": e.Rule,
+		"key":  e.Key,
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Error removing firing alert %s", e.ID())
+		log.Println(err)
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
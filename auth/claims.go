@@ -0,0 +1,81 @@
+// Package auth contains the caller's claims carried in query-events,
+// and the checks made against them.
+package auth
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// AllStores is the store-claim granting access to every store.
+const AllStores = "*"
+
+// Claims are the identity and permissions of the caller that requested an
+// event. These are carried in the event-data under "claims", and are set by
+// the service producing the event after authenticating the caller, such as:
+//  {"claims": {"sub": "<user>", "roles": ["staff"], "stores": ["store-1"]}, ...}
+// Claims are not signed, so they are only as trusted as their producer.
+type Claims struct {
+	Subject string   `json:"sub,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	Stores  []string `json:"stores,omitempty"`
+}
+
+// FromEventData returns the Claims in the event-data,
+// or nil if the event-data has no claims.
+func FromEventData(data []byte) (*Claims, error) {
+	ec := struct {
+		Claims *Claims `json:"claims"`
+	}{}
+	err := json.Unmarshal(data, &ec)
+	if err != nil {
+		err = errors.Wrap(err, "Error unmarshalling claims")
+		return nil, err
+	}
+	return ec.Claims, nil
+}
+
+// FromJSON returns the Claims in the JSON, such as carried in the headers
+// of HTTP-requests and the metadata of gRPC-calls.
+func FromJSON(data []byte) (*Claims, error) {
+	claims := &Claims{}
+	err := json.Unmarshal(data, claims)
+	if err != nil {
+		err = errors.Wrap(err, "Error unmarshalling claims")
+		return nil, err
+	}
+	return claims, nil
+}
+
+// AllowsAllStores checks if the claims grant access to every store.
+func (c *Claims) AllowsAllStores() bool {
+	for _, s := range c.Stores {
+		if s == AllStores {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsStores checks if the claims grant access to all the provided stores.
+// A nil stores means every store.
+func (c *Claims) AllowsStores(stores []string) bool {
+	if c.AllowsAllStores() {
+		return true
+	}
+	if stores == nil {
+		return false
+	}
+
+	allowed := map[string]bool{}
+	for _, s := range c.Stores {
+		allowed[s] = true
+	}
+	for _, s := range stores {
+		if !allowed[s] {
+			return false
+		}
+	}
+	return true
+}
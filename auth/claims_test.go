@@ -0,0 +1,40 @@
+package auth
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Claims", func() {
+	It("reads claims from event-data", func() {
+		claims, err := FromEventData(
+			[]byte(`{"claims":{"sub":"user-1","stores":["store-1"]},"timestamp":{"$gt":1}}`),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(claims).To(Equal(&Claims{
+			Subject: "user-1",
+			Stores:  []string{"store-1"},
+		}))
+	})
+
+	It("returns nil if event-data has no claims", func() {
+		claims, err := FromEventData([]byte(`{"timestamp":{"$gt":1}}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(claims).To(BeNil())
+	})
+
+	It("allows only the claimed stores", func() {
+		claims := &Claims{Stores: []string{"store-1", "store-2"}}
+		Expect(claims.AllowsAllStores()).To(BeFalse())
+		Expect(claims.AllowsStores([]string{"store-1"})).To(BeTrue())
+		Expect(claims.AllowsStores([]string{"store-1", "store-3"})).To(BeFalse())
+		Expect(claims.AllowsStores(nil)).To(BeFalse())
+	})
+
+	It("allows every store with the AllStores claim", func() {
+		claims := &Claims{Stores: []string{AllStores}}
+		Expect(claims.AllowsAllStores()).To(BeTrue())
+		Expect(claims.AllowsStores(nil)).To(BeTrue())
+		Expect(claims.AllowsStores([]string{"store-3"})).To(BeTrue())
+	})
+})
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Permission is an operation a role can be granted.
+type Permission string
+
+const (
+	// PermissionQuery allows generating new reports.
+	PermissionQuery Permission = "query"
+	// PermissionRender allows rendering stored reports.
+	PermissionRender Permission = "render"
+	// PermissionAllStores allows using the AllStores store-claim. Without it,
+	// the caller is limited to the stores explicitly listed in its claims.
+	PermissionAllStores Permission = "allStores"
+	// PermissionCost allows seeing monetary cost fields in reports.
+	PermissionCost Permission = "cost"
+	// PermissionSchedule allows managing the scheduled reports.
+	PermissionSchedule Permission = "schedule"
+)
+
+// Permissions lists all known Permissions.
+var Permissions = []Permission{
+	PermissionQuery,
+	PermissionRender,
+	PermissionAllStores,
+	PermissionCost,
+	PermissionSchedule,
+}
+
+// RoleAdmin is the role with every permission in the DefaultPolicy. It is also
+// given to events without claims when claims are not required.
+const RoleAdmin = "admin"
+
+// Policy maps roles to the permissions they grant.
+type Policy map[string][]Permission
+
+// DefaultPolicy returns the Policy used if none is configured:
+//  * "admin" and "finance" can access every store, and see costs.
+//  * "manager" and "staff" can access their claimed stores, and "manager"
+//    can also schedule reports for them.
+func DefaultPolicy() Policy {
+	return Policy{
+		RoleAdmin: Permissions,
+		"finance": Permissions,
+		"manager": []Permission{PermissionQuery, PermissionRender, PermissionSchedule},
+		"staff":   []Permission{PermissionQuery, PermissionRender},
+	}
+}
+
+// PolicyFromConfig creates a Policy from role-names mapped to
+// permission-names. An error is returned for unknown permissions.
+func PolicyFromConfig(roles map[string][]string) (Policy, error) {
+	known := map[Permission]bool{}
+	for _, p := range Permissions {
+		known[p] = true
+	}
+
+	policy := Policy{}
+	for role, perms := range roles {
+		for _, perm := range perms {
+			p := Permission(perm)
+			if !known[p] {
+				return nil, errors.Errorf("unknown permission %q for role %q", perm, role)
+			}
+			policy[role] = append(policy[role], p)
+		}
+	}
+	return policy, nil
+}
+
+// Decision is the result of authorizing the caller for a Permission.
+// It contains everything needed to audit the decision.
+type Decision struct {
+	Allowed    bool         `json:"allowed"`
+	Reason     string       `json:"reason,omitempty"`
+	Subject    string       `json:"sub"`
+	Roles      []string     `json:"roles"`
+	Stores     []string     `json:"stores"`
+	Permission Permission   `json:"permission"`
+	Granted    []Permission `json:"granted"`
+}
+
+// Has checks if the caller's roles grant the Permission.
+func (d *Decision) Has(perm Permission) bool {
+	for _, p := range d.Granted {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Granted returns the Permissions granted by any of the roles, sorted by name.
+func (p Policy) Granted(roles []string) []Permission {
+	set := map[Permission]bool{}
+	for _, role := range roles {
+		for _, perm := range p[role] {
+			set[perm] = true
+		}
+	}
+
+	granted := make([]Permission, 0, len(set))
+	for perm := range set {
+		granted = append(granted, perm)
+	}
+	sort.Slice(granted, func(i, j int) bool {
+		return granted[i] < granted[j]
+	})
+	return granted
+}
+
+// Authorize checks if the claims' roles grant the Permission. Claims with the
+// AllStores store-claim also need the PermissionAllStores.
+func (p Policy) Authorize(claims *Claims, perm Permission) *Decision {
+	d := &Decision{
+		Subject:    claims.Subject,
+		Roles:      claims.Roles,
+		Stores:     claims.Stores,
+		Permission: perm,
+		Granted:    p.Granted(claims.Roles),
+	}
+
+	switch {
+	case len(claims.Roles) == 0:
+		d.Reason = "claims have no roles"
+	case !d.Has(perm):
+		d.Reason = fmt.Sprintf("roles do not grant %q", perm)
+	case claims.AllowsAllStores() && !d.Has(PermissionAllStores):
+		d.Reason = fmt.Sprintf("roles do not grant %q", PermissionAllStores)
+	default:
+		d.Allowed = true
+	}
+	return d
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Policy", func() {
+	policy := DefaultPolicy()
+
+	It("allows roles granting the permission", func() {
+		claims := &Claims{
+			Subject: "user-1",
+			Roles:   []string{"staff"},
+			Stores:  []string{"store-1"},
+		}
+		d := policy.Authorize(claims, PermissionQuery)
+		Expect(d.Allowed).To(BeTrue())
+		Expect(d.Subject).To(Equal("user-1"))
+		Expect(d.Has(PermissionCost)).To(BeFalse())
+	})
+
+	It("denies claims without roles", func() {
+		d := policy.Authorize(&Claims{Stores: []string{"store-1"}}, PermissionQuery)
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Reason).To(Equal("claims have no roles"))
+	})
+
+	It("denies roles not granting the permission", func() {
+		claims := &Claims{
+			Roles:  []string{"viewer"},
+			Stores: []string{"store-1"},
+		}
+		d := policy.Authorize(claims, PermissionRender)
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Granted).To(BeEmpty())
+	})
+
+	It("denies the AllStores claim without PermissionAllStores", func() {
+		claims := &Claims{
+			Roles:  []string{"staff"},
+			Stores: []string{AllStores},
+		}
+		d := policy.Authorize(claims, PermissionQuery)
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Reason).To(ContainSubstring(string(PermissionAllStores)))
+
+		claims.Roles = append(claims.Roles, "finance")
+		d = policy.Authorize(claims, PermissionQuery)
+		Expect(d.Allowed).To(BeTrue())
+		Expect(d.Has(PermissionCost)).To(BeTrue())
+	})
+
+	It("creates policy from config", func() {
+		p, err := PolicyFromConfig(map[string][]string{
+			"auditor": []string{"render", "cost"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p).To(Equal(Policy{
+			"auditor": []Permission{PermissionRender, PermissionCost},
+		}))
+
+		_, err = PolicyFromConfig(map[string][]string{
+			"auditor": []string{"delete"},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,114 @@
+// Command report-export writes a stored WasteReport to a file.
+// Supported formats are csv, xlsx, html and pdf.
+//
+// Usage:
+//  report-export -id <reportID> [-format csv] [-out report.csv] [-env ./.env] [-config config.yaml]
+//
+// Mongo connection-params are read from the same config-sources as the service.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/TerrexTech/agg-itemwaste-report/config"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
+)
+
+// formatPDF is only available here, since PDFs are generated offline
+// and not in response to query-events.
+const formatPDF = "pdf"
+
+func loadReportCollection(cfg config.Mongo) (*mongo.Collection, error) {
+	client, err := mongo.NewClient(cfg.ClientConfig())
+	if err != nil {
+		err = errors.Wrap(err, "Error creating MongoClient")
+		return nil, err
+	}
+
+	conn := &mongo.ConnectionConfig{
+		Client:  client,
+		Timeout: uint32(cfg.ResourceTimeoutMS),
+	}
+	return mongo.EnsureCollection(&mongo.Collection{
+		Connection:   conn,
+		Database:     cfg.Database,
+		Name:         cfg.ReportCollection,
+		SchemaStruct: &report.WasteReport{},
+	})
+}
+
+func main() {
+	reportIDStr := flag.String("id", "", "reportID of the stored WasteReport")
+	format := flag.String("format", report.FormatCSV, "csv, xlsx, html or pdf")
+	outPath := flag.String("out", "", "file to write, defaults to <reportID>.<format>")
+	envPath := flag.String("env", "./.env", "env-file to read Mongo config from")
+	configFile := flag.String("config", "", "optional YAML config-file, env-vars override its values")
+	flag.Parse()
+
+	if *reportIDStr == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	reportID, err := uuuid.FromString(*reportIDStr)
+	if err != nil {
+		err = errors.Wrap(err, "Error parsing reportID")
+		log.Fatalln(err)
+	}
+	if *format != formatPDF && !report.ValidFormat(*format) {
+		log.Fatalf("Unsupported format: %s", *format)
+	}
+	if *outPath == "" {
+		*outPath = reportID.String() + "." + *format
+	}
+
+	cfg, err := config.Load(*configFile, *envPath)
+	if err != nil {
+		err = errors.Wrap(err, "Error loading config")
+		log.Fatalln(err)
+	}
+	err = cfg.Mongo.Validate()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	reportColl, err := loadReportCollection(cfg.Mongo)
+	if err != nil {
+		err = errors.Wrap(err, "Error loading report-collection")
+		log.Fatalln(err)
+	}
+
+	wasteReport, err := report.FindReport(reportID, reportColl)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var document []byte
+	if *format == formatPDF {
+		buf := &bytes.Buffer{}
+		err = report.WritePDF(buf, wasteReport)
+		document = buf.Bytes()
+	} else {
+		document, err = report.MarshalReport(wasteReport, *format)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "Error rendering report")
+		log.Fatalln(err)
+	}
+
+	err = ioutil.WriteFile(*outPath, document, 0644)
+	if err != nil {
+		err = errors.Wrap(err, "Error writing file")
+		log.Fatalln(err)
+	}
+	log.Printf(
+		"Wrote report %s with %d rows to %s",
+		reportID.String(), len(wasteReport.ReportResult), *outPath,
+	)
+}
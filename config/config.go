@@ -0,0 +1,278 @@
+// Package config contains the service's configuration.
+// The Config is loaded from defaults, an optional YAML-file and env-vars
+// (including those from an env-file), with later sources overriding
+// earlier ones.
+package config
+
+import (
+	"io/ioutil"
+	"reflect"
+
+	"github.com/TerrexTech/agg-itemwaste-report/alert"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// redactedValue replaces the values of secret fields in Redacted.
+const redactedValue = "<redacted>"
+
+// Config is the configuration for the service.
+// The env-tag of each field is the env-var the field is read from.
+// Fields with the tag `secret:"true"` are hidden by Redacted.
+type Config struct {
+	ServiceName string      `yaml:"serviceName" env:"SERVICE_NAME"`
+	Kafka       Kafka       `yaml:"kafka"`
+	Mongo       Mongo       `yaml:"mongo"`
+	Servers     Servers     `yaml:"servers"`
+	Health      Health      `yaml:"health"`
+	WasteGauges WasteGauges `yaml:"wasteGauges"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Scoping     Scoping     `yaml:"scoping"`
+	Pricing     Pricing     `yaml:"pricing"`
+	Alerts      Alerts      `yaml:"alerts"`
+	Anomalies   Anomalies   `yaml:"anomalies"`
+	Schedules   Schedules   `yaml:"schedules"`
+}
+
+// Kafka is the configuration for Kafka consumers and producers.
+type Kafka struct {
+	Brokers []string `yaml:"brokers" env:"KAFKA_BROKERS"`
+
+	LogProducerTopic string `yaml:"logProducerTopic" env:"KAFKA_LOG_PRODUCER_TOPIC"`
+
+	ConsumerEventGroup      string `yaml:"consumerEventGroup" env:"KAFKA_CONSUMER_EVENT_GROUP"`
+	ConsumerEventQueryGroup string `yaml:"consumerEventQueryGroup" env:"KAFKA_CONSUMER_EVENT_QUERY_GROUP"`
+
+	ConsumerEventTopic      string `yaml:"consumerEventTopic" env:"KAFKA_CONSUMER_EVENT_TOPIC"`
+	ConsumerEventQueryTopic string `yaml:"consumerEventQueryTopic" env:"KAFKA_CONSUMER_EVENT_QUERY_TOPIC"`
+	ProducerEventQueryTopic string `yaml:"producerEventQueryTopic" env:"KAFKA_PRODUCER_EVENT_QUERY_TOPIC"`
+	ProducerResponseTopic   string `yaml:"producerResponseTopic" env:"KAFKA_PRODUCER_RESPONSE_TOPIC"`
+}
+
+// Mongo is the configuration for MongoDB.
+type Mongo struct {
+	Hosts    []string `yaml:"hosts" env:"MONGO_HOSTS"`
+	Username string   `yaml:"username" env:"MONGO_USERNAME"`
+	Password string   `yaml:"password" env:"MONGO_PASSWORD" secret:"true"`
+
+	Database         string `yaml:"database" env:"MONGO_DATABASE"`
+	AggCollection    string `yaml:"aggCollection" env:"MONGO_AGG_COLLECTION"`
+	MetaCollection   string `yaml:"metaCollection" env:"MONGO_META_COLLECTION"`
+	ReportCollection string `yaml:"reportCollection" env:"MONGO_REPORT_COLLECTION"`
+	// PriceCollection is the optional price-catalogue of unit-costs per SKU.
+	PriceCollection string `yaml:"priceCollection" env:"MONGO_PRICE_COLLECTION"`
+	// CatalogCollection is the optional product-catalogue of the category
+	// hierarchy per SKU, for rolling-up reports.
+	CatalogCollection string `yaml:"catalogCollection" env:"MONGO_CATALOG_COLLECTION"`
+	// LotCollection is the optional lot-metadata, having the supplier and
+	// receipt per lot, for lot-traceability.
+	LotCollection string `yaml:"lotCollection" env:"MONGO_LOT_COLLECTION"`
+	// ScheduleCollection is the optional collection of report-schedules.
+	// The scheduled reports are disabled if this is blank.
+	ScheduleCollection string `yaml:"scheduleCollection" env:"MONGO_SCHEDULE_COLLECTION"`
+	// DefinitionCollection is the optional collection of saved
+	// report-definitions. Saved reports are disabled if this is blank.
+	DefinitionCollection string `yaml:"definitionCollection" env:"MONGO_DEFINITION_COLLECTION"`
+	// AlertCollection is the collection of the firing threshold-alerts,
+	// shared by the replicas. It is required if there are alert-rules.
+	AlertCollection string `yaml:"alertCollection" env:"MONGO_ALERT_COLLECTION"`
+	// AnomalyCollection is the collection of the produced anomaly-events,
+	// shared by the replicas. It is required if anomaly-events are enabled.
+	AnomalyCollection string `yaml:"anomalyCollection" env:"MONGO_ANOMALY_COLLECTION"`
+
+	// AuthSource is the database to authenticate against, if not the "admin" database.
+	AuthSource string `yaml:"authSource" env:"MONGO_AUTH_SOURCE"`
+	ReplicaSet string `yaml:"replicaSet" env:"MONGO_REPLICA_SET"`
+
+	TLS                bool   `yaml:"tls" env:"MONGO_TLS"`
+	TLSCAFile          string `yaml:"tlsCAFile" env:"MONGO_TLS_CA_FILE"`
+	TLSCertKeyFile     string `yaml:"tlsCertKeyFile" env:"MONGO_TLS_CERT_KEY_FILE"`
+	TLSCertKeyPassword string `yaml:"tlsCertKeyPassword" env:"MONGO_TLS_CERT_KEY_PASSWORD" secret:"true"`
+	// TLSInsecure disables verifying the server's certificate and hostname.
+	TLSInsecure bool `yaml:"tlsInsecure" env:"MONGO_TLS_INSECURE"`
+
+	ConnectionTimeoutMS int `yaml:"connectionTimeoutMS" env:"MONGO_CONNECTION_TIMEOUT_MS"`
+	ResourceTimeoutMS   int `yaml:"resourceTimeoutMS" env:"MONGO_RESOURCE_TIMEOUT_MS"`
+}
+
+// Servers is the configuration for the optional HTTP, gRPC and admin servers.
+// A server is not started if its listen-address is blank.
+type Servers struct {
+	HTTPListenAddr  string `yaml:"httpListenAddr" env:"HTTP_LISTEN_ADDR"`
+	GRPCListenAddr  string `yaml:"grpcListenAddr" env:"GRPC_LISTEN_ADDR"`
+	AdminListenAddr string `yaml:"adminListenAddr" env:"ADMIN_LISTEN_ADDR"`
+}
+
+// Health is the configuration for the admin-server's health-probes.
+type Health struct {
+	LivenessTimeoutSec int `yaml:"livenessTimeoutSec" env:"HEALTH_LIVENESS_TIMEOUT_SEC"`
+}
+
+// WasteGauges is the configuration for the waste-level gauges.
+// An interval of 0 disables the gauges.
+type WasteGauges struct {
+	IntervalSec int `yaml:"intervalSec" env:"WASTE_GAUGES_INTERVAL_SEC"`
+	MaxSeries   int `yaml:"maxSeries" env:"WASTE_GAUGES_MAX_SERIES"`
+}
+
+// Tracing is the configuration for exporting traces.
+// Tracing is disabled if Exporter is blank.
+type Tracing struct {
+	Exporter string `yaml:"exporter" env:"TRACE_EXPORTER"`
+	FilePath string `yaml:"filePath" env:"TRACE_FILE_PATH"`
+}
+
+// Scoping is the configuration for authorizing query-events, and HTTP and
+// gRPC requests, using their claims, and limiting them to the allowed stores.
+type Scoping struct {
+	// RequireClaims denies events and requests without claims. Otherwise such
+	// events can access every store, which is only meant for migrating callers.
+	//
+	// The claims are unsigned JSON and are trusted as-is, so any producer to
+	// the event-topic, or client of the HTTP and gRPC APIs, can claim any role,
+	// such as admin or finance. Only authenticating services, which set the
+	// claims of their callers, must be able to reach these.
+	RequireClaims bool `yaml:"requireClaims" env:"SCOPE_REQUIRE_CLAIMS"`
+	// Policy maps roles to their permissions, and can only be set in the
+	// YAML-file. The auth.DefaultPolicy is used if this is empty.
+	Policy map[string][]string `yaml:"policy"`
+}
+
+// Pricing is the configuration for the monetary cost of waste.
+type Pricing struct {
+	// Currency is the ISO 4217 code of unit-costs, such as "USD".
+	Currency string `yaml:"currency" env:"PRICE_CURRENCY"`
+}
+
+// Alerts is the configuration for the threshold-alerts on waste.
+// Alerts are disabled if there are no rules.
+type Alerts struct {
+	// Topic is the Kafka-topic alert-events are produced on.
+	Topic       string `yaml:"topic" env:"KAFKA_PRODUCER_ALERT_TOPIC"`
+	IntervalSec int    `yaml:"intervalSec" env:"ALERTS_INTERVAL_SEC"`
+	// Rules can only be set in the YAML-file.
+	Rules []alert.Rule `yaml:"rules"`
+}
+
+// Anomalies is the configuration for producing the waste-anomalies of each
+// day as events. An interval of 0 disables the events, while the anomalies
+// report is always available.
+type Anomalies struct {
+	// Topic is the Kafka-topic anomaly-events are produced on.
+	Topic       string `yaml:"topic" env:"KAFKA_PRODUCER_ANOMALY_TOPIC"`
+	IntervalSec int    `yaml:"intervalSec" env:"ANOMALIES_INTERVAL_SEC"`
+	// BaselineDays and Threshold are as in report.AnomalyParams.
+	BaselineDays int     `yaml:"baselineDays" env:"ANOMALIES_BASELINE_DAYS"`
+	Threshold    float64 `yaml:"threshold" env:"ANOMALIES_THRESHOLD"`
+}
+
+// Schedules is the configuration for running the scheduled reports, which
+// are enabled by setting the Mongo ScheduleCollection.
+type Schedules struct {
+	// Topic is the Kafka-topic the scheduled reports are distributed on.
+	Topic string `yaml:"topic" env:"KAFKA_PRODUCER_SCHEDULE_TOPIC"`
+	// IntervalSec is how often due schedules are checked for.
+	IntervalSec int `yaml:"intervalSec" env:"SCHEDULES_INTERVAL_SEC"`
+}
+
+// Default returns the Config with default values set.
+func Default() *Config {
+	return &Config{
+		Mongo: Mongo{
+			ConnectionTimeoutMS: 3000,
+			ResourceTimeoutMS:   5000,
+		},
+		Health: Health{
+			LivenessTimeoutSec: 30,
+		},
+		WasteGauges: WasteGauges{
+			IntervalSec: 300,
+			MaxSeries:   50,
+		},
+		Tracing: Tracing{
+			FilePath: "./traces.json",
+		},
+		Scoping: Scoping{
+			RequireClaims: true,
+		},
+		Pricing: Pricing{
+			Currency: "USD",
+		},
+		Alerts: Alerts{
+			IntervalSec: 60,
+		},
+		Anomalies: Anomalies{
+			BaselineDays: report.DefaultBaselineDays,
+			Threshold:    report.DefaultAnomalyThreshold,
+		},
+		Schedules: Schedules{
+			IntervalSec: 60,
+		},
+	}
+}
+
+// Load loads the Config. Values are read from, in increasing precedence:
+// defaults, the YAML-file at filePath (skipped if blank), and env-vars.
+// The env-vars in envFile are loaded first (skipped if the file does not
+// exist), without overriding env-vars already set.
+// The loaded Config is not validated.
+func Load(filePath string, envFile string) (*Config, error) {
+	cfg := Default()
+
+	if filePath != "" {
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			err = errors.Wrap(err, "Error reading config-file")
+			return nil, err
+		}
+		err = yaml.UnmarshalStrict(data, cfg)
+		if err != nil {
+			err = errors.Wrap(err, "Error parsing config-file")
+			return nil, err
+		}
+	}
+
+	if envFile != "" {
+		// A missing env-file is fine, since env-vars might be set directly
+		_ = godotenv.Load(envFile)
+	}
+	err := loadEnv(reflect.ValueOf(cfg).Elem())
+	if err != nil {
+		err = errors.Wrap(err, "Error reading env-vars")
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Redacted returns a copy of the Config with secret values hidden.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redact(reflect.ValueOf(&redacted).Elem())
+	return &redacted
+}
+
+// Dump returns the redacted Config as YAML, such as for logging.
+func (c *Config) Dump() (string, error) {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling config")
+		return "", err
+	}
+	return string(data), nil
+}
+
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			redact(field)
+			continue
+		}
+		if t.Field(i).Tag.Get("secret") == "true" && field.String() != "" {
+			field.SetString(redactedValue)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var testEnv = map[string]string{
+		"SERVICE_NAME":                     "test-service",
+		"KAFKA_BROKERS":                    "kafka1:9092, kafka2:9092",
+		"KAFKA_LOG_PRODUCER_TOPIC":         "log.sink",
+		"KAFKA_CONSUMER_EVENT_GROUP":       "event-group",
+		"KAFKA_CONSUMER_EVENT_QUERY_GROUP": "event-query-group",
+		"KAFKA_CONSUMER_EVENT_TOPIC":       "event-topic",
+		"KAFKA_CONSUMER_EVENT_QUERY_TOPIC": "event-query-topic",
+		"KAFKA_PRODUCER_EVENT_QUERY_TOPIC": "query-request",
+		"KAFKA_PRODUCER_RESPONSE_TOPIC":    "response",
+		"MONGO_HOSTS":                      "mongo:27017",
+		"MONGO_USERNAME":                   "root",
+		"MONGO_PASSWORD":                   "secret-password",
+		"MONGO_DATABASE":                   "rns_projections",
+		"MONGO_AGG_COLLECTION":             "agg_itemwaste",
+		"MONGO_META_COLLECTION":            "aggregate_meta",
+		"MONGO_REPORT_COLLECTION":          "agg_report_itemwaste",
+		"MONGO_RESOURCE_TIMEOUT_MS":        "7000",
+	}
+
+	BeforeEach(func() {
+		os.Clearenv()
+		for k, v := range testEnv {
+			os.Setenv(k, v)
+		}
+	})
+
+	It("loads env-vars over defaults", func() {
+		cfg, err := Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Validate()).To(Succeed())
+
+		Expect(cfg.Kafka.Brokers).To(Equal([]string{"kafka1:9092", "kafka2:9092"}))
+		Expect(cfg.Mongo.ReportCollection).To(Equal("agg_report_itemwaste"))
+		Expect(cfg.Mongo.ConnectionTimeoutMS).To(Equal(3000))
+		Expect(cfg.Mongo.ResourceTimeoutMS).To(Equal(7000))
+		Expect(cfg.WasteGauges.MaxSeries).To(Equal(50))
+		Expect(cfg.Pricing.Currency).To(Equal("USD"))
+	})
+
+	It("loads the config-file, with env-vars taking precedence", func() {
+		file, err := ioutil.TempFile("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(`
+mongo:
+  database: file-database
+  connectionTimeoutMS: 1000
+tracing:
+  exporter: stdout
+`)
+		Expect(err).ToNot(HaveOccurred())
+		file.Close()
+
+		os.Setenv("TRACE_EXPORTER", "")
+		cfg, err := Load(file.Name(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(cfg.Mongo.Database).To(Equal("rns_projections"))
+		Expect(cfg.Mongo.ConnectionTimeoutMS).To(Equal(1000))
+		// Blank env-vars don't override the config-file
+		Expect(cfg.Tracing.Exporter).To(Equal("stdout"))
+	})
+
+	It("returns error on unknown config-file fields", func() {
+		file, err := ioutil.TempFile("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(file.Name())
+		_, err = file.WriteString("mongo:\n  databse: typo\n")
+		Expect(err).ToNot(HaveOccurred())
+		file.Close()
+
+		_, err = Load(file.Name(), "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns error on invalid integer env-vars", func() {
+		os.Setenv("MONGO_CONNECTION_TIMEOUT_MS", "3s")
+		_, err := Load("", "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("loads number env-vars", func() {
+		os.Setenv("ANOMALIES_THRESHOLD", "2.5")
+		cfg, err := Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Anomalies.Threshold).To(Equal(2.5))
+		Expect(cfg.Anomalies.BaselineDays).To(Equal(28))
+
+		os.Setenv("ANOMALIES_THRESHOLD", "high")
+		_, err = Load("", "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reports every validation problem", func() {
+		os.Unsetenv("MONGO_REPORT_COLLECTION")
+		os.Unsetenv("KAFKA_BROKERS")
+		os.Setenv("ADMIN_LISTEN_ADDR", "9100")
+		os.Setenv("PRICE_CURRENCY", "usd")
+
+		cfg, err := Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = cfg.Validate()
+		Expect(err).To(HaveOccurred())
+		problems, ok := err.(ValidationError)
+		Expect(ok).To(BeTrue())
+		Expect(problems).To(HaveLen(4))
+		Expect(err.Error()).To(ContainSubstring("MONGO_REPORT_COLLECTION"))
+		Expect(err.Error()).To(ContainSubstring("KAFKA_BROKERS"))
+		Expect(err.Error()).To(ContainSubstring("ADMIN_LISTEN_ADDR"))
+		Expect(err.Error()).To(ContainSubstring("PRICE_CURRENCY"))
+	})
+
+	It("validates the permissions in the scoping-policy", func() {
+		file, err := ioutil.TempFile("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(`
+scoping:
+  policy:
+    staff: [query, delete]
+`)
+		Expect(err).ToNot(HaveOccurred())
+		file.Close()
+
+		cfg, err := Load(file.Name(), "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Scoping.Policy).To(HaveKey("staff"))
+
+		err = cfg.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("delete"))
+	})
+
+	It("validates the alert-rules", func() {
+		file, err := ioutil.TempFile("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(`
+alerts:
+  rules:
+    - name: sku-ratio
+      group: sku
+      metric: wasteRatio
+      threshold: 0.15
+      window: 24h
+    - name: lot-weight
+      group: lot
+      metric: wasteWeight
+      threshold: 50
+      window: 1d
+`)
+		Expect(err).ToNot(HaveOccurred())
+		file.Close()
+
+		cfg, err := Load(file.Name(), "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Alerts.Rules).To(HaveLen(2))
+		Expect(cfg.Alerts.IntervalSec).To(Equal(60))
+
+		err = cfg.Validate()
+		Expect(err).To(HaveOccurred())
+		problems := err.(ValidationError)
+		Expect(problems).To(HaveLen(3))
+		Expect(err.Error()).To(ContainSubstring("KAFKA_PRODUCER_ALERT_TOPIC"))
+		Expect(err.Error()).To(ContainSubstring("MONGO_ALERT_COLLECTION"))
+		Expect(err.Error()).To(ContainSubstring("lot-weight"))
+	})
+
+	It("requires the schedule-topic if schedules are enabled", func() {
+		cfg, err := Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Schedules.IntervalSec).To(Equal(60))
+
+		os.Setenv("MONGO_SCHEDULE_COLLECTION", "agg_report_schedules")
+		cfg, err = Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+		err = cfg.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("KAFKA_PRODUCER_SCHEDULE_TOPIC"))
+
+		os.Setenv("KAFKA_PRODUCER_SCHEDULE_TOPIC", "report.schedule")
+		cfg, err = Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Validate()).To(Succeed())
+	})
+
+	It("requires the anomaly-collection if anomaly-events are enabled", func() {
+		os.Setenv("ANOMALIES_INTERVAL_SEC", "3600")
+		os.Setenv("KAFKA_PRODUCER_ANOMALY_TOPIC", "report.anomaly")
+		cfg, err := Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+		err = cfg.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("MONGO_ANOMALY_COLLECTION"))
+
+		os.Setenv("MONGO_ANOMALY_COLLECTION", "agg_report_itemwaste_anomalies")
+		cfg, err = Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Validate()).To(Succeed())
+	})
+
+	It("redacts secrets without changing the config", func() {
+		cfg, err := Load("", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(cfg.Redacted().Mongo.Password).To(Equal(redactedValue))
+		Expect(cfg.Mongo.Password).To(Equal("secret-password"))
+
+		dump, err := cfg.Dump()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dump).ToNot(ContainSubstring("secret-password"))
+		Expect(dump).To(ContainSubstring("agg_report_itemwaste"))
+	})
+})
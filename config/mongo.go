@@ -0,0 +1,49 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/TerrexTech/go-mongoutils/mongo"
+)
+
+// ClientConfig returns the config for creating a MongoDB-client.
+// The MongoDB-client only supports a single host without connection-options,
+// so all hosts and options, such as for TLS, are included in the first host.
+func (m *Mongo) ClientConfig() mongo.ClientConfig {
+	options := url.Values{}
+	if m.AuthSource != "" {
+		options.Set("authSource", m.AuthSource)
+	}
+	if m.ReplicaSet != "" {
+		options.Set("replicaSet", m.ReplicaSet)
+	}
+	if m.TLS {
+		options.Set("ssl", "true")
+		if m.TLSCAFile != "" {
+			options.Set("sslCertificateAuthorityFile", m.TLSCAFile)
+		}
+		if m.TLSCertKeyFile != "" {
+			options.Set("sslClientCertificateKeyFile", m.TLSCertKeyFile)
+		}
+		if m.TLSCertKeyPassword != "" {
+			options.Set("sslClientCertificateKeyPassword", m.TLSCertKeyPassword)
+		}
+		if m.TLSInsecure {
+			options.Set("sslInsecure", "true")
+		}
+	}
+
+	hosts := strings.Join(m.Hosts, ",")
+	if len(options) > 0 {
+		hosts += "/?" + options.Encode()
+	}
+
+	return mongo.ClientConfig{
+		Hosts: []string{hosts},
+		// The credentials are added to the connection-string as is
+		Username:            url.QueryEscape(m.Username),
+		Password:            url.QueryEscape(m.Password),
+		TimeoutMilliseconds: uint32(m.ConnectionTimeoutMS),
+	}
+}
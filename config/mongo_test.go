@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/mongodb/mongo-go-driver/core/connstring"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mongo ClientConfig", func() {
+	// parse parses the connection-string the same way as the MongoDB-client.
+	parse := func(m Mongo) connstring.ConnString {
+		cc := m.ClientConfig()
+		Expect(cc.Hosts).To(HaveLen(1))
+		cs, err := connstring.Parse(fmt.Sprintf(
+			"mongodb://%s:%s@%s", cc.Username, cc.Password, cc.Hosts[0],
+		))
+		Expect(err).ToNot(HaveOccurred())
+		return cs
+	}
+
+	It("includes all hosts and escaped credentials", func() {
+		cs := parse(Mongo{
+			Hosts:    []string{"mongo1:27017", "mongo2:27017"},
+			Username: "user@store",
+			Password: "p@ss:word/1",
+		})
+		Expect(cs.Hosts).To(Equal([]string{"mongo1:27017", "mongo2:27017"}))
+		Expect(cs.Username).To(Equal("user@store"))
+		Expect(cs.Password).To(Equal("p@ss:word/1"))
+		Expect(cs.SSL).To(BeFalse())
+	})
+
+	It("includes auth-source, replica-set and TLS options", func() {
+		cs := parse(Mongo{
+			Hosts:          []string{"mongo1:27017"},
+			Username:       "root",
+			Password:       "root",
+			AuthSource:     "users",
+			ReplicaSet:     "rs0",
+			TLS:            true,
+			TLSCAFile:      "/certs/ca.pem",
+			TLSCertKeyFile: "/certs/client.pem",
+			TLSInsecure:    true,
+		})
+		Expect(cs.AuthSource).To(Equal("users"))
+		Expect(cs.ReplicaSet).To(Equal("rs0"))
+		Expect(cs.SSL).To(BeTrue())
+		Expect(cs.SSLCaFile).To(Equal("/certs/ca.pem"))
+		Expect(cs.SSLClientCertificateKeyFile).To(Equal("/certs/client.pem"))
+		Expect(cs.SSLInsecure).To(BeTrue())
+	})
+
+	It("requires TLS to be enabled for other TLS options", func() {
+		m := Mongo{
+			Hosts:               []string{"mongo1:27017"},
+			Database:            "db",
+			AggCollection:       "agg",
+			MetaCollection:      "meta",
+			ReportCollection:    "report",
+			ConnectionTimeoutMS: 1000,
+			ResourceTimeoutMS:   1000,
+		}
+		Expect(m.Validate()).To(Succeed())
+
+		m.TLSInsecure = true
+		Expect(m.Validate()).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/TerrexTech/agg-itemwaste-report/alert"
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+)
+
+// currencyPattern matches ISO 4217 currency-codes, such as "USD".
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidationError lists all the problems found when validating a Config.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "Invalid config: " + strings.Join(e, "; ")
+}
+
+// validator collects problems, so all of them can be reported at once.
+type validator struct {
+	problems ValidationError
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) required(value string, name string) {
+	v.check(value != "", "%s is required", name)
+}
+
+func (v *validator) listenAddr(addr string, name string) {
+	if addr == "" {
+		return
+	}
+	_, _, err := net.SplitHostPort(addr)
+	v.check(err == nil, "%s must be a host:port address, got: %s", name, addr)
+}
+
+func (v *validator) fileExists(path string, name string) {
+	if path == "" {
+		return
+	}
+	_, err := os.Stat(path)
+	v.check(err == nil, "%s must be a readable file, got: %s", name, path)
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return v.problems
+}
+
+// Validate checks all fields of the Config. The returned error is
+// a ValidationError listing every problem found.
+func (c *Config) Validate() error {
+	v := &validator{}
+	v.required(c.ServiceName, "SERVICE_NAME")
+	c.Kafka.validate(v)
+	c.Mongo.validate(v)
+	c.Servers.validate(v)
+	c.Health.validate(v)
+	c.WasteGauges.validate(v)
+	c.Tracing.validate(v)
+	c.Scoping.validate(v)
+	c.Pricing.validate(v)
+	c.Alerts.validate(v, c.Mongo.AlertCollection != "")
+	c.Anomalies.validate(v, c.Mongo.AnomalyCollection != "")
+	c.Schedules.validate(v, c.Mongo.ScheduleCollection != "")
+	return v.err()
+}
+
+// Validate checks the Mongo fields, for tools that only need Mongo.
+func (m *Mongo) Validate() error {
+	v := &validator{}
+	m.validate(v)
+	return v.err()
+}
+
+func (k *Kafka) validate(v *validator) {
+	v.check(len(k.Brokers) > 0, "KAFKA_BROKERS is required")
+	v.required(k.LogProducerTopic, "KAFKA_LOG_PRODUCER_TOPIC")
+	v.required(k.ConsumerEventGroup, "KAFKA_CONSUMER_EVENT_GROUP")
+	v.required(k.ConsumerEventQueryGroup, "KAFKA_CONSUMER_EVENT_QUERY_GROUP")
+	v.required(k.ConsumerEventTopic, "KAFKA_CONSUMER_EVENT_TOPIC")
+	v.required(k.ConsumerEventQueryTopic, "KAFKA_CONSUMER_EVENT_QUERY_TOPIC")
+	v.required(k.ProducerEventQueryTopic, "KAFKA_PRODUCER_EVENT_QUERY_TOPIC")
+	v.required(k.ProducerResponseTopic, "KAFKA_PRODUCER_RESPONSE_TOPIC")
+}
+
+func (m *Mongo) validate(v *validator) {
+	v.check(len(m.Hosts) > 0, "MONGO_HOSTS is required")
+	v.check(
+		(m.Username == "") == (m.Password == ""),
+		"MONGO_USERNAME and MONGO_PASSWORD must be set together",
+	)
+	v.required(m.Database, "MONGO_DATABASE")
+	v.required(m.AggCollection, "MONGO_AGG_COLLECTION")
+	v.required(m.MetaCollection, "MONGO_META_COLLECTION")
+	v.required(m.ReportCollection, "MONGO_REPORT_COLLECTION")
+	if !m.TLS {
+		v.check(
+			m.TLSCAFile == "" && m.TLSCertKeyFile == "" && !m.TLSInsecure,
+			"MONGO_TLS must be enabled to use other MONGO_TLS options",
+		)
+	}
+	v.fileExists(m.TLSCAFile, "MONGO_TLS_CA_FILE")
+	v.fileExists(m.TLSCertKeyFile, "MONGO_TLS_CERT_KEY_FILE")
+	v.check(
+		m.TLSCertKeyPassword == "" || m.TLSCertKeyFile != "",
+		"MONGO_TLS_CERT_KEY_PASSWORD requires MONGO_TLS_CERT_KEY_FILE",
+	)
+	v.check(m.ConnectionTimeoutMS > 0, "MONGO_CONNECTION_TIMEOUT_MS must be positive")
+	v.check(m.ResourceTimeoutMS > 0, "MONGO_RESOURCE_TIMEOUT_MS must be positive")
+}
+
+func (s *Servers) validate(v *validator) {
+	v.listenAddr(s.HTTPListenAddr, "HTTP_LISTEN_ADDR")
+	v.listenAddr(s.GRPCListenAddr, "GRPC_LISTEN_ADDR")
+	v.listenAddr(s.AdminListenAddr, "ADMIN_LISTEN_ADDR")
+}
+
+func (h *Health) validate(v *validator) {
+	v.check(h.LivenessTimeoutSec > 0, "HEALTH_LIVENESS_TIMEOUT_SEC must be positive")
+}
+
+func (w *WasteGauges) validate(v *validator) {
+	v.check(w.IntervalSec >= 0, "WASTE_GAUGES_INTERVAL_SEC must not be negative")
+	v.check(w.MaxSeries > 0, "WASTE_GAUGES_MAX_SERIES must be positive")
+}
+
+func (t *Tracing) validate(v *validator) {
+	if t.Exporter == "file" {
+		v.required(t.FilePath, "TRACE_FILE_PATH")
+	}
+}
+
+func (s *Scoping) validate(v *validator) {
+	_, err := auth.PolicyFromConfig(s.Policy)
+	if err != nil {
+		v.check(false, "scoping.policy is invalid: %s", err)
+	}
+}
+
+func (p *Pricing) validate(v *validator) {
+	v.check(
+		currencyPattern.MatchString(p.Currency),
+		"PRICE_CURRENCY must be a 3-letter uppercase currency-code, got: %s", p.Currency,
+	)
+}
+
+func (a *Alerts) validate(v *validator, hasCollection bool) {
+	if len(a.Rules) == 0 {
+		return
+	}
+	// Without the firing alerts shared in Mongo, every replica
+	// would send the alert-events
+	v.check(hasCollection, "MONGO_ALERT_COLLECTION is required if there are alert-rules")
+	v.required(a.Topic, "KAFKA_PRODUCER_ALERT_TOPIC")
+	v.check(a.IntervalSec > 0, "ALERTS_INTERVAL_SEC must be positive")
+	err := alert.ValidateRules(a.Rules)
+	if err != nil {
+		v.check(false, "alerts.rules is invalid: %s", err)
+	}
+}
+
+func (a *Anomalies) validate(v *validator, hasCollection bool) {
+	v.check(a.IntervalSec >= 0, "ANOMALIES_INTERVAL_SEC must not be negative")
+	if a.IntervalSec > 0 {
+		// Without the produced anomalies shared in Mongo, every replica
+		// would produce the anomaly-events
+		v.check(
+			hasCollection,
+			"MONGO_ANOMALY_COLLECTION is required if ANOMALIES_INTERVAL_SEC is set",
+		)
+		v.required(a.Topic, "KAFKA_PRODUCER_ANOMALY_TOPIC")
+	}
+	v.check(
+		a.BaselineDays >= report.MinBaselineDays,
+		"ANOMALIES_BASELINE_DAYS must be atleast %d", report.MinBaselineDays,
+	)
+	v.check(a.Threshold > 0, "ANOMALIES_THRESHOLD must be positive")
+}
+
+func (s *Schedules) validate(v *validator, enabled bool) {
+	if !enabled {
+		return
+	}
+	v.required(s.Topic, "KAFKA_PRODUCER_SCHEDULE_TOPIC")
+	v.check(s.IntervalSec > 0, "SCHEDULES_INTERVAL_SEC must be positive")
+}
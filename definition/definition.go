@@ -0,0 +1,117 @@
+// Package definition contains the saved report-definitions, which are named
+// and versioned WasteItemParams that can be run later by name or ID.
+package definition
+
+import (
+	"encoding/json"
+
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// Definition is a version of a saved report-definition. Each save of a
+// Definition stores a new version, having the same DefinitionID and Name.
+type Definition struct {
+	DefinitionID string `json:"definitionID"`
+	Name         string `json:"name"`
+	// Version starts at 1, and increases with each save.
+	Version     int    `json:"version"`
+	Description string `json:"description,omitempty"`
+	// Params are the report's filters and grouping, and are the defaults
+	// the overrides of each run are applied to.
+	Params report.WasteItemParams `json:"params"`
+	Format string                 `json:"format,omitempty"`
+	Unit   string                 `json:"unit,omitempty"`
+	// CreatedBy is the subject of the claims that saved the version, and
+	// CreatedAt is the unix-timestamp it was saved at.
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt int64  `json:"createdAt,omitempty"`
+}
+
+// Validate checks the Definition's fields, and that its Params can be run.
+func (d *Definition) Validate() error {
+	if d.Name == "" {
+		return errors.New("Definition-name is required")
+	}
+	if !report.ValidFormat(d.Format) || !report.ValidUnit(d.Unit) {
+		return errors.Errorf("Unsupported output-format or weight-unit: %s, %s", d.Format, d.Unit)
+	}
+	return d.Params.Validate()
+}
+
+// Stores returns the stores the Definition's reports are limited to,
+// or nil if it is not limited to any stores.
+func (d *Definition) Stores() []string {
+	return d.Params.Stores()
+}
+
+// RunParams returns the Definition's Params with the overrides applied.
+// The overrides are the JSON of WasteItemParams, and only the fields they
+// have replace the Definition's, such as only the "timestamp".
+func (d *Definition) RunParams(overrides json.RawMessage) (report.WasteItemParams, error) {
+	params := d.Params
+	if len(overrides) == 0 {
+		return params, nil
+	}
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(overrides, &fields)
+	if err != nil {
+		err = errors.Wrap(err, "Error reading overrides")
+		return report.WasteItemParams{}, err
+	}
+	// Overridden filters are replaced as a whole. Otherwise they would be
+	// merged into, and change, the Definition's filters.
+	for field := range fields {
+		switch field {
+		case "timestamp":
+			params.Timestamp = nil
+		case "storeID":
+			params.StoreID = nil
+		case "reason":
+			params.Reason = nil
+		case "disposal":
+			params.Disposal = nil
+		case "sku":
+			params.SKU = nil
+		case "category":
+			params.Category = nil
+		}
+	}
+
+	err = json.Unmarshal(overrides, &params)
+	if err != nil {
+		err = errors.Wrap(err, "Error applying overrides")
+		return report.WasteItemParams{}, err
+	}
+	return params, nil
+}
+
+// definitionBSON is the stored Definition. Its Params are stored the same
+// as the SearchQuery of WasteReports.
+type definitionBSON struct {
+	DefinitionID string                 `bson:"definitionID"`
+	Name         string                 `bson:"name"`
+	Version      int                    `bson:"version"`
+	Description  string                 `bson:"description"`
+	Params       report.WasteItemParams `bson:"params"`
+	Format       string                 `bson:"format"`
+	Unit         string                 `bson:"unit"`
+	CreatedBy    string                 `bson:"createdBy"`
+	CreatedAt    int64                  `bson:"createdAt"`
+}
+
+func (d Definition) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(definitionBSON(d))
+}
+
+func (d *Definition) UnmarshalBSON(in []byte) error {
+	db := &definitionBSON{}
+	err := bson.Unmarshal(in, db)
+	if err != nil {
+		err = errors.Wrap(err, "UnmarshalBSON Error")
+		return err
+	}
+	*d = Definition(*db)
+	return nil
+}
@@ -0,0 +1,13 @@
+package definition
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDefinition(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Definition Suite")
+}
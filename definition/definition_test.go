@@ -0,0 +1,93 @@
+package definition
+
+import (
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/mongodb/mongo-go-driver/bson"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Definition", func() {
+	var d Definition
+
+	BeforeEach(func() {
+		d = Definition{
+			DefinitionID: "definition-1",
+			Name:         "weekly-produce",
+			Version:      2,
+			Params: report.WasteItemParams{
+				Timestamp: &report.Comparator{
+					Gt: 1529315000,
+					Lt: 1529919800,
+				},
+				StoreID: &report.InComparator{
+					In: []string{"store-1"},
+				},
+				GroupByReason: true,
+			},
+			Format: report.FormatCSV,
+		}
+	})
+
+	It("validates the definition", func() {
+		Expect(d.Validate()).To(Succeed())
+
+		invalid := d
+		invalid.Name = ""
+		Expect(invalid.Validate()).ToNot(Succeed())
+		invalid = d
+		invalid.Unit = "stone"
+		Expect(invalid.Validate()).ToNot(Succeed())
+		invalid = d
+		invalid.Params.RollUp = "aisle"
+		Expect(invalid.Validate()).ToNot(Succeed())
+	})
+
+	It("applies the overrides to the params", func() {
+		params, err := d.RunParams([]byte(
+			`{"timestamp": {"$gt": 1529919800, "$lt": 1530524600}, "groupByStore": true}`,
+		))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params.Timestamp).To(Equal(&report.Comparator{Gt: 1529919800, Lt: 1530524600}))
+		Expect(params.GroupByStore).To(BeTrue())
+		// Fields not overridden are kept
+		Expect(params.StoreID.In).To(Equal([]string{"store-1"}))
+		Expect(params.GroupByReason).To(BeTrue())
+		// The definition is not changed
+		Expect(d.Params.Timestamp.Gt).To(Equal(float64(1529315000)))
+
+		// Filters are replaced rather than merged
+		params, err = d.RunParams([]byte(`{"timestamp": {"$lt": 1529500000}}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params.Timestamp).To(Equal(&report.Comparator{Lt: 1529500000}))
+
+		params, err = d.RunParams(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params).To(Equal(d.Params))
+
+		_, err = d.RunParams([]byte(`{"timestamp": 5}`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("requires the definitionID or name in refs", func() {
+		Expect(Ref{Name: "weekly-produce"}.Validate()).To(Succeed())
+		Expect(Ref{DefinitionID: "definition-1", Version: 1}.Validate()).To(Succeed())
+		Expect(Ref{Version: 1}.Validate()).ToNot(Succeed())
+		Expect(Ref{Name: "weekly-produce", Version: -1}.Validate()).ToNot(Succeed())
+
+		Expect(Ref{Name: "weekly-produce"}.filter()).To(Equal(map[string]interface{}{
+			"name": "weekly-produce",
+		}))
+	})
+
+	It("round-trips through BSON", func() {
+		d.CreatedBy = "user-1"
+		d.CreatedAt = 1530000000
+
+		data, err := bson.Marshal(d)
+		Expect(err).ToNot(HaveOccurred())
+		decoded := &Definition{}
+		Expect(bson.Unmarshal(data, decoded)).To(Succeed())
+		Expect(*decoded).To(Equal(d))
+	})
+})
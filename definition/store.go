@@ -0,0 +1,165 @@
+package definition
+
+import (
+	"log"
+	"time"
+
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
+	"github.com/mongodb/mongo-go-driver/bson"
+	mgo "github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/findopt"
+	"github.com/pkg/errors"
+)
+
+// Ref refers to a saved Definition by its ID or name, and optionally
+// its version. The latest version is used if Version is 0.
+type Ref struct {
+	DefinitionID string `json:"definitionID,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Version      int    `json:"version,omitempty"`
+}
+
+// Validate checks that the Ref has the DefinitionID or Name.
+func (r Ref) Validate() error {
+	if r.DefinitionID == "" && r.Name == "" {
+		return errors.New("definitionID or name is required")
+	}
+	if r.Version < 0 {
+		return errors.Errorf("Version must not be negative, got: %d", r.Version)
+	}
+	return nil
+}
+
+func (r Ref) filter() map[string]interface{} {
+	filter := map[string]interface{}{}
+	if r.DefinitionID != "" {
+		filter["definitionID"] = r.DefinitionID
+	}
+	if r.Name != "" {
+		filter["name"] = r.Name
+	}
+	if r.Version > 0 {
+		filter["version"] = r.Version
+	}
+	return filter
+}
+
+// find returns the versions of Definitions matching the filter,
+// sorted by name and newest version first. A limit of 0 returns all.
+func find(defColl *mongo.Collection, filter map[string]interface{}, limit int64) ([]*Definition, error) {
+	findResults, err := defColl.Find(
+		filter,
+		// A document keeps the order of the sort-keys
+		findopt.Sort(bson.NewDocument(
+			bson.EC.Int32("name", 1),
+			bson.EC.Int32("version", -1),
+		)),
+		findopt.Limit(limit),
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error finding definitions")
+		log.Println(err)
+		return nil, err
+	}
+
+	definitions := make([]*Definition, 0, len(findResults))
+	for _, v := range findResults {
+		d, assertOK := v.(*Definition)
+		if !assertOK {
+			err = errors.New("Error asserting find-result to Definition")
+			log.Println(err)
+			return nil, err
+		}
+		definitions = append(definitions, d)
+	}
+	return definitions, nil
+}
+
+// Find finds the saved Definition the Ref refers to.
+// report.IsNotFound is true for the error if there is none.
+func Find(defColl *mongo.Collection, ref Ref) (*Definition, error) {
+	definitions, err := find(defColl, ref.filter(), 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(definitions) == 0 {
+		return nil, errors.Wrapf(
+			mgo.ErrNoDocuments, "No definition with ID %q or name %q", ref.DefinitionID, ref.Name,
+		)
+	}
+	return definitions[0], nil
+}
+
+// Versions returns all versions of the Definition the Ref refers to,
+// newest first. The Ref's Version is ignored.
+func Versions(defColl *mongo.Collection, ref Ref) ([]*Definition, error) {
+	ref.Version = 0
+	return find(defColl, ref.filter(), 0)
+}
+
+// List returns the latest version of each saved Definition, by name.
+func List(defColl *mongo.Collection) ([]*Definition, error) {
+	definitions, err := find(defColl, map[string]interface{}{}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make([]*Definition, 0, len(definitions))
+	for i, d := range definitions {
+		// The versions of a Definition are sorted together, newest first
+		if i > 0 && definitions[i-1].DefinitionID == d.DefinitionID {
+			continue
+		}
+		latest = append(latest, d)
+	}
+	return latest, nil
+}
+
+// Save stores the Definition as a new version. The Definition with the
+// same name gets a new version, and otherwise a new Definition is created.
+// The DefinitionID, Version and CreatedAt are set on the Definition.
+// Definitions can't be renamed, so a DefinitionID, if set, must be the
+// one of the Definition having the name.
+func Save(defColl *mongo.Collection, d *Definition, now time.Time) error {
+	definitions, err := find(defColl, map[string]interface{}{
+		"name": d.Name,
+	}, 1)
+	if err != nil {
+		return err
+	}
+
+	if len(definitions) > 0 {
+		latest := definitions[0]
+		if d.DefinitionID != "" && d.DefinitionID != latest.DefinitionID {
+			return errors.Errorf(
+				"Name %q is used by definition %s", d.Name, latest.DefinitionID,
+			)
+		}
+		d.DefinitionID = latest.DefinitionID
+		d.Version = latest.Version + 1
+	} else {
+		if d.DefinitionID != "" {
+			return errors.Errorf(
+				"No definition with ID %s and name %q, definitions can't be renamed",
+				d.DefinitionID, d.Name,
+			)
+		}
+		definitionID, err := uuuid.NewV4()
+		if err != nil {
+			err = errors.Wrap(err, "Error in generating definitionID")
+			return err
+		}
+		d.DefinitionID = definitionID.String()
+		d.Version = 1
+	}
+	d.CreatedAt = now.Unix()
+
+	// Concurrent saves of the same version are rejected by the unique index
+	_, err = defColl.InsertOne(d)
+	if err != nil {
+		err = errors.Wrap(err, "Error inserting definition")
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
+)
+
+// newAdminMux creates the http.Handler for operational endpoints, which are
+// served separately from the HTTP API. Routes:
+//  /metrics  Prometheus metrics
+//  /livez    liveness, fails if the main-loop stops progressing
+//  /readyz   readiness, fails until started and all readiness-checks pass
+func newAdminMux(h *health) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/livez", h.handleLive)
+	mux.HandleFunc("/readyz", h.handleReady)
+	return mux
+}
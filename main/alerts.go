@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/alert"
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// produceAlerts checks the alert-rules, and produces the resulting
+// alert-events on the topic. The events are keyed by their alert's ID,
+// so the firing and resolved events of an alert stay in order.
+func produceAlerts(
+	alerter *alert.Alerter,
+	itemWasteColl *mongo.Collection,
+	producer *kafka.Producer,
+	topic string,
+) error {
+	// The events are produced even if some rules failed
+	events, checkErr := alerter.Check(itemWasteColl, time.Now())
+	for _, e := range events {
+		value, err := json.Marshal(e)
+		if err != nil {
+			err = errors.Wrapf(err, "Error marshalling alert-event %s", e.ID())
+			return err
+		}
+		producer.Input() <- kafka.CreateKeyMessage(topic, e.ID(), value)
+		metrics.AlertEvents.WithLabelValues(e.Rule, e.Status).Inc()
+	}
+	return checkErr
+}
+
+// runAlerts checks the alert-rules every interval until ctx is done.
+func runAlerts(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	alertColl *mongo.Collection,
+	producer *kafka.Producer,
+	topic string,
+	interval time.Duration,
+	rules []alert.Rule,
+) {
+	alerter := alert.NewAlerter(rules, alertColl)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := produceAlerts(alerter, itemWasteColl, producer, topic)
+		if err != nil {
+			err = errors.Wrap(err, "Error checking alert-rules")
+			logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
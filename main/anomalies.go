@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// AnomaliesAction is the ServiceAction for the anomalies report.
+const AnomaliesAction = "Anomalies"
+
+// Anomalies handles "query" events for the anomalies report, having the days
+// on which a SKU's waste at a store deviated from its learned baseline.
+// The waste is limited to the stores allowed by the claims.
+// The report is not stored.
+func Anomalies(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	claims *auth.Claims,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format: `{"timestamp":{"$gt":1529315000,"$lt":1551997372}}`
+	// Optional `"sku":{"$in":["<sku>"]}` and `"storeID":{"$in":["<store>"]}` filter the waste,
+	// and optional `"baselineDays"` and `"threshold"` tune the detection.
+	// An optional `"format"` of "json" (default) or "csv", and `"unit"` as for Query.
+	params := report.AnomalyParams{}
+	err := json.Unmarshal(event.Data, &params)
+	if err != nil {
+		err = errors.Wrap(err, "Anomalies: Error while unmarshalling Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	output := report.OutputParams{}
+	err = json.Unmarshal(event.Data, &output)
+	if err != nil {
+		err = errors.Wrap(err, "Anomalies: Error while unmarshalling Event-data - OutputParams")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if !report.ValidDataFormat(output.Format) || !report.ValidUnit(output.Unit) {
+		err = errors.Errorf(
+			"Anomalies: Unsupported output-format or weight-unit: %s, %s", output.Format, output.Unit,
+		)
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = params.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "Anomalies: Invalid params")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = scopeStores(claims, &params.StoreID)
+	if err != nil {
+		err = errors.Wrap(err, "Anomalies: Error scoping report to claims")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	_, anomalySpan := tracing.Tracer().Start(ctx, "mongo.anomalies")
+	anomalies, err := report.AnomalyReport(params, itemWasteColl)
+	if err != nil {
+		tracing.RecordError(anomalySpan, err)
+	}
+	anomalySpan.End()
+	if err != nil {
+		err = errors.Wrap(err, "Anomalies: Error detecting anomalies")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	// The unit is already validated, so this can't fail
+	anomalies, _ = report.AnomaliesInUnit(anomalies, output.Unit)
+
+	result, err := report.MarshalAnomalies(anomalies, output.Format)
+	if err != nil {
+		err = errors.Wrap(err, "Anomalies: Error marshalling anomalies")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
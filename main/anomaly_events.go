@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/config"
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// produceAnomalies detects the anomalies on the UTC-day starting at the
+// day unix-timestamp, and produces each as an event on the topic. The events
+// are keyed by their SKU and store. Each anomaly is claimed in anomalyColl
+// first, and skipped if it was already claimed, so it is only produced once
+// by all replicas, even if the day is checked again.
+func produceAnomalies(
+	itemWasteColl *mongo.Collection,
+	anomalyColl *mongo.Collection,
+	producer *kafka.Producer,
+	cfg config.Anomalies,
+	day int64,
+) error {
+	params := report.AnomalyParams{
+		Timestamp: &report.Comparator{
+			Gt: float64(day - 1),
+			Lt: float64(day + 24*60*60),
+		},
+		BaselineDays: cfg.BaselineDays,
+		Threshold:    cfg.Threshold,
+	}
+	err := params.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "Invalid anomaly-params")
+		return err
+	}
+	anomalies, err := report.AnomalyReport(params, itemWasteColl)
+	if err != nil {
+		err = errors.Wrap(err, "Error detecting anomalies")
+		return err
+	}
+
+	for _, a := range anomalies {
+		claimed, err := report.ClaimAnomaly(anomalyColl, a)
+		if err != nil {
+			err = errors.Wrap(err, "Error claiming anomaly")
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		value, err := json.Marshal(a)
+		if err != nil {
+			err = errors.Wrapf(err, "Error marshalling anomaly for SKU %s", a.SKU)
+			return err
+		}
+		producer.Input() <- kafka.CreateKeyMessage(cfg.Topic, a.SKU+"/"+a.StoreID, value)
+		metrics.AnomalyEvents.WithLabelValues(a.Direction).Inc()
+	}
+	return nil
+}
+
+// runAnomalyEvents checks every interval if a UTC-day has ended, and then
+// produces the anomalies on that day, until ctx is done. Each replica checks
+// each day once, and again after a restart, but the anomalies are claimed
+// in anomalyColl, so their events are only produced once.
+func runAnomalyEvents(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	anomalyColl *mongo.Collection,
+	producer *kafka.Producer,
+	cfg config.Anomalies,
+) {
+	ticker := time.NewTicker(time.Duration(cfg.IntervalSec) * time.Second)
+	defer ticker.Stop()
+
+	var lastDay int64
+	for {
+		now := time.Now().UTC()
+		yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC).Unix()
+		if yesterday > lastDay {
+			err := produceAnomalies(itemWasteColl, anomalyColl, producer, cfg, yesterday)
+			if err != nil {
+				err = errors.Wrap(err, "Error producing anomaly-events")
+				logger.E(tlog.Entry{
+					Description: err.Error(),
+					ErrorCode:   1,
+				})
+			} else {
+				lastDay = yesterday
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/config"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/pkg/errors"
+)
+
+// loadPolicy returns the configured authorization-policy,
+// or the auth.DefaultPolicy if none is configured.
+func loadPolicy(cfg config.Scoping) (auth.Policy, error) {
+	if len(cfg.Policy) == 0 {
+		return auth.DefaultPolicy(), nil
+	}
+	return auth.PolicyFromConfig(cfg.Policy)
+}
+
+// actionPermission returns the Permission required for the ServiceAction.
+func actionPermission(serviceAction string) auth.Permission {
+	switch serviceAction {
+	case RenderReportAction:
+		return auth.PermissionRender
+	case SaveScheduleAction, DeleteScheduleAction, ListSchedulesAction:
+		return auth.PermissionSchedule
+	}
+	return auth.PermissionQuery
+}
+
+// authorizeEvent reads the claims from the event, and checks them against the
+// policy for the event's ServiceAction. Every decision is logged for audit.
+// An error is returned if the event is not authorized, and otherwise the
+// Decision has the permissions granted to the caller.
+func authorizeEvent(
+	logger tlog.Logger,
+	policy auth.Policy,
+	requireClaims bool,
+	event *model.Event,
+) (*auth.Claims, *auth.Decision, error) {
+	claims, err := eventClaims(event, requireClaims)
+	if err != nil {
+		err = errors.Wrap(err, "Error reading claims from event")
+		logger.I(tlog.Entry{
+			Description: fmt.Sprintf(
+				"Authorization denied for event %s: %s", event.UUID, err,
+			),
+			ErrorCode: UnauthorizedError,
+		})
+		return nil, nil, err
+	}
+
+	decision, err := authorizeClaims(
+		logger, policy, claims, actionPermission(event.ServiceAction),
+		fmt.Sprintf("event %s", event.UUID),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return claims, decision, nil
+}
+
+// authorizeClaims checks the claims against the policy for the Permission,
+// and logs the decision for audit. The request describes what the caller
+// requested, such as the event, in the log.
+func authorizeClaims(
+	logger tlog.Logger,
+	policy auth.Policy,
+	claims *auth.Claims,
+	perm auth.Permission,
+	request string,
+) (*auth.Decision, error) {
+	decision := policy.Authorize(claims, perm)
+	if !decision.Allowed {
+		logger.I(tlog.Entry{
+			Description: fmt.Sprintf(
+				"Authorization denied for %s: %s", request, decision.Reason,
+			),
+			ErrorCode: UnauthorizedError,
+		}, decision)
+		return nil, errors.Errorf("Unauthorized: %s", decision.Reason)
+	}
+
+	logger.I(tlog.Entry{
+		Description: fmt.Sprintf("Authorization allowed for %s", request),
+	}, decision)
+	return decision, nil
+}
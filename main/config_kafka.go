@@ -2,34 +2,75 @@ package main
 
 import (
 	"os"
+	"regexp"
+	"time"
 
-	"github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/agg-itemwaste-report/config"
 	"github.com/TerrexTech/go-eventspoll/poll"
 	"github.com/TerrexTech/go-kafkautils/kafka"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
 )
 
-func loadKafkaConfig() (*poll.KafkaConfig, error) {
-	kafkaBrokers := *commonutil.ParseHosts(
-		os.Getenv("KAFKA_BROKERS"),
-	)
+// invalidClientIDChars are the characters not allowed in Kafka client-IDs.
+var invalidClientIDChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
 
-	cEventGroup := os.Getenv("KAFKA_CONSUMER_EVENT_GROUP")
-	cEventQueryGroup := os.Getenv("KAFKA_CONSUMER_EVENT_QUERY_GROUP")
-	cEventTopic := os.Getenv("KAFKA_CONSUMER_EVENT_TOPIC")
-	cEventQueryTopic := os.Getenv("KAFKA_CONSUMER_EVENT_QUERY_TOPIC")
-	pEventQueryTopic := os.Getenv("KAFKA_PRODUCER_EVENT_QUERY_TOPIC")
-	pResponseTopic := os.Getenv("KAFKA_PRODUCER_RESPONSE_TOPIC")
+// consumerClientID returns the Kafka client-ID of this instance's consumers,
+// being the service-name and hostname with a random suffix, so the instance
+// can find its own members in the consumer-groups.
+func consumerClientID(serviceName string) (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		err = errors.Wrap(err, "Error getting hostname")
+		return "", err
+	}
+	suffix, err := uuuid.NewV4()
+	if err != nil {
+		err = errors.Wrap(err, "Error generating client-ID suffix")
+		return "", err
+	}
+	clientID := serviceName + "-" + hostname + "-" + suffix.String()[:8]
+	return invalidClientIDChars.ReplaceAllString(clientID, "_"), nil
+}
+
+// consumerSaramaConfig returns the default consumer-config of kafka.NewConsumer,
+// having the provided client-ID.
+func consumerSaramaConfig(clientID string) *sarama.Config {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.ClientID = clientID
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	saramaConfig.Consumer.MaxProcessingTime = 10 * time.Second
+	saramaConfig.Consumer.Return.Errors = true
+	saramaConfig.Version = sarama.V2_0_0_0
+	return saramaConfig
+}
+
+// loadKafkaConfig returns the KafkaConfig for the events-poll. The consumers
+// use the clientID, which is checked for by the readiness-check.
+func loadKafkaConfig(cfg config.Kafka, clientID string) (*poll.KafkaConfig, error) {
+	kafkaBrokers := cfg.Brokers
+
+	cEventGroup := cfg.ConsumerEventGroup
+	cEventQueryGroup := cfg.ConsumerEventQueryGroup
+	cEventTopic := cfg.ConsumerEventTopic
+	cEventQueryTopic := cfg.ConsumerEventQueryTopic
+	pEventQueryTopic := cfg.ProducerEventQueryTopic
+	pResponseTopic := cfg.ProducerResponseTopic
 
 	kc := &poll.KafkaConfig{
 		EventCons: &kafka.ConsumerConfig{
 			KafkaBrokers: kafkaBrokers,
 			GroupName:    cEventGroup,
 			Topics:       []string{cEventTopic},
+			SaramaConfig: consumerSaramaConfig(clientID),
 		},
 		ESQueryResCons: &kafka.ConsumerConfig{
 			KafkaBrokers: kafkaBrokers,
 			GroupName:    cEventQueryGroup,
 			Topics:       []string{cEventQueryTopic},
+			SaramaConfig: consumerSaramaConfig(clientID),
 		},
 
 		ESQueryReqProd: &kafka.ProducerConfig{
@@ -44,3 +85,24 @@ func loadKafkaConfig() (*poll.KafkaConfig, error) {
 
 	return kc, nil
 }
+
+// newEventProducer creates the producer for alert and anomaly events.
+// Errors producing the events are logged.
+func newEventProducer(logger tlog.Logger, brokers []string) (*kafka.Producer, error) {
+	producer, err := kafka.NewProducer(&kafka.ProducerConfig{
+		KafkaBrokers: brokers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for err := range producer.Errors() {
+			logger.E(tlog.Entry{
+				Description: errors.Wrap(err, "Error producing event").Error(),
+				ErrorCode:   1,
+			})
+		}
+	}()
+	return producer, nil
+}
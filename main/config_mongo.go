@@ -1,63 +1,33 @@
 package main
 
 import (
-	"log"
-	"os"
-	"strconv"
-
-	"github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/agg-itemwaste-report/config"
 	"github.com/TerrexTech/go-eventspoll/poll"
 	"github.com/TerrexTech/go-mongoutils/mongo"
 	"github.com/pkg/errors"
 )
 
-func loadMongoConfig(collectionName string, schema interface{}) (*poll.MongoConfig, error) {
-	hosts := *commonutil.ParseHosts(
-		os.Getenv("MONGO_HOSTS"),
-	)
-
-	database := os.Getenv("MONGO_DATABASE")
-	metaCollection := os.Getenv("MONGO_META_COLLECTION")
-
-	username := os.Getenv("MONGO_USERNAME")
-	password := os.Getenv("MONGO_PASSWORD")
-	connTimeoutStr := os.Getenv("MONGO_CONNECTION_TIMEOUT_MS")
-	connTimeout, err := strconv.Atoi(connTimeoutStr)
-	if err != nil {
-		err = errors.Wrap(err, "Error converting MONGO_CONNECTION_TIMEOUT_MS to integer")
-		log.Println(err)
-		log.Println("A defalt value of 3000 will be used for MONGO_CONNECTION_TIMEOUT_MS")
-		connTimeout = 3000
-	}
-
-	mongoConfig := mongo.ClientConfig{
-		Hosts:               hosts,
-		Username:            username,
-		Password:            password,
-		TimeoutMilliseconds: uint32(connTimeout),
-	}
-
-	// MongoDB Client
-	client, err := mongo.NewClient(mongoConfig)
+// newMongoConnection creates the MongoDB-client shared by all collections.
+func newMongoConnection(cfg config.Mongo) (*mongo.ConnectionConfig, error) {
+	client, err := mongo.NewClient(cfg.ClientConfig())
 	if err != nil {
 		err = errors.Wrap(err, "Error creating MongoClient")
-		log.Fatalln(err)
+		return nil, err
 	}
 
-	resTimeoutStr := os.Getenv("MONGO_CONNECTION_TIMEOUT_MS")
-	resTimeout, err := strconv.Atoi(resTimeoutStr)
-	if err != nil {
-		err = errors.Wrap(err, "Error converting MONGO_RESOURCE_TIMEOUT_MS to integer")
-		log.Println(err)
-		log.Println("A defalt value of 5000 will be used for MONGO_RESOURCE_TIMEOUT_MS")
-		connTimeout = 5000
-	}
-	conn := &mongo.ConnectionConfig{
+	return &mongo.ConnectionConfig{
 		Client:  client,
-		Timeout: uint32(resTimeout),
-	}
+		Timeout: uint32(cfg.ResourceTimeoutMS),
+	}, nil
+}
 
-	aggMongoCollection, err := createMongoCollection(conn, database, collectionName, schema)
+func loadMongoConfig(
+	cfg config.Mongo,
+	conn *mongo.ConnectionConfig,
+	collectionName string,
+	schema interface{},
+) (*poll.MongoConfig, error) {
+	aggMongoCollection, err := createMongoCollection(conn, cfg.Database, collectionName, schema)
 	if err != nil {
 		err = errors.Wrap(err, "Error creating MongoCollection")
 		return nil, err
@@ -67,8 +37,8 @@ func loadMongoConfig(collectionName string, schema interface{}) (*poll.MongoConf
 		AggregateID:        aggregateID,
 		AggCollection:      aggMongoCollection,
 		Connection:         conn,
-		MetaDatabaseName:   database,
-		MetaCollectionName: metaCollection,
+		MetaDatabaseName:   cfg.Database,
+		MetaCollectionName: cfg.MetaCollection,
 	}, nil
 }
 
@@ -102,30 +72,13 @@ func createMongoCollection(conn *mongo.ConnectionConfig, db string, coll string,
 	return collection, nil
 }
 
-// createClient creates a MongoDB-Client.
-func CreateClient() (*mongo.Client, error) {
-	// Would ideally set these config-params as environment vars
-	config := mongo.ClientConfig{
-		Hosts:               []string{"mongo:27017"},
-		Username:            "root",
-		Password:            "root",
-		TimeoutMilliseconds: 3000,
-	}
-
-	// ====> MongoDB Client
-	client, err := mongo.NewClient(config)
-	// Let the parent functions handle error, always -.-
-	// (Even though in these examples, we won't, for simplicity)
-	return client, err
-}
-
-// createCollection demonstrates creating the collection and the associated database.
-func CreateCollection(client *mongo.Client, collName string, schema interface{}) (*mongo.Collection, error) {
-	// ====> Collection Configuration
-	conn := &mongo.ConnectionConfig{
-		Client:  client,
-		Timeout: 5000,
-	}
+// createItemWasteCollection creates the collection for WasteItem projections.
+func createItemWasteCollection(
+	conn *mongo.ConnectionConfig,
+	db string,
+	collName string,
+	schema interface{},
+) (*mongo.Collection, error) {
 	// Index Configuration
 	indexConfigs := []mongo.IndexConfig{
 		mongo.IndexConfig{
@@ -141,11 +94,282 @@ func CreateCollection(client *mongo.Client, collName string, schema interface{})
 		},
 	}
 
-	// ====> Create New Collection
+	// Create New Collection
+	c := &mongo.Collection{
+		Connection:   conn,
+		Name:         collName,
+		Database:     db,
+		SchemaStruct: schema,
+		Indexes:      indexConfigs,
+	}
+	return mongo.EnsureCollection(c)
+}
+
+// createPriceCollection creates the price-catalogue collection,
+// having a Price per SKU and currency.
+func createPriceCollection(
+	conn *mongo.ConnectionConfig,
+	db string,
+	collName string,
+	schema interface{},
+) (*mongo.Collection, error) {
+	// Index Configuration
+	indexConfigs := []mongo.IndexConfig{
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "sku",
+				},
+				mongo.IndexColumnConfig{
+					Name: "currency",
+				},
+			},
+			IsUnique: true,
+			Name:     "sku_currency_index",
+		},
+	}
+
+	// Create New Collection
 	c := &mongo.Collection{
 		Connection:   conn,
 		Name:         collName,
-		Database:     "rns_projections",
+		Database:     db,
+		SchemaStruct: schema,
+		Indexes:      indexConfigs,
+	}
+	return mongo.EnsureCollection(c)
+}
+
+// createCatalogCollection creates the product-catalogue collection,
+// having a unique index on SKU.
+func createCatalogCollection(
+	conn *mongo.ConnectionConfig,
+	db string,
+	collName string,
+	schema interface{},
+) (*mongo.Collection, error) {
+	// Index Configuration
+	indexConfigs := []mongo.IndexConfig{
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "sku",
+				},
+			},
+			IsUnique: true,
+			Name:     "sku_index",
+		},
+	}
+
+	// Create New Collection
+	c := &mongo.Collection{
+		Connection:   conn,
+		Name:         collName,
+		Database:     db,
+		SchemaStruct: schema,
+		Indexes:      indexConfigs,
+	}
+	return mongo.EnsureCollection(c)
+}
+
+// createLotCollection creates the lot-metadata collection,
+// having a unique index on lot.
+func createLotCollection(
+	conn *mongo.ConnectionConfig,
+	db string,
+	collName string,
+	schema interface{},
+) (*mongo.Collection, error) {
+	// Index Configuration
+	indexConfigs := []mongo.IndexConfig{
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "lot",
+				},
+			},
+			IsUnique: true,
+			Name:     "lot_index",
+		},
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "supplier",
+				},
+			},
+			Name: "supplier_index",
+		},
+	}
+
+	// Create New Collection
+	c := &mongo.Collection{
+		Connection:   conn,
+		Name:         collName,
+		Database:     db,
+		SchemaStruct: schema,
+		Indexes:      indexConfigs,
+	}
+	return mongo.EnsureCollection(c)
+}
+
+// createScheduleCollection creates the report-schedules collection,
+// having a unique index on scheduleID, and an index on the next run
+// for finding the due schedules.
+func createScheduleCollection(
+	conn *mongo.ConnectionConfig,
+	db string,
+	collName string,
+	schema interface{},
+) (*mongo.Collection, error) {
+	// Index Configuration
+	indexConfigs := []mongo.IndexConfig{
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "scheduleID",
+				},
+			},
+			IsUnique: true,
+			Name:     "scheduleID_index",
+		},
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "nextRunAt",
+				},
+			},
+			Name: "nextRunAt_index",
+		},
+	}
+
+	// Create New Collection
+	c := &mongo.Collection{
+		Connection:   conn,
+		Name:         collName,
+		Database:     db,
+		SchemaStruct: schema,
+		Indexes:      indexConfigs,
+	}
+	return mongo.EnsureCollection(c)
+}
+
+// createAlertCollection creates the firing threshold-alerts collection,
+// having a unique index on the rule and key of each alert, so each alert
+// can only be fired by one replica.
+func createAlertCollection(
+	conn *mongo.ConnectionConfig,
+	db string,
+	collName string,
+	schema interface{},
+) (*mongo.Collection, error) {
+	// Index Configuration
+	indexConfigs := []mongo.IndexConfig{
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "rule",
+				},
+				mongo.IndexColumnConfig{
+					Name: "key",
+				},
+			},
+			IsUnique: true,
+			Name:     "rule_key_index",
+		},
+	}
+
+	// Create New Collection
+	c := &mongo.Collection{
+		Connection:   conn,
+		Name:         collName,
+		Database:     db,
+		SchemaStruct: schema,
+		Indexes:      indexConfigs,
+	}
+	return mongo.EnsureCollection(c)
+}
+
+// createAnomalyCollection creates the collection of produced anomaly-events,
+// having a unique index on the day, SKU and store of each anomaly, so each
+// anomaly-event can only be produced by one replica.
+func createAnomalyCollection(
+	conn *mongo.ConnectionConfig,
+	db string,
+	collName string,
+	schema interface{},
+) (*mongo.Collection, error) {
+	// Index Configuration
+	indexConfigs := []mongo.IndexConfig{
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "day",
+				},
+				mongo.IndexColumnConfig{
+					Name: "sku",
+				},
+				mongo.IndexColumnConfig{
+					Name: "storeID",
+				},
+			},
+			IsUnique: true,
+			Name:     "day_sku_storeID_index",
+		},
+	}
+
+	// Create New Collection
+	c := &mongo.Collection{
+		Connection:   conn,
+		Name:         collName,
+		Database:     db,
+		SchemaStruct: schema,
+		Indexes:      indexConfigs,
+	}
+	return mongo.EnsureCollection(c)
+}
+
+// createDefinitionCollection creates the saved report-definitions collection,
+// having a unique index on the version of each definition, and an index on
+// name for finding definitions by name.
+func createDefinitionCollection(
+	conn *mongo.ConnectionConfig,
+	db string,
+	collName string,
+	schema interface{},
+) (*mongo.Collection, error) {
+	// Index Configuration
+	indexConfigs := []mongo.IndexConfig{
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "definitionID",
+				},
+				mongo.IndexColumnConfig{
+					Name: "version",
+				},
+			},
+			IsUnique: true,
+			Name:     "definitionID_version_index",
+		},
+		mongo.IndexConfig{
+			ColumnConfig: []mongo.IndexColumnConfig{
+				mongo.IndexColumnConfig{
+					Name: "name",
+				},
+				mongo.IndexColumnConfig{
+					Name: "version",
+				},
+			},
+			IsUnique: true,
+			Name:     "name_version_index",
+		},
+	}
+
+	// Create New Collection
+	c := &mongo.Collection{
+		Connection:   conn,
+		Name:         collName,
+		Database:     db,
 		SchemaStruct: schema,
 		Indexes:      indexConfigs,
 	}
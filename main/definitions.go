@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/definition"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// ServiceActions for the saved report-definitions.
+const (
+	SaveDefinitionAction  = "SaveDefinition"
+	RunDefinitionAction   = "RunDefinition"
+	ListDefinitionsAction = "ListDefinitions"
+)
+
+// errDefinitionsDisabled is returned by the definition-actions
+// if there is no definition-collection.
+var errDefinitionsDisabled = errors.New("Saved report-definitions are not enabled")
+
+// allowsDefinition checks if the claims allow the stores the Definition is
+// limited to. Definitions not limited to any stores are allowed, since their
+// reports are limited to the claimed stores when run.
+func allowsDefinition(claims *auth.Claims, d *definition.Definition) bool {
+	return d.Stores() == nil || claims.AllowsStores(d.Stores())
+}
+
+// SaveDefinition handles "query" events saving a report-definition, as a new
+// version if a definition with the same name exists. The saved version is
+// returned, having its definitionID and version.
+func SaveDefinition(
+	logger tlog.Logger,
+	defColl *mongo.Collection,
+	claims *auth.Claims,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format:
+	// `{"name":"weekly-produce","description":"...","params":{"groupByStore":true},"format":"csv"}`
+	// The "params" are as for Query, and optional "format" and "unit" are as for Query.
+	if defColl == nil {
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         errDefinitionsDisabled.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	d := &definition.Definition{}
+	err := json.Unmarshal(event.Data, d)
+	if err != nil {
+		err = errors.Wrap(err, "SaveDefinition: Error while unmarshalling Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, d)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = d.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "SaveDefinition: Invalid definition")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, d)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	if !allowsDefinition(claims, d) {
+		err = errors.New("SaveDefinition: Claims do not allow the stores in definition")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	d.CreatedBy = claims.Subject
+	err = definition.Save(defColl, d, time.Now())
+	if err != nil {
+		err = errors.Wrap(err, "SaveDefinition: Error saving definition")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, d)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	result, err := json.Marshal(d)
+	if err != nil {
+		err = errors.Wrap(err, "SaveDefinition: Error marshalling definition")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, d)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
+
+// RunDefinition handles "query" events running a saved report-definition,
+// with the overrides applied to its params. The report is then generated,
+// stored and returned same as for Query.
+func RunDefinition(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	reportColl *mongo.Collection,
+	pricing report.Pricing,
+	catalogColl *mongo.Collection,
+	defColl *mongo.Collection,
+	claims *auth.Claims,
+	showCosts bool,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format:
+	// `{"name":"weekly-produce","overrides":{"timestamp":{"$gt":1529315000,"$lt":1529919800}}}`
+	// The definition is found by "name" or "definitionID", and an optional "version"
+	// (default latest). The "overrides" replace the same fields of the definition's params,
+	// and optional "format" and "unit" replace the definition's.
+	if defColl == nil {
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         errDefinitionsDisabled.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	params := struct {
+		definition.Ref
+		Overrides json.RawMessage `json:"overrides,omitempty"`
+	}{}
+	err := json.Unmarshal(event.Data, &params)
+	if err == nil {
+		err = params.Ref.Validate()
+	}
+	if err != nil {
+		err = errors.Wrap(err, "RunDefinition: Invalid Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	output := report.OutputParams{}
+	err = json.Unmarshal(event.Data, &output)
+	if err != nil {
+		err = errors.Wrap(err, "RunDefinition: Error while unmarshalling Event-data - OutputParams")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if !report.ValidFormat(output.Format) || !report.ValidUnit(output.Unit) {
+		err = errors.Errorf(
+			"RunDefinition: Unsupported output-format or weight-unit: %s, %s",
+			output.Format, output.Unit,
+		)
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	d, err := definition.Find(defColl, params.Ref)
+	if err != nil {
+		err = errors.Wrap(err, "RunDefinition: Error finding definition")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	filter, err := d.RunParams(params.Overrides)
+	if err == nil && filter.Timestamp == nil {
+		err = errors.New("timestamp is required, in the definition or the overrides")
+	}
+	if err != nil {
+		err = errors.Wrap(err, "RunDefinition: Invalid overrides")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if output.Format == "" {
+		output.Format = d.Format
+	}
+	if output.Unit == "" {
+		output.Unit = d.Unit
+	}
+
+	return runReport(
+		ctx, logger, itemWasteColl, reportColl, pricing, catalogColl,
+		claims, showCosts, filter, output, event,
+	)
+}
+
+// ListDefinitions handles "query" events listing the latest version of each
+// saved report-definition, by name. If the event-data has a "name" or
+// "definitionID", all versions of that definition are listed, newest first.
+// Definitions limited to stores the claims don't allow are left out.
+func ListDefinitions(
+	logger tlog.Logger,
+	defColl *mongo.Collection,
+	claims *auth.Claims,
+	event *model.Event,
+) *model.KafkaResponse {
+	if defColl == nil {
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         errDefinitionsDisabled.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	ref := definition.Ref{}
+	err := json.Unmarshal(event.Data, &ref)
+	if err != nil {
+		err = errors.Wrap(err, "ListDefinitions: Error while unmarshalling Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, ref)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	var definitions []*definition.Definition
+	if ref.Validate() == nil {
+		definitions, err = definition.Versions(defColl, ref)
+	} else {
+		definitions, err = definition.List(defColl)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "ListDefinitions: Error listing definitions")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, ref)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	allowed := make([]*definition.Definition, 0, len(definitions))
+	for _, d := range definitions {
+		if allowsDefinition(claims, d) {
+			allowed = append(allowed, d)
+		}
+	}
+
+	result, err := json.Marshal(allowed)
+	if err != nil {
+		err = errors.Wrap(err, "ListDefinitions: Error marshalling definitions")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		})
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
@@ -6,3 +6,11 @@ const InternalError = 2
 // DatabaseError is when some operation related to Database, such as insert or find,
 // goes wrong and the task cannot proceed.
 const DatabaseError = 3
+
+// InvalidRequestError is when the event-data asks for something the service
+// does not support, such as an unknown output-format.
+const InvalidRequestError = 4
+
+// UnauthorizedError is when the caller's claims do not allow the request,
+// such as when asking for stores the caller has no access to.
+const UnauthorizedError = 5
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// ForecastAction is the ServiceAction for the forecast report.
+const ForecastAction = "Forecast"
+
+// Forecast handles "query" events for the forecast report, having the
+// expected waste per SKU for the next days, with confidence-bands.
+// The history is limited to the stores allowed by the claims.
+// The report is not stored.
+func Forecast(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	claims *auth.Claims,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format: `{"days":7,"sku":{"$in":["<sku>"]}}`
+	// Optional `"storeID":{"$in":["<store>"]}` filters the history, and `"groupByStore"`,
+	// `"historyDays"`, `"confidence"` and `"asOf"` tune the forecast.
+	// An optional `"format"` of "json" (default) or "csv", and `"unit"` as for Query.
+	params := report.ForecastParams{}
+	err := json.Unmarshal(event.Data, &params)
+	if err != nil {
+		err = errors.Wrap(err, "Forecast: Error while unmarshalling Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	output := report.OutputParams{}
+	err = json.Unmarshal(event.Data, &output)
+	if err != nil {
+		err = errors.Wrap(err, "Forecast: Error while unmarshalling Event-data - OutputParams")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if !report.ValidDataFormat(output.Format) || !report.ValidUnit(output.Unit) {
+		err = errors.Errorf(
+			"Forecast: Unsupported output-format or weight-unit: %s, %s", output.Format, output.Unit,
+		)
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = params.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "Forecast: Invalid params")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = scopeStores(claims, &params.StoreID)
+	if err != nil {
+		err = errors.Wrap(err, "Forecast: Error scoping report to claims")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	_, forecastSpan := tracing.Tracer().Start(ctx, "mongo.forecast")
+	results, err := report.ForecastReport(params, itemWasteColl)
+	if err != nil {
+		tracing.RecordError(forecastSpan, err)
+	}
+	forecastSpan.End()
+	if err != nil {
+		err = errors.Wrap(err, "Forecast: Error forecasting waste")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	// The unit is already validated, so this can't fail
+	results, _ = report.ForecastInUnit(results, output.Unit)
+
+	result, err := report.MarshalForecast(results, output.Format)
+	if err != nil {
+		err = errors.Wrap(err, "Forecast: Error marshalling forecast")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/agg-itemwaste-report/wastepb"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// claimsMetadata is the gRPC-metadata key carrying the caller's claims as
+// JSON, such as: {"sub": "<user>", "roles": ["staff"], "stores": ["store-1"]}
+const claimsMetadata = "claims"
+
+// grpcServer implements wastepb.ItemWasteReportServer using the same
+// report-functions as query-events. Calls are authorized and scoped by
+// the claims in the claimsMetadata, same as query-events.
+type grpcServer struct {
+	logger        tlog.Logger
+	policy        auth.Policy
+	requireClaims bool
+	itemWasteColl *mongo.Collection
+	reportColl    *mongo.Collection
+	pricing       report.Pricing
+	catalogColl   *mongo.Collection
+}
+
+// newGRPCServer creates a gRPC server with the ItemWasteReport service registered.
+func newGRPCServer(
+	logger tlog.Logger,
+	policy auth.Policy,
+	requireClaims bool,
+	itemWasteColl *mongo.Collection,
+	reportColl *mongo.Collection,
+	pricing report.Pricing,
+	catalogColl *mongo.Collection,
+) *grpc.Server {
+	server := grpc.NewServer()
+	wastepb.RegisterItemWasteReportServer(server, &grpcServer{
+		logger:        logger,
+		policy:        policy,
+		requireClaims: requireClaims,
+		itemWasteColl: itemWasteColl,
+		reportColl:    reportColl,
+		pricing:       pricing,
+		catalogColl:   catalogColl,
+	})
+	return server
+}
+
+func (s *grpcServer) GenerateReport(
+	ctx context.Context,
+	params *wastepb.WasteItemParams,
+) (*wastepb.WasteReport, error) {
+	p, decision, err := s.reportParams(ctx, "GenerateReport", params)
+	if err != nil {
+		return nil, err
+	}
+
+	wasteReport, err := report.GenerateReport(
+		ctx, p, s.itemWasteColl, s.reportColl, s.pricing, s.catalogColl,
+	)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !decision.Has(auth.PermissionCost) {
+		wasteReport = wasteReport.WithoutCosts()
+	}
+	return reportToProto(wasteReport), nil
+}
+
+// StreamReport sends each result as it is read from the aggregation, with
+// the reportID in the header. The report is stored once all results are sent.
+func (s *grpcServer) StreamReport(
+	params *wastepb.WasteItemParams,
+	stream wastepb.ItemWasteReport_StreamReportServer,
+) error {
+	p, decision, err := s.reportParams(stream.Context(), "StreamReport", params)
+	if err != nil {
+		return err
+	}
+
+	_, err = report.StreamReport(
+		stream.Context(), p, s.itemWasteColl, s.reportColl, s.pricing, s.catalogColl,
+		&resultStream{
+			stream:    stream,
+			showCosts: decision.Has(auth.PermissionCost),
+		},
+	)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// resultStream sends the results of report.StreamReport on the gRPC-stream,
+// having cost-fields removed unless showCosts is set.
+type resultStream struct {
+	stream    wastepb.ItemWasteReport_StreamReportServer
+	showCosts bool
+}
+
+func (rs *resultStream) Start(reportID uuuid.UUID) error {
+	err := rs.stream.SendHeader(metadata.Pairs("report-id", reportID.String()))
+	if err != nil {
+		return errors.Wrap(err, "StreamReport: Error sending header")
+	}
+	return nil
+}
+
+func (rs *resultStream) Send(r report.ReportResult) error {
+	if !rs.showCosts {
+		r = r.WithoutCosts()
+	}
+	err := rs.stream.Send(resultToProto(r))
+	if err != nil {
+		return errors.Wrap(err, "StreamReport: Error sending result")
+	}
+	return nil
+}
+
+func (s *grpcServer) GetReport(
+	ctx context.Context,
+	req *wastepb.GetReportRequest,
+) (*wastepb.WasteReport, error) {
+	claims, decision, err := s.authorize(ctx, "GetReport", auth.PermissionRender)
+	if err != nil {
+		return nil, err
+	}
+
+	reportID, err := uuuid.FromString(req.ReportId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid reportID")
+	}
+
+	wasteReport, err := report.FindReport(reportID, s.reportColl)
+	if err != nil {
+		if report.IsNotFound(err) {
+			return nil, status.Error(codes.NotFound, "report not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	err = checkReportScope(claims, wasteReport)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if !decision.Has(auth.PermissionCost) {
+		wasteReport = wasteReport.WithoutCosts()
+	}
+	return reportToProto(wasteReport), nil
+}
+
+// reportParams authorizes the call for generating a report, and returns
+// the validated params limited to the stores allowed by the claims.
+// The returned error is a gRPC-status.
+func (s *grpcServer) reportParams(
+	ctx context.Context,
+	method string,
+	params *wastepb.WasteItemParams,
+) (report.WasteItemParams, *auth.Decision, error) {
+	claims, decision, err := s.authorize(ctx, method, auth.PermissionQuery)
+	if err != nil {
+		return report.WasteItemParams{}, nil, err
+	}
+
+	p := paramsFromProto(params)
+	err = p.Validate()
+	if err != nil {
+		return p, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	err = scopeParams(claims, &p)
+	if err != nil {
+		return p, nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return p, decision, nil
+}
+
+// authorize reads the claims from the call's claimsMetadata, and checks them
+// against the policy for the Permission. The returned error is a gRPC-status.
+func (s *grpcServer) authorize(
+	ctx context.Context,
+	method string,
+	perm auth.Permission,
+) (*auth.Claims, *auth.Decision, error) {
+	var claims *auth.Claims
+	var err error
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(claimsMetadata); len(values) > 0 {
+		claims, err = auth.FromJSON([]byte(values[0]))
+	}
+	if err == nil {
+		claims, err = callerClaims(claims, s.requireClaims)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "Error reading claims from metadata")
+		return nil, nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	decision, err := authorizeClaims(
+		s.logger, s.policy, claims, perm, fmt.Sprintf("gRPC %s", method),
+	)
+	if err != nil {
+		return nil, nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return claims, decision, nil
+}
+
+func paramsFromProto(params *wastepb.WasteItemParams) report.WasteItemParams {
+	p := report.WasteItemParams{
+		GroupByStore:    params.GetGroupByStore(),
+		GroupByReason:   params.GetGroupByReason(),
+		GroupByDisposal: params.GetGroupByDisposal(),
+	}
+	if params.GetTimestamp() != nil {
+		p.Timestamp = &report.Comparator{
+			Lt: params.Timestamp.Lt,
+			Gt: params.Timestamp.Gt,
+		}
+	}
+	if len(params.GetStoreIds()) > 0 {
+		p.StoreID = &report.InComparator{
+			In: params.StoreIds,
+		}
+	}
+	if len(params.GetReasons()) > 0 {
+		p.Reason = &report.InComparator{
+			In: params.Reasons,
+		}
+	}
+	if len(params.GetDisposals()) > 0 {
+		p.Disposal = &report.InComparator{
+			In: params.Disposals,
+		}
+	}
+	if len(params.GetSkus()) > 0 {
+		p.SKU = &report.InComparator{
+			In: params.Skus,
+		}
+	}
+	if params.GetCategory() != nil {
+		p.Category = &report.CategoryPath{
+			Department:  params.Category.Department,
+			Category:    params.Category.Category,
+			Subcategory: params.Category.Subcategory,
+		}
+	}
+	p.RollUp = params.GetRollUp()
+	return p
+}
+
+func resultToProto(r report.ReportResult) *wastepb.ReportResult {
+	return &wastepb.ReportResult{
+		Department:  r.Department,
+		Category:    r.Category,
+		Subcategory: r.Subcategory,
+		Subtotal:    r.Subtotal,
+		StoreId:     r.StoreID,
+		Reason:      r.Reason,
+		Disposal:    r.Disposal,
+		Sku:         r.SKU,
+		Name:        r.Name,
+		WasteWeight: r.WasteWeight,
+		TotalWeight: r.TotalWeight,
+		Unit:        r.Unit,
+		UnitCost:    r.UnitCost,
+		WasteValue:  r.WasteValue,
+		TotalValue:  r.TotalValue,
+		Currency:    r.Currency,
+	}
+}
+
+func reportToProto(wasteReport *report.WasteReport) *wastepb.WasteReport {
+	pr := &wastepb.WasteReport{
+		ReportId: wasteReport.ReportID.String(),
+		SearchQuery: &wastepb.WasteItemParams{
+			StoreIds:        wasteReport.SearchQuery.Stores(),
+			GroupByStore:    wasteReport.SearchQuery.GroupByStore,
+			GroupByReason:   wasteReport.SearchQuery.GroupByReason,
+			GroupByDisposal: wasteReport.SearchQuery.GroupByDisposal,
+			RollUp:          wasteReport.SearchQuery.RollUp,
+		},
+	}
+	if wasteReport.SearchQuery.SKU != nil {
+		pr.SearchQuery.Skus = wasteReport.SearchQuery.SKU.In
+	}
+	if c := wasteReport.SearchQuery.Category; c != nil {
+		pr.SearchQuery.Category = &wastepb.CategoryPath{
+			Department:  c.Department,
+			Category:    c.Category,
+			Subcategory: c.Subcategory,
+		}
+	}
+	if wasteReport.SearchQuery.Reason != nil {
+		pr.SearchQuery.Reasons = wasteReport.SearchQuery.Reason.In
+	}
+	if wasteReport.SearchQuery.Disposal != nil {
+		pr.SearchQuery.Disposals = wasteReport.SearchQuery.Disposal.In
+	}
+	ts := wasteReport.SearchQuery.Timestamp
+	if ts != nil {
+		pr.SearchQuery.Timestamp = &wastepb.Comparator{
+			Lt: ts.Lt,
+			Gt: ts.Gt,
+		}
+	}
+	for _, r := range wasteReport.ReportResult {
+		pr.ReportResult = append(pr.ReportResult, resultToProto(r))
+	}
+	return pr
+}
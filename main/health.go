@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// healthCheckTimeout is the timeout for each readiness-check.
+const healthCheckTimeout = 3 * time.Second
+
+// readyCheck is a named readiness-check. The service is not ready if
+// any readiness-check returns an error.
+type readyCheck struct {
+	name  string
+	check func() error
+}
+
+// health tracks the service's readiness and liveness for the admin-server.
+// The service is ready once started and all readiness-checks pass.
+// The service is live as long as the main-loop calls Beat within liveTimeout
+// (or while the service is still starting).
+type health struct {
+	checksLock sync.RWMutex
+	checks     []readyCheck
+
+	started     int32
+	heartbeat   int64
+	liveTimeout time.Duration
+}
+
+func newHealth(liveTimeout time.Duration) *health {
+	return &health{
+		heartbeat:   time.Now().UnixNano(),
+		liveTimeout: liveTimeout,
+	}
+}
+
+// AddReadyCheck adds a readiness-check.
+func (h *health) AddReadyCheck(name string, check func() error) {
+	h.checksLock.Lock()
+	defer h.checksLock.Unlock()
+	h.checks = append(h.checks, readyCheck{
+		name:  name,
+		check: check,
+	})
+}
+
+// MarkStarted marks the service as started, after which the liveness is
+// decided by heartbeats.
+func (h *health) MarkStarted() {
+	h.Beat()
+	atomic.StoreInt32(&h.started, 1)
+}
+
+// Beat records progress of the main-loop.
+func (h *health) Beat() {
+	atomic.StoreInt64(&h.heartbeat, time.Now().UnixNano())
+}
+
+func (h *health) isStarted() bool {
+	return atomic.LoadInt32(&h.started) == 1
+}
+
+func (h *health) handleLive(w http.ResponseWriter, r *http.Request) {
+	lastBeat := time.Unix(0, atomic.LoadInt64(&h.heartbeat))
+	sinceBeat := time.Since(lastBeat)
+
+	if h.isStarted() && sinceBeat > h.liveTimeout {
+		writeHTTPJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status": "stalled",
+			"error":  "main-loop has not progressed for " + sinceBeat.String(),
+		})
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, map[string]string{
+		"status": "live",
+	})
+}
+
+func (h *health) handleReady(w http.ResponseWriter, r *http.Request) {
+	if !h.isStarted() {
+		writeHTTPJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "starting",
+		})
+		return
+	}
+
+	h.checksLock.RLock()
+	checks := append([]readyCheck{}, h.checks...)
+	h.checksLock.RUnlock()
+
+	status := http.StatusOK
+	results := map[string]string{}
+	for _, c := range checks {
+		err := c.check()
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			results[c.name] = err.Error()
+			continue
+		}
+		results[c.name] = "ok"
+	}
+
+	statusText := "ready"
+	if status != http.StatusOK {
+		statusText = "unready"
+	}
+	writeHTTPJSON(w, status, map[string]interface{}{
+		"status": statusText,
+		"checks": results,
+	})
+}
+
+// mongoPingCheck returns a readiness-check verifying that the
+// Mongo-server is reachable.
+func mongoPingCheck(client *mongo.Client) func() error {
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		defer cancel()
+
+		err := client.DriverClient().Ping(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "Error pinging Mongo")
+		}
+		return nil
+	}
+}
+
+// collectionCheck returns a readiness-check verifying that the collection exists.
+func collectionCheck(coll *mongo.Collection) func() error {
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		defer cancel()
+
+		cur, err := coll.Connection.Client.Database(coll.Database).ListCollections(
+			ctx,
+			bson.NewDocument(
+				bson.EC.String("name", coll.Name),
+			),
+		)
+		if err != nil {
+			return errors.Wrap(err, "Error listing collections")
+		}
+		defer cur.Close(ctx)
+
+		if !cur.Next(ctx) {
+			return errors.Errorf("Collection %s not found", coll.Name)
+		}
+		return nil
+	}
+}
+
+// kafkaGroupsCheck returns a readiness-check verifying that the consumer-groups
+// are stable, and have this instance's consumers, having the clientID, as members.
+// So an instance dropped out of a group is not ready, even if the group is.
+func kafkaGroupsCheck(brokers []string, clientID string, groups ...string) func() error {
+	var (
+		client sarama.Client
+		lock   sync.Mutex
+	)
+
+	return func() error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if client == nil || client.Closed() {
+			config := sarama.NewConfig()
+			config.Net.DialTimeout = healthCheckTimeout
+			config.Net.ReadTimeout = healthCheckTimeout
+			config.Net.WriteTimeout = healthCheckTimeout
+
+			var err error
+			client, err = sarama.NewClient(brokers, config)
+			if err != nil {
+				return errors.Wrap(err, "Error creating Kafka-client")
+			}
+		}
+
+		err := describeGroups(client, clientID, groups)
+		if err != nil {
+			// Recreate the client on next check, in case the
+			// coordinators or brokers changed.
+			client.Close()
+			return err
+		}
+		return nil
+	}
+}
+
+func describeGroups(client sarama.Client, clientID string, groups []string) error {
+	for _, group := range groups {
+		coordinator, err := client.Coordinator(group)
+		if err != nil {
+			return errors.Wrapf(err, "Error getting coordinator for group %s", group)
+		}
+		resp, err := coordinator.DescribeGroups(&sarama.DescribeGroupsRequest{
+			Groups: []string{group},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Error describing group %s", group)
+		}
+
+		for _, desc := range resp.Groups {
+			if desc.Err != sarama.ErrNoError {
+				return errors.Wrapf(desc.Err, "Error describing group %s", group)
+			}
+			err = checkGroupMember(desc, clientID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkGroupMember checks that the described consumer-group is stable, and
+// has a member with the clientID.
+func checkGroupMember(desc *sarama.GroupDescription, clientID string) error {
+	if desc.State != "Stable" {
+		return errors.Errorf("Group %s is %s", desc.GroupId, desc.State)
+	}
+	for _, member := range desc.Members {
+		if member.ClientId == clientID {
+			return nil
+		}
+	}
+	return errors.Errorf(
+		"Group %s has no member with client-ID %s, out of %d members",
+		desc.GroupId, clientID, len(desc.Members),
+	)
+}
+
+// contextCheck returns a readiness-check that fails once ctx is done.
+func contextCheck(ctx context.Context) func() error {
+	return func() error {
+		err := ctx.Err()
+		if err != nil {
+			return errors.Wrap(err, "Routines stopped")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
+)
+
+// defaultListLimit is the number of reports listed if no limit is specified.
+const defaultListLimit = 50
+
+// claimsHeader is the HTTP-header carrying the caller's claims as JSON,
+// such as: {"sub": "<user>", "roles": ["staff"], "stores": ["store-1"]}
+const claimsHeader = "X-Claims"
+
+// httpAPI serves the report-operations over HTTP, as an alternative to
+// query-events. Routes:
+//  POST /reports       runs a new report, body is same as query-event data
+//  GET  /reports       lists stored reports, newest first (?limit=&skip=)
+//  GET  /reports/<id>  fetches a stored report
+// The response-format can be chosen using "?format=" or the Accept header,
+// and defaults to JSON. Requests are authorized and scoped by the claims in
+// the claimsHeader, same as query-events.
+type httpAPI struct {
+	logger        tlog.Logger
+	policy        auth.Policy
+	requireClaims bool
+	itemWasteColl *mongo.Collection
+	reportColl    *mongo.Collection
+	pricing       report.Pricing
+	catalogColl   *mongo.Collection
+}
+
+// newHTTPAPI creates the http.Handler for report-operations.
+func newHTTPAPI(
+	logger tlog.Logger,
+	policy auth.Policy,
+	requireClaims bool,
+	itemWasteColl *mongo.Collection,
+	reportColl *mongo.Collection,
+	pricing report.Pricing,
+	catalogColl *mongo.Collection,
+) http.Handler {
+	api := &httpAPI{
+		logger:        logger,
+		policy:        policy,
+		requireClaims: requireClaims,
+		itemWasteColl: itemWasteColl,
+		reportColl:    reportColl,
+		pricing:       pricing,
+		catalogColl:   catalogColl,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reports", api.handleReports)
+	mux.HandleFunc("/reports/", api.handleReport)
+	return mux
+}
+
+func (a *httpAPI) handleReports(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.listReports(w, r)
+	case http.MethodPost:
+		a.runReport(w, r)
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (a *httpAPI) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	claims, decision := a.authorize(w, r, auth.PermissionRender)
+	if decision == nil {
+		return
+	}
+
+	idStr := strings.TrimPrefix(r.URL.Path, "/reports/")
+	reportID, err := uuuid.FromString(idStr)
+	if err != nil {
+		err = errors.Wrap(err, "Error parsing reportID")
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	wasteReport, err := report.FindReport(reportID, a.reportColl)
+	if err != nil {
+		if report.IsNotFound(err) {
+			writeHTTPError(w, http.StatusNotFound, errors.New("report not found"))
+			return
+		}
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = checkReportScope(claims, wasteReport)
+	if err != nil {
+		writeHTTPError(w, http.StatusForbidden, err)
+		return
+	}
+	if !decision.Has(auth.PermissionCost) {
+		wasteReport = wasteReport.WithoutCosts()
+	}
+	writeHTTPReport(w, r, http.StatusOK, wasteReport)
+}
+
+func (a *httpAPI) runReport(w http.ResponseWriter, r *http.Request) {
+	claims, decision := a.authorize(w, r, auth.PermissionQuery)
+	if decision == nil {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = errors.Wrap(err, "Error reading request-body")
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	filter := report.WasteItemParams{}
+	err = json.Unmarshal(body, &filter)
+	if err != nil {
+		err = errors.Wrap(err, "Error unmarshalling request-body")
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = filter.Validate()
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = scopeParams(claims, &filter)
+	if err != nil {
+		writeHTTPError(w, http.StatusForbidden, err)
+		return
+	}
+
+	wasteReport, err := report.GenerateReport(
+		r.Context(), filter, a.itemWasteColl, a.reportColl, a.pricing, a.catalogColl,
+	)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !decision.Has(auth.PermissionCost) {
+		wasteReport = wasteReport.WithoutCosts()
+	}
+	writeHTTPReport(w, r, http.StatusCreated, wasteReport)
+}
+
+func (a *httpAPI) listReports(w http.ResponseWriter, r *http.Request) {
+	claims, decision := a.authorize(w, r, auth.PermissionQuery)
+	if decision == nil {
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultListLimit)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	skip, err := queryInt(r, "skip", 0)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Only the reports for stores the claims allow are listed
+	reports, err := report.ListReports(a.reportColl, scopedStores(claims), limit, skip)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !decision.Has(auth.PermissionCost) {
+		for i, wasteReport := range reports {
+			reports[i] = wasteReport.WithoutCosts()
+		}
+	}
+
+	format := responseFormat(r)
+	switch format {
+	case report.FormatJSON:
+		writeHTTPJSON(w, http.StatusOK, reports)
+	case report.FormatCSV:
+		buf := &bytes.Buffer{}
+		err = report.WriteReportListCSV(buf, reports)
+		if err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeHTTPBody(w, http.StatusOK, report.ContentType(format), buf.Bytes())
+	default:
+		err = errors.Errorf("Reports can only be listed as json or csv, got: %s", format)
+		writeHTTPError(w, http.StatusNotAcceptable, err)
+	}
+}
+
+// authorize reads the claims from the request's claimsHeader, and checks
+// them against the policy for the Permission. If the request is not
+// authorized, the error-response is written and the Decision is nil.
+func (a *httpAPI) authorize(
+	w http.ResponseWriter,
+	r *http.Request,
+	perm auth.Permission,
+) (*auth.Claims, *auth.Decision) {
+	var claims *auth.Claims
+	var err error
+	if header := r.Header.Get(claimsHeader); header != "" {
+		claims, err = auth.FromJSON([]byte(header))
+	}
+	if err == nil {
+		claims, err = callerClaims(claims, a.requireClaims)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "Error reading claims from request")
+		writeHTTPError(w, http.StatusUnauthorized, err)
+		return nil, nil
+	}
+
+	decision, err := authorizeClaims(
+		a.logger, a.policy, claims, perm,
+		fmt.Sprintf("HTTP %s %s", r.Method, r.URL.Path),
+	)
+	if err != nil {
+		writeHTTPError(w, http.StatusForbidden, err)
+		return nil, nil
+	}
+	return claims, decision
+}
+
+// responseFormat returns the output-format requested using "?format=",
+// or the Accept header.
+func responseFormat(r *http.Request) string {
+	format := r.URL.Query().Get("format")
+	if format != "" {
+		return format
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		return report.FormatCSV
+	}
+	return report.FormatJSON
+}
+
+func queryInt(r *http.Request, key string, defaultValue int64) (int64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || i < 0 {
+		return 0, errors.Errorf("Query-param %s must be a non-negative integer", key)
+	}
+	return i, nil
+}
+
+// writeHTTPReport writes the WasteReport in the requested format.
+// Unlike query-event responses, JSON-responses include the whole WasteReport,
+// so the reportID is available to the client.
+func writeHTTPReport(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	wasteReport *report.WasteReport,
+) {
+	format := responseFormat(r)
+	if !report.ValidFormat(format) {
+		err := errors.Errorf("Unsupported output-format: %s", format)
+		writeHTTPError(w, http.StatusNotAcceptable, err)
+		return
+	}
+	wasteReport, err := wasteReport.InUnit(r.URL.Query().Get("unit"))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if format == report.FormatJSON {
+		writeHTTPJSON(w, status, wasteReport)
+		return
+	}
+
+	body, err := report.MarshalReport(wasteReport, format)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPBody(w, status, report.ContentType(format), body)
+}
+
+func writeHTTPJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling response")
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPBody(w, status, report.ContentType(report.FormatJSON), body)
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, err error) {
+	log.Println(err)
+	body, _ := json.Marshal(map[string]string{
+		"error": err.Error(),
+	})
+	writeHTTPBody(w, status, report.ContentType(report.FormatJSON), body)
+}
+
+func writeHTTPBody(w http.ResponseWriter, status int, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err := w.Write(body)
+	if err != nil {
+		err = errors.Wrap(err, "Error writing HTTP-response")
+		log.Println(err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// LotTraceAction is the ServiceAction for the lot-traceability report.
+const LotTraceAction = "LotTrace"
+
+// LotTrace handles "query" events for the lot-traceability report, having the
+// waste-events against each lot, joined with the lot's supplier and receipt
+// from lotColl (if not nil). The waste-events are limited to the stores
+// allowed by the claims. The report is not stored.
+func LotTrace(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	lotColl *mongo.Collection,
+	claims *auth.Claims,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format: `{"lot":{"$in":["<lot>"]}}`, or have both
+	// timestamp bounds instead: `{"timestamp":{"$gt":1529315000,"$lt":1551997372}}`
+	// Optional `"supplier":{"$in":["<supplier>"]}` and `"storeID":{"$in":["<store>"]}` filter the lots.
+	// An optional `"format"` of "json" (default) or "csv", and `"unit"` as for Query.
+	params := report.LotTraceParams{}
+	err := json.Unmarshal(event.Data, &params)
+	if err != nil {
+		err = errors.Wrap(err, "LotTrace: Error while unmarshalling Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	output := report.OutputParams{}
+	err = json.Unmarshal(event.Data, &output)
+	if err != nil {
+		err = errors.Wrap(err, "LotTrace: Error while unmarshalling Event-data - OutputParams")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if !report.ValidDataFormat(output.Format) || !report.ValidUnit(output.Unit) {
+		err = errors.Errorf(
+			"LotTrace: Unsupported output-format or weight-unit: %s, %s", output.Format, output.Unit,
+		)
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	err = params.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "LotTrace: Invalid params")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if params.Supplier != nil && lotColl == nil {
+		err = errors.New("LotTrace: Filtering by supplier requires the lot-metadata collection")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = scopeLotParams(claims, &params)
+	if err != nil {
+		err = errors.Wrap(err, "LotTrace: Error scoping report to claims")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	_, traceSpan := tracing.Tracer().Start(ctx, "mongo.lot_traces")
+	traces, err := report.LotTraces(params, itemWasteColl, lotColl)
+	if err != nil {
+		tracing.RecordError(traceSpan, err)
+	}
+	traceSpan.End()
+	if err != nil {
+		err = errors.Wrap(err, "LotTrace: Error getting lot-traces")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	// The unit is already validated, so this can't fail
+	traces, _ = report.LotTracesInUnit(traces, output.Unit)
+
+	result, err := report.MarshalLotTraces(traces, output.Format)
+	if err != nil {
+		err = errors.Wrap(err, "LotTrace: Error marshalling lot-traces")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
@@ -1,52 +1,36 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/TerrexTech/agg-itemwaste-report/alert"
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/config"
+	"github.com/TerrexTech/agg-itemwaste-report/definition"
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
 	"github.com/TerrexTech/agg-itemwaste-report/report"
-	"github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/agg-itemwaste-report/schedule"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
 	"github.com/TerrexTech/go-eventspoll/poll"
-
+	"github.com/TerrexTech/go-eventstore-models/model"
 	"github.com/TerrexTech/go-kafkautils/kafka"
 	tlog "github.com/TerrexTech/go-logtransport/log"
-	"github.com/joho/godotenv"
+	"github.com/TerrexTech/go-mongoutils/mongo"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var aggregateID int8 = 12
 
-func validateEnv() error {
-	missingVar, err := commonutil.ValidateEnv(
-		"KAFKA_BROKERS",
-
-		"KAFKA_CONSUMER_EVENT_GROUP",
-		"KAFKA_CONSUMER_EVENT_QUERY_GROUP",
-
-		"KAFKA_CONSUMER_EVENT_TOPIC",
-		"KAFKA_CONSUMER_EVENT_QUERY_TOPIC",
-		"KAFKA_PRODUCER_EVENT_QUERY_TOPIC",
-		"KAFKA_PRODUCER_RESPONSE_TOPIC",
-
-		"MONGO_HOSTS",
-		"MONGO_DATABASE",
-		"MONGO_AGG_COLLECTION",
-		"MONGO_META_COLLECTION",
-
-		"MONGO_CONNECTION_TIMEOUT_MS",
-		"MONGO_RESOURCE_TIMEOUT_MS",
-	)
-
-	if err != nil {
-		err = errors.Wrapf(
-			err,
-			"Env-var %s is required for testing, but is not set", missingVar,
-		)
-		return err
-	}
-	return nil
-}
-
 // func createData(numIterations int, repCollection *mongo.Collection) {
 // 	newReport := []report.WasteItem{}
 // 	for i := 0; i < numIterations; i++ {
@@ -66,40 +50,97 @@ func validateEnv() error {
 // }
 
 func main() {
-	log.Println("Reading environment file")
-	err := godotenv.Load("./.env")
+	configFile := flag.String(
+		"config", os.Getenv("CONFIG_FILE"), "optional YAML config-file, env-vars override its values",
+	)
+	printConfig := flag.Bool("print-config", false, "print the loaded config (redacted) and exit")
+	flag.Parse()
+
+	log.Println("Reading configuration")
+	cfg, err := config.Load(*configFile, "./.env")
 	if err != nil {
-		err = errors.Wrap(err,
-			".env file not found, env-vars will be read as set in environment",
-		)
-		log.Println(err)
+		err = errors.Wrap(err, "Error loading config")
+		log.Fatalln(err)
+	}
+	cfgDump, err := cfg.Dump()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *printConfig {
+		fmt.Print(cfgDump)
+	}
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *printConfig {
+		return
 	}
 
-	err = validateEnv()
+	policy, err := loadPolicy(cfg.Scoping)
 	if err != nil {
+		err = errors.Wrap(err, "Error loading authorization-policy")
 		log.Fatalln(err)
 	}
 
-	aggCollection := os.Getenv("MONGO_AGG_COLLECTION")
-	reportCollection := os.Getenv("MONGO_REPORT_COLLECTION")
-	brokersStr := os.Getenv("KAFKA_BROKERS")
-	brokers := *commonutil.ParseHosts(brokersStr)
-	logTopic := os.Getenv("KAFKA_LOG_PRODUCER_TOPIC")
-	serviceName := os.Getenv("SERVICE_NAME")
+	brokers := cfg.Kafka.Brokers
+	serviceName := cfg.ServiceName
 
 	log.Println("=================")
 	log.Println(serviceName)
+	log.Printf("Config:\n%s", cfgDump)
 
 	prodConfig := &kafka.ProducerConfig{
 		KafkaBrokers: brokers,
 	}
-	logger, err := tlog.Init(nil, serviceName, prodConfig, logTopic)
+	logger, err := tlog.Init(nil, serviceName, prodConfig, cfg.Kafka.LogProducerTopic)
 	if err != nil {
 		err = errors.Wrap(err, "Error initializing Logger")
 		log.Fatalln(err)
 	}
 
-	kc, err := loadKafkaConfig()
+	serviceHealth := newHealth(
+		time.Duration(cfg.Health.LivenessTimeoutSec) * time.Second,
+	)
+
+	// The admin-server is started before connecting to Kafka and Mongo,
+	// so the probes report the service as live but not ready while starting.
+	adminAddr := cfg.Servers.AdminListenAddr
+	if adminAddr != "" {
+		go func() {
+			log.Println("Starting admin-server on", adminAddr)
+			err := http.ListenAndServe(adminAddr, newAdminMux(serviceHealth))
+			err = errors.Wrap(err, "Admin-server stopped")
+			logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}()
+	}
+
+	// Spans are not flushed on exit, since the service only exits on fatal errors
+	_, err = tracing.Init(tracing.Config{
+		ServiceName: serviceName,
+		Exporter:    cfg.Tracing.Exporter,
+		FilePath:    cfg.Tracing.FilePath,
+	})
+	if err != nil {
+		err = errors.Wrap(err, "Error initializing tracing")
+		logger.F(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		})
+	}
+
+	clientID, err := consumerClientID(serviceName)
+	if err != nil {
+		err = errors.Wrap(err, "Error creating Kafka client-ID")
+		logger.F(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		})
+	}
+	kc, err := loadKafkaConfig(cfg.Kafka, clientID)
 	if err != nil {
 		err = errors.Wrap(err, "Error in KafkaConfig")
 		logger.F(tlog.Entry{
@@ -108,8 +149,19 @@ func main() {
 		}, kc)
 	}
 
+	mongoConn, err := newMongoConnection(cfg.Mongo)
+	if err != nil {
+		err = errors.Wrap(err, "Error in MongoConnection")
+		logger.F(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		})
+	}
+
 	//This is for report collection
-	mc, err := loadMongoConfig(reportCollection, &report.WasteReport{})
+	mc, err := loadMongoConfig(
+		cfg.Mongo, mongoConn, cfg.Mongo.ReportCollection, &report.WasteReport{},
+	)
 	if err != nil {
 		err = errors.Wrap(err, "Error in MongoConfig - trying to load WasteReport - mongoCollection")
 		logger.F(tlog.Entry{
@@ -135,16 +187,9 @@ func main() {
 		}, eventPoll)
 	}
 
-	client, err := CreateClient()
-	if err != nil {
-		err = errors.Wrap(err, "Error in MongoClient")
-		logger.F(tlog.Entry{
-			Description: err.Error(),
-			ErrorCode:   1,
-		}, client)
-	}
-
-	itemWasteColl, err := CreateCollection(client, aggCollection, &report.WasteItem{})
+	itemWasteColl, err := createItemWasteCollection(
+		mongoConn, cfg.Mongo.Database, cfg.Mongo.AggCollection, &report.WasteItem{},
+	)
 	if err != nil {
 		err = errors.Wrap(err, "Error in MongoCollection- itemWasteColl")
 		logger.F(tlog.Entry{
@@ -153,8 +198,233 @@ func main() {
 		}, itemWasteColl)
 	}
 
+	pricing := report.Pricing{
+		Currency: cfg.Pricing.Currency,
+	}
+	if cfg.Mongo.PriceCollection != "" {
+		pricing.Catalog, err = createPriceCollection(
+			mongoConn, cfg.Mongo.Database, cfg.Mongo.PriceCollection, &report.Price{},
+		)
+		if err != nil {
+			err = errors.Wrap(err, "Error in MongoCollection- priceColl")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+	}
+
+	var catalogColl *mongo.Collection
+	if cfg.Mongo.CatalogCollection != "" {
+		catalogColl, err = createCatalogCollection(
+			mongoConn, cfg.Mongo.Database, cfg.Mongo.CatalogCollection, &report.Product{},
+		)
+		if err != nil {
+			err = errors.Wrap(err, "Error in MongoCollection- catalogColl")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+	}
+
+	var lotColl *mongo.Collection
+	if cfg.Mongo.LotCollection != "" {
+		lotColl, err = createLotCollection(
+			mongoConn, cfg.Mongo.Database, cfg.Mongo.LotCollection, &report.LotInfo{},
+		)
+		if err != nil {
+			err = errors.Wrap(err, "Error in MongoCollection- lotColl")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+	}
+
+	var schedColl *mongo.Collection
+	if cfg.Mongo.ScheduleCollection != "" {
+		schedColl, err = createScheduleCollection(
+			mongoConn, cfg.Mongo.Database, cfg.Mongo.ScheduleCollection, &schedule.Schedule{},
+		)
+		if err != nil {
+			err = errors.Wrap(err, "Error in MongoCollection- schedColl")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+	}
+
+	var alertColl *mongo.Collection
+	if len(cfg.Alerts.Rules) > 0 {
+		alertColl, err = createAlertCollection(
+			mongoConn, cfg.Mongo.Database, cfg.Mongo.AlertCollection, &alert.Event{},
+		)
+		if err != nil {
+			err = errors.Wrap(err, "Error in MongoCollection- alertColl")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+	}
+
+	var anomalyColl *mongo.Collection
+	if cfg.Anomalies.IntervalSec > 0 {
+		anomalyColl, err = createAnomalyCollection(
+			mongoConn, cfg.Mongo.Database, cfg.Mongo.AnomalyCollection, &report.Anomaly{},
+		)
+		if err != nil {
+			err = errors.Wrap(err, "Error in MongoCollection- anomalyColl")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+	}
+
+	var defColl *mongo.Collection
+	if cfg.Mongo.DefinitionCollection != "" {
+		defColl, err = createDefinitionCollection(
+			mongoConn, cfg.Mongo.Database, cfg.Mongo.DefinitionCollection, &definition.Definition{},
+		)
+		if err != nil {
+			err = errors.Wrap(err, "Error in MongoCollection- defColl")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+	}
+
+	serviceHealth.AddReadyCheck("mongo", mongoPingCheck(mongoConn.Client))
+	serviceHealth.AddReadyCheck("report_collection", collectionCheck(mc.AggCollection))
+	serviceHealth.AddReadyCheck("kafka_consumers", kafkaGroupsCheck(
+		brokers,
+		clientID,
+		kc.EventCons.GroupName,
+		kc.ESQueryResCons.GroupName,
+	))
+	serviceHealth.AddReadyCheck("eventspoll", contextCheck(eventPoll.RoutinesCtx()))
+
+	httpAddr := cfg.Servers.HTTPListenAddr
+	if httpAddr != "" {
+		go func() {
+			log.Println("Starting HTTP API on", httpAddr)
+			api := newHTTPAPI(
+				logger, policy, cfg.Scoping.RequireClaims,
+				itemWasteColl, mc.AggCollection, pricing, catalogColl,
+			)
+			err := http.ListenAndServe(httpAddr, api)
+			err = errors.Wrap(err, "HTTP API stopped")
+			logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}()
+	}
+
+	if cfg.WasteGauges.IntervalSec > 0 {
+		go runWasteGauges(
+			eventPoll.RoutinesCtx(),
+			logger,
+			itemWasteColl,
+			time.Duration(cfg.WasteGauges.IntervalSec)*time.Second,
+			cfg.WasteGauges.MaxSeries,
+		)
+	}
+
+	// The producer is shared by alert and anomaly events, and scheduled reports
+	var eventProducer *kafka.Producer
+	if len(cfg.Alerts.Rules) > 0 || cfg.Anomalies.IntervalSec > 0 || schedColl != nil {
+		eventProducer, err = newEventProducer(logger, brokers)
+		if err != nil {
+			err = errors.Wrap(err, "Error creating event-producer")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+	}
+
+	if len(cfg.Alerts.Rules) > 0 {
+		go runAlerts(
+			eventPoll.RoutinesCtx(),
+			logger,
+			itemWasteColl,
+			alertColl,
+			eventProducer,
+			cfg.Alerts.Topic,
+			time.Duration(cfg.Alerts.IntervalSec)*time.Second,
+			cfg.Alerts.Rules,
+		)
+	}
+
+	if cfg.Anomalies.IntervalSec > 0 {
+		go runAnomalyEvents(
+			eventPoll.RoutinesCtx(),
+			logger,
+			itemWasteColl,
+			anomalyColl,
+			eventProducer,
+			cfg.Anomalies,
+		)
+	}
+
+	if schedColl != nil {
+		go runScheduledReports(
+			eventPoll.RoutinesCtx(),
+			&reportScheduler{
+				logger:        logger,
+				itemWasteColl: itemWasteColl,
+				reportColl:    mc.AggCollection,
+				schedColl:     schedColl,
+				pricing:       pricing,
+				catalogColl:   catalogColl,
+				producer:      eventProducer,
+				topic:         cfg.Schedules.Topic,
+			},
+			time.Duration(cfg.Schedules.IntervalSec)*time.Second,
+		)
+	}
+
+	grpcAddr := cfg.Servers.GRPCListenAddr
+	if grpcAddr != "" {
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			err = errors.Wrap(err, "Error listening on GRPC_LISTEN_ADDR")
+			logger.F(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+		go func() {
+			log.Println("Starting gRPC API on", grpcAddr)
+			server := newGRPCServer(
+				logger, policy, cfg.Scoping.RequireClaims,
+				itemWasteColl, mc.AggCollection, pricing, catalogColl,
+			)
+			err := server.Serve(lis)
+			err = errors.Wrap(err, "gRPC API stopped")
+			logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}()
+	}
+
+	// The heartbeat keeps the service live while idle, as long as
+	// the main-loop is not blocked.
+	heartbeat := time.NewTicker(serviceHealth.liveTimeout / 3)
+	defer heartbeat.Stop()
+	serviceHealth.MarkStarted()
+
 	for {
 		select {
+		case <-heartbeat.C:
+			serviceHealth.Beat()
+
 		case <-eventPoll.RoutinesCtx().Done():
 			err = errors.New("service-context closed")
 			logger.F(tlog.Entry{
@@ -164,6 +434,9 @@ func main() {
 
 		case eventResp := <-eventPoll.Query():
 			go func(eventResp *poll.EventResponse) {
+				metrics.InFlightHandlers.Inc()
+				defer metrics.InFlightHandlers.Dec()
+
 				if eventResp == nil {
 					return
 				}
@@ -176,9 +449,93 @@ func main() {
 					})
 					return
 				}
-				kafkaResp := Query(logger, itemWasteColl, mc.AggCollection, &eventResp.Event)
+				event := &eventResp.Event
+				metrics.QueryEvents.WithLabelValues(event.ServiceAction).Inc()
+
+				ctx, span := tracing.Tracer().Start(
+					tracing.EventContext(context.Background(), event),
+					"query_event "+event.ServiceAction,
+					trace.WithSpanKind(trace.SpanKindConsumer),
+					trace.WithAttributes(tracing.EventAttributes(event)...),
+				)
+				defer span.End()
+
+				var kafkaResp *model.KafkaResponse
+				claims, decision, err := authorizeEvent(
+					logger, policy, cfg.Scoping.RequireClaims, event,
+				)
+				if err != nil {
+					kafkaResp = &model.KafkaResponse{
+						AggregateID:   event.AggregateID,
+						CorrelationID: event.CorrelationID,
+						Error:         err.Error(),
+						ErrorCode:     UnauthorizedError,
+						EventAction:   event.EventAction,
+						ServiceAction: event.ServiceAction,
+						UUID:          event.UUID,
+					}
+				} else {
+					showCosts := decision.Has(auth.PermissionCost)
+					switch event.ServiceAction {
+					case RenderReportAction:
+						kafkaResp = RenderReport(
+							ctx, logger, mc.AggCollection, claims, showCosts, event,
+						)
+					case LotTraceAction:
+						kafkaResp = LotTrace(
+							ctx, logger, itemWasteColl, lotColl, claims, event,
+						)
+					case ShelfLifeAction:
+						kafkaResp = ShelfLife(
+							ctx, logger, itemWasteColl, lotColl, claims, event,
+						)
+					case AnomaliesAction:
+						kafkaResp = Anomalies(
+							ctx, logger, itemWasteColl, claims, event,
+						)
+					case ForecastAction:
+						kafkaResp = Forecast(
+							ctx, logger, itemWasteColl, claims, event,
+						)
+					case SaveScheduleAction:
+						kafkaResp = SaveSchedule(
+							logger, schedColl, claims, showCosts, event,
+						)
+					case DeleteScheduleAction:
+						kafkaResp = DeleteSchedule(logger, schedColl, claims, event)
+					case ListSchedulesAction:
+						kafkaResp = ListSchedules(logger, schedColl, claims, event)
+					case SaveDefinitionAction:
+						kafkaResp = SaveDefinition(logger, defColl, claims, event)
+					case RunDefinitionAction:
+						kafkaResp = RunDefinition(
+							ctx, logger, itemWasteColl, mc.AggCollection, pricing, catalogColl,
+							defColl, claims, showCosts, event,
+						)
+					case ListDefinitionsAction:
+						kafkaResp = ListDefinitions(logger, defColl, claims, event)
+					default:
+						kafkaResp = Query(
+							ctx, logger, itemWasteColl, mc.AggCollection, pricing, catalogColl,
+							claims, showCosts, event,
+						)
+					}
+				}
 				if kafkaResp != nil {
+					span.SetAttributes(attribute.Int("response.error_code", int(kafkaResp.ErrorCode)))
+					if kafkaResp.ErrorCode != 0 {
+						tracing.RecordError(span, errors.New(kafkaResp.Error))
+					}
+					metrics.Responses.WithLabelValues(
+						strconv.Itoa(int(kafkaResp.ErrorCode)),
+					).Inc()
+
+					_, produceSpan := tracing.Tracer().Start(
+						ctx, "kafka.produce_result",
+						trace.WithSpanKind(trace.SpanKindProducer),
+					)
 					eventPoll.ProduceResult() <- kafkaResp
+					produceSpan.End()
 				}
 			}(eventResp)
 		}
@@ -1,25 +1,43 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
 	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
 	"github.com/TerrexTech/go-eventstore-models/model"
 	tlog "github.com/TerrexTech/go-logtransport/log"
 	"github.com/TerrexTech/go-mongoutils/mongo"
-	"github.com/TerrexTech/uuuid"
 	"github.com/pkg/errors"
 )
 
 // Query handles "query" events.
-func Query(logger tlog.Logger, itemWasteColl *mongo.Collection, reportColl *mongo.Collection, event *model.Event) *model.KafkaResponse {
+// The report is limited to the stores allowed by the claims, and priced using
+// pricing. Cost-fields are stored, but only returned if showCosts is set.
+func Query(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	reportColl *mongo.Collection,
+	pricing report.Pricing,
+	catalogColl *mongo.Collection,
+	claims *auth.Claims,
+	showCosts bool,
+	event *model.Event,
+) *model.KafkaResponse {
 	// event.Data should be in this format: `{"timestamp":{"$gt":1529315000},"timestamp":{"$lt":1551997372}}`
+	// An optional `"format"` of "json" (default), "csv" or "xlsx" chooses the format of Result.
+	// An optional `"unit"` of "kg" (default), "lb" or "g" chooses the weight-unit of results.
+	// Optional `"storeID":{"$in":["<store>"]}` and `"groupByStore":true` filter and group by store.
+	// Similarly, "reason" and "groupByReason", and "disposal" and "groupByDisposal".
+	// An optional `"category":{"department":"<dept>"}` drills-down to a category, and
+	// `"rollUp":"<level>"` sums results at a hierarchy-level with subtotals.
 
 	filter := report.WasteItemParams{}
 
-	var reportAgg []report.ReportResult
-
 	err := json.Unmarshal(event.Data, &filter)
 	if err != nil {
 		err = errors.Wrap(err, "Query: Error while unmarshalling Event-data - ItemWasteReport")
@@ -31,55 +49,57 @@ func Query(logger tlog.Logger, itemWasteColl *mongo.Collection, reportColl *mong
 			AggregateID:   event.AggregateID,
 			CorrelationID: event.CorrelationID,
 			Error:         err.Error(),
-			ErrorCode:     InternalError,
+			ErrorCode:     InvalidRequestError,
 			EventAction:   event.EventAction,
 			ServiceAction: event.ServiceAction,
 			UUID:          event.UUID,
 		}
 	}
 
-	if &filter == nil {
-		err = errors.New("blank filter provided")
-		err = errors.Wrap(err, "Query left blank - ItemWasteReport")
+	output := report.OutputParams{}
+	err = json.Unmarshal(event.Data, &output)
+	if err != nil {
+		err = errors.Wrap(err, "Query: Error while unmarshalling Event-data - OutputParams")
 		logger.E(tlog.Entry{
 			Description: err.Error(),
 			ErrorCode:   1,
-		}, filter)
+		}, output)
 		return &model.KafkaResponse{
 			AggregateID:   event.AggregateID,
 			CorrelationID: event.CorrelationID,
 			Error:         err.Error(),
-			ErrorCode:     InternalError,
+			ErrorCode:     InvalidRequestError,
 			EventAction:   event.EventAction,
 			ServiceAction: event.ServiceAction,
 			UUID:          event.UUID,
 		}
 	}
-
-	avgWasteReport, err := report.ItemWasteReport(filter, itemWasteColl)
-	if err != nil {
-		err = errors.Wrap(err, "Error getting results from ItemWasteCollection")
+	if !report.ValidFormat(output.Format) || !report.ValidUnit(output.Unit) {
+		err = errors.Errorf(
+			"Query: Unsupported output-format or weight-unit: %s, %s", output.Format, output.Unit,
+		)
 		logger.E(tlog.Entry{
 			Description: err.Error(),
 			ErrorCode:   1,
-		}, filter)
+		}, output)
 		return &model.KafkaResponse{
 			AggregateID:   event.AggregateID,
 			CorrelationID: event.CorrelationID,
 			Error:         err.Error(),
-			ErrorCode:     InternalError,
+			ErrorCode:     InvalidRequestError,
 			EventAction:   event.EventAction,
 			ServiceAction: event.ServiceAction,
 			UUID:          event.UUID,
 		}
 	}
 
-	if len(avgWasteReport) < 1 {
-		err = errors.New("Error: No result found from agg_itemwaste collection - Function = ItemWasteReport")
+	if &filter == nil {
+		err = errors.New("blank filter provided")
+		err = errors.Wrap(err, "Query left blank - ItemWasteReport")
 		logger.E(tlog.Entry{
 			Description: err.Error(),
 			ErrorCode:   1,
-		}, reportAgg)
+		}, filter)
 		return &model.KafkaResponse{
 			AggregateID:   event.AggregateID,
 			CorrelationID: event.CorrelationID,
@@ -91,72 +111,73 @@ func Query(logger tlog.Logger, itemWasteColl *mongo.Collection, reportColl *mong
 		}
 	}
 
-	for _, v := range avgWasteReport {
-		m, assertOK := v.(map[string]interface{})
-		if !assertOK {
-			err = errors.New("Error getting results from asserting AvgSoldReport into map[string]interface{}")
-			logger.E(tlog.Entry{
-				Description: err.Error(),
-				ErrorCode:   1,
-			}, m)
-			return &model.KafkaResponse{
-				AggregateID:   event.AggregateID,
-				CorrelationID: event.CorrelationID,
-				Error:         err.Error(),
-				ErrorCode:     InternalError,
-				EventAction:   event.EventAction,
-				ServiceAction: event.ServiceAction,
-				UUID:          event.UUID,
-			}
-		}
-
-		groupByFields := m["_id"]
-		mapInGroupBy := groupByFields.(map[string]interface{})
-		sku := mapInGroupBy["sku"].(string)
-		name := mapInGroupBy["name"].(string)
-
-		// log.Println(m, "#############")
-
-		//if it crashes on wasteWeight - check the wasteWeight field inside db and inside item_waste file--- inside the aggregate pipeline they should match
-		reportAgg = append(reportAgg, report.ReportResult{
-			SKU:         sku,
-			Name:        name,
-			WasteWeight: m["avg_waste"].(float64),
-			TotalWeight: m["avg_total"].(float64),
-		})
-	}
+	return runReport(
+		ctx, logger, itemWasteColl, reportColl, pricing, catalogColl,
+		claims, showCosts, filter, output, event,
+	)
+}
 
-	reportID, err := uuuid.NewV4()
+// runReport generates, stores and returns the report for the filter, for
+// Query and RunDefinition. The filter is limited to the stores allowed by
+// the claims, and cost-fields are only returned if showCosts is set.
+func runReport(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	reportColl *mongo.Collection,
+	pricing report.Pricing,
+	catalogColl *mongo.Collection,
+	claims *auth.Claims,
+	showCosts bool,
+	filter report.WasteItemParams,
+	output report.OutputParams,
+	event *model.Event,
+) *model.KafkaResponse {
+	err := filter.Validate()
 	if err != nil {
-		err = errors.Wrap(err, "Error in generating reportID ")
+		err = errors.Wrap(err, "Query: Invalid filter")
 		logger.E(tlog.Entry{
 			Description: err.Error(),
 			ErrorCode:   1,
-		})
+		}, filter)
 		return &model.KafkaResponse{
 			AggregateID:   event.AggregateID,
 			CorrelationID: event.CorrelationID,
 			Error:         err.Error(),
-			ErrorCode:     InternalError,
+			ErrorCode:     InvalidRequestError,
 			EventAction:   event.EventAction,
 			ServiceAction: event.ServiceAction,
 			UUID:          event.UUID,
 		}
 	}
 
-	reportGen := report.WasteReport{
-		ReportID:     reportID,
-		SearchQuery:  filter,
-		ReportResult: reportAgg,
+	err = scopeParams(claims, &filter)
+	if err != nil {
+		err = errors.Wrap(err, "Query: Error scoping report to claims")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
 	}
 
-	repInsert, err := report.CreateReport(reportGen, reportColl)
+	reportGen, err := report.GenerateReport(
+		ctx, filter, itemWasteColl, reportColl, pricing, catalogColl,
+	)
 	if err != nil {
-		err = errors.Wrap(err, "Error in inserting report to mongo")
+		err = errors.Wrap(err, "Query: Error generating report")
 		logger.E(tlog.Entry{
 			Description: err.Error(),
 			ErrorCode:   1,
-		}, reportGen)
+		}, filter)
 		return &model.KafkaResponse{
 			AggregateID:   event.AggregateID,
 			CorrelationID: event.CorrelationID,
@@ -167,17 +188,22 @@ func Query(logger tlog.Logger, itemWasteColl *mongo.Collection, reportColl *mong
 			UUID:          event.UUID,
 		}
 	}
+	log.Println("Generated report:", reportGen.ReportID.String())
+	if !showCosts {
+		reportGen = reportGen.WithoutCosts()
+	}
+	// The unit is already validated, so this can't fail
+	reportGen, _ = reportGen.InUnit(output.Unit)
 
-	log.Println(repInsert)
-	// log.Println(reportAgg, "$$$$$$$$$$$$$$$")
-
-	resultMarshal, err := json.Marshal(reportAgg)
+	_, marshalSpan := tracing.Tracer().Start(ctx, "marshal_report")
+	resultMarshal, err := report.MarshalReport(reportGen, output.Format)
+	marshalSpan.End()
 	if err != nil {
-		err = errors.Wrap(err, "Query: Error marshalling report ItemWasteResults - called reportAgg")
+		err = errors.Wrap(err, "Query: Error marshalling report-results")
 		logger.E(tlog.Entry{
 			Description: err.Error(),
 			ErrorCode:   1,
-		}, reportAgg)
+		}, reportGen.ReportResult)
 		return &model.KafkaResponse{
 			AggregateID:   event.AggregateID,
 			CorrelationID: event.CorrelationID,
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
+)
+
+// RenderReportAction is the ServiceAction for rendering a stored WasteReport.
+const RenderReportAction = "RenderReport"
+
+// renderParams is the event-data for RenderReportAction.
+type renderParams struct {
+	ReportID uuuid.UUID `json:"reportID"`
+	Format   string     `json:"format,omitempty"`
+	Unit     string     `json:"unit,omitempty"`
+}
+
+// RenderReport handles "query" events for rendering a previously generated
+// WasteReport as a document. The report is rendered as HTML unless some other
+// format is specified. The claims must allow all the stores in the report.
+// Cost-fields are removed from the report unless showCosts is set.
+func RenderReport(
+	ctx context.Context,
+	logger tlog.Logger,
+	reportColl *mongo.Collection,
+	claims *auth.Claims,
+	showCosts bool,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format: `{"reportID":"<uuid>","format":"html"}`
+	params := renderParams{}
+	err := json.Unmarshal(event.Data, &params)
+	if err != nil {
+		err = errors.Wrap(err, "RenderReport: Error while unmarshalling Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if params.Format == "" {
+		params.Format = report.FormatHTML
+	}
+	if params.ReportID == (uuuid.UUID{}) ||
+		!report.ValidFormat(params.Format) ||
+		!report.ValidUnit(params.Unit) {
+		err = errors.New(
+			"RenderReport: reportID, and a supported format and weight-unit are required",
+		)
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	_, findSpan := tracing.Tracer().Start(ctx, "mongo.find_report")
+	wasteReport, err := report.FindReport(params.ReportID, reportColl)
+	findSpan.End()
+	if err != nil {
+		err = errors.Wrap(err, "RenderReport: Error finding report")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = checkReportScope(claims, wasteReport)
+	if err != nil {
+		err = errors.Wrap(err, "RenderReport: Error checking report-scope")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	if !showCosts {
+		wasteReport = wasteReport.WithoutCosts()
+	}
+	wasteReport, err = wasteReport.InUnit(params.Unit)
+	if err != nil {
+		err = errors.Wrap(err, "RenderReport: Error converting report to weight-unit")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	_, marshalSpan := tracing.Tracer().Start(ctx, "marshal_report")
+	document, err := report.MarshalReport(wasteReport, params.Format)
+	marshalSpan.End()
+	if err != nil {
+		err = errors.Wrap(err, "RenderReport: Error rendering report")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        document,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
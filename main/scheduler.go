@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/agg-itemwaste-report/schedule"
+	"github.com/TerrexTech/go-kafkautils/kafka"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// reportScheduler runs the due report-schedules.
+type reportScheduler struct {
+	logger        tlog.Logger
+	itemWasteColl *mongo.Collection
+	reportColl    *mongo.Collection
+	schedColl     *mongo.Collection
+	pricing       report.Pricing
+	catalogColl   *mongo.Collection
+	producer      *kafka.Producer
+	topic         string
+}
+
+// runSchedule generates and stores the Schedule's report for the run at
+// runAt, and publishes it as a schedule.Run on the topic, keyed by the
+// scheduleID. The ID of the generated report is returned.
+func (rs *reportScheduler) runSchedule(
+	ctx context.Context,
+	s *schedule.Schedule,
+	runAt time.Time,
+) (string, error) {
+	params := s.Params
+	params.Timestamp = s.ReportWindow(runAt)
+	// Stores and claims were checked when the Schedule was saved
+	wasteReport, err := report.GenerateReport(
+		ctx, params, rs.itemWasteColl, rs.reportColl, rs.pricing, rs.catalogColl,
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error generating report")
+		return "", err
+	}
+	reportID := wasteReport.ReportID.String()
+
+	if !s.ShowCosts {
+		wasteReport = wasteReport.WithoutCosts()
+	}
+	// The unit is validated with the Schedule, so this can't fail
+	wasteReport, _ = wasteReport.InUnit(s.Unit)
+	document, err := report.MarshalReport(wasteReport, s.Format)
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling report")
+		return reportID, err
+	}
+
+	format := s.Format
+	if format == "" {
+		format = report.FormatJSON
+	}
+	value, err := json.Marshal(schedule.Run{
+		ScheduleID: s.ScheduleID,
+		Name:       s.Name,
+		RunAt:      runAt.Unix(),
+		From:       int64(params.Timestamp.Gt) + 1,
+		To:         int64(params.Timestamp.Lt),
+		ReportID:   reportID,
+		Format:     format,
+		Document:   document,
+	})
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling schedule-run")
+		return reportID, err
+	}
+	rs.producer.Input() <- kafka.CreateKeyMessage(rs.topic, s.ScheduleID, value)
+	return reportID, nil
+}
+
+// runDue runs the Schedules due at now. Each due Schedule is claimed first,
+// moving its next run after now, so it runs once even if the service has
+// several replicas. Runs missed while the service was down are skipped,
+// other than the latest.
+func (rs *reportScheduler) runDue(ctx context.Context, now time.Time) error {
+	due, err := schedule.Due(rs.schedColl, now)
+	if err != nil {
+		err = errors.Wrap(err, "Error finding due schedules")
+		return err
+	}
+
+	for _, s := range due {
+		runAt := time.Unix(s.NextRunAt, 0)
+		claimed, err := schedule.Claim(rs.schedColl, s, s.NextRun(now))
+		if err != nil {
+			err = errors.Wrapf(err, "Error claiming schedule %s", s.ScheduleID)
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		reportID, runErr := rs.runSchedule(ctx, s, runAt)
+		if runErr != nil {
+			metrics.ScheduledRuns.WithLabelValues("failed").Inc()
+			runErr = errors.Wrapf(runErr, "Error running schedule %s", s.ScheduleID)
+			rs.logger.E(tlog.Entry{
+				Description: runErr.Error(),
+				ErrorCode:   1,
+			}, s)
+		} else {
+			metrics.ScheduledRuns.WithLabelValues("published").Inc()
+			rs.logger.I(tlog.Entry{
+				Description: fmt.Sprintf(
+					"Published report %s for schedule %s", reportID, s.ScheduleID,
+				),
+			})
+		}
+		err = schedule.RecordRun(rs.schedColl, s.ScheduleID, runAt, reportID, runErr)
+		if err != nil {
+			err = errors.Wrapf(err, "Error recording run of schedule %s", s.ScheduleID)
+			return err
+		}
+	}
+	return nil
+}
+
+// runScheduledReports runs the due report-schedules every interval
+// until ctx is done.
+func runScheduledReports(ctx context.Context, rs *reportScheduler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := rs.runDue(ctx, time.Now())
+		if err != nil {
+			err = errors.Wrap(err, "Error running scheduled reports")
+			rs.logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/schedule"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
+)
+
+// ServiceActions for managing the report-schedules.
+const (
+	SaveScheduleAction   = "SaveSchedule"
+	DeleteScheduleAction = "DeleteSchedule"
+	ListSchedulesAction  = "ListSchedules"
+)
+
+// errSchedulesDisabled is returned by the schedule-actions
+// if there is no schedule-collection.
+var errSchedulesDisabled = errors.New("Scheduled reports are not enabled")
+
+// SaveSchedule handles "query" events creating or replacing a report-schedule.
+// The schedule's report is limited to the stores allowed by the claims, and
+// only keeps the cost-fields if showCosts is set. The saved schedule is
+// returned, having its next run.
+func SaveSchedule(
+	logger tlog.Logger,
+	schedColl *mongo.Collection,
+	claims *auth.Claims,
+	showCosts bool,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format:
+	// `{"name":"daily","cron":"0 6 * * *","timezone":"America/Toronto","window":"previousDay","params":{}}`
+	// The "params" are as for Query, without the timestamp. An optional "scheduleID"
+	// replaces that schedule, and optional "format", "unit" and "showCosts" are as for Query.
+	if schedColl == nil {
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         errSchedulesDisabled.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	s := &schedule.Schedule{}
+	err := json.Unmarshal(event.Data, s)
+	if err != nil {
+		err = errors.Wrap(err, "SaveSchedule: Error while unmarshalling Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, s)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = s.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "SaveSchedule: Invalid schedule")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, s)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = scopeParams(claims, &s.Params)
+	if err != nil {
+		err = errors.Wrap(err, "SaveSchedule: Error scoping schedule to claims")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	s.ShowCosts = s.ShowCosts && showCosts
+
+	isNew := s.ScheduleID == ""
+	if isNew {
+		scheduleID, err := uuuid.NewV4()
+		if err != nil {
+			err = errors.Wrap(err, "SaveSchedule: Error in generating scheduleID")
+			logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+			return &model.KafkaResponse{
+				AggregateID:   event.AggregateID,
+				CorrelationID: event.CorrelationID,
+				Error:         err.Error(),
+				ErrorCode:     InternalError,
+				EventAction:   event.EventAction,
+				ServiceAction: event.ServiceAction,
+				UUID:          event.UUID,
+			}
+		}
+		s.ScheduleID = scheduleID.String()
+		s.CreatedBy = claims.Subject
+		// Run-history can't be set by the caller
+		s.LastRunAt = 0
+		s.LastReportID = ""
+		s.LastError = ""
+	} else {
+		existing, err := schedule.Find(schedColl, s.ScheduleID)
+		if err != nil {
+			err = errors.Wrap(err, "SaveSchedule: Error finding schedule")
+			logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			}, s.ScheduleID)
+			return &model.KafkaResponse{
+				AggregateID:   event.AggregateID,
+				CorrelationID: event.CorrelationID,
+				Error:         err.Error(),
+				ErrorCode:     DatabaseError,
+				EventAction:   event.EventAction,
+				ServiceAction: event.ServiceAction,
+				UUID:          event.UUID,
+			}
+		}
+		if !claims.AllowsStores(existing.Stores()) {
+			err = errors.New("SaveSchedule: Claims do not allow the stores in schedule")
+			logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			}, claims)
+			return &model.KafkaResponse{
+				AggregateID:   event.AggregateID,
+				CorrelationID: event.CorrelationID,
+				Error:         err.Error(),
+				ErrorCode:     UnauthorizedError,
+				EventAction:   event.EventAction,
+				ServiceAction: event.ServiceAction,
+				UUID:          event.UUID,
+			}
+		}
+		s.CreatedBy = existing.CreatedBy
+		s.LastRunAt = existing.LastRunAt
+		s.LastReportID = existing.LastReportID
+		s.LastError = existing.LastError
+	}
+
+	nextRun := s.NextRun(time.Now())
+	if nextRun.IsZero() {
+		err = errors.Errorf("SaveSchedule: Cron-expression never matches: %s", s.Cron)
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, s)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	s.NextRunAt = nextRun.Unix()
+
+	err = schedule.Save(schedColl, s, isNew)
+	if err != nil {
+		err = errors.Wrap(err, "SaveSchedule: Error saving schedule")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, s)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	result, err := json.Marshal(s)
+	if err != nil {
+		err = errors.Wrap(err, "SaveSchedule: Error marshalling schedule")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, s)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
+
+// DeleteSchedule handles "query" events deleting a report-schedule, if the
+// claims allow all its stores. The deleted schedule is returned.
+func DeleteSchedule(
+	logger tlog.Logger,
+	schedColl *mongo.Collection,
+	claims *auth.Claims,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format: `{"scheduleID":"<uuid>"}`
+	if schedColl == nil {
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         errSchedulesDisabled.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	params := struct {
+		ScheduleID string `json:"scheduleID"`
+	}{}
+	err := json.Unmarshal(event.Data, &params)
+	if err == nil && params.ScheduleID == "" {
+		err = errors.New("scheduleID is required")
+	}
+	if err != nil {
+		err = errors.Wrap(err, "DeleteSchedule: Invalid Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	s, err := schedule.Find(schedColl, params.ScheduleID)
+	if err != nil {
+		err = errors.Wrap(err, "DeleteSchedule: Error finding schedule")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if !claims.AllowsStores(s.Stores()) {
+		err = errors.New("DeleteSchedule: Claims do not allow the stores in schedule")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = schedule.Delete(schedColl, s.ScheduleID)
+	if err != nil {
+		err = errors.Wrap(err, "DeleteSchedule: Error deleting schedule")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	result, err := json.Marshal(s)
+	if err != nil {
+		err = errors.Wrap(err, "DeleteSchedule: Error marshalling schedule")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, s)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
+
+// ListSchedules handles "query" events listing the report-schedules whose
+// stores are all allowed by the claims, by name.
+func ListSchedules(
+	logger tlog.Logger,
+	schedColl *mongo.Collection,
+	claims *auth.Claims,
+	event *model.Event,
+) *model.KafkaResponse {
+	if schedColl == nil {
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         errSchedulesDisabled.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	schedules, err := schedule.List(schedColl, map[string]interface{}{})
+	if err != nil {
+		err = errors.Wrap(err, "ListSchedules: Error listing schedules")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		})
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	allowed := make([]*schedule.Schedule, 0, len(schedules))
+	for _, s := range schedules {
+		if claims.AllowsStores(s.Stores()) {
+			allowed = append(allowed, s)
+		}
+	}
+
+	result, err := json.Marshal(allowed)
+	if err != nil {
+		err = errors.Wrap(err, "ListSchedules: Error marshalling schedules")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		})
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	"github.com/pkg/errors"
+)
+
+// eventClaims returns the claims carried in the event, same as callerClaims.
+func eventClaims(event *model.Event, requireClaims bool) (*auth.Claims, error) {
+	claims, err := auth.FromEventData(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	return callerClaims(claims, requireClaims)
+}
+
+// callerClaims returns the caller's claims. Callers without claims are denied
+// if requireClaims is set, and otherwise get the admin-role and can access
+// every store.
+func callerClaims(claims *auth.Claims, requireClaims bool) (*auth.Claims, error) {
+	if claims == nil {
+		if requireClaims {
+			return nil, errors.New("Caller has no claims")
+		}
+		claims = &auth.Claims{
+			Roles:  []string{auth.RoleAdmin},
+			Stores: []string{auth.AllStores},
+		}
+	}
+	return claims, nil
+}
+
+// scopeParams limits the params to the stores the claims allow. If the params
+// are not limited to any stores, they are limited to all the claimed stores.
+// An error is returned if the params ask for stores the claims don't allow.
+func scopeParams(claims *auth.Claims, params *report.WasteItemParams) error {
+	return scopeStores(claims, &params.StoreID)
+}
+
+// scopeLotParams limits the lot-trace params to the stores the claims allow,
+// same as scopeParams.
+func scopeLotParams(claims *auth.Claims, params *report.LotTraceParams) error {
+	return scopeStores(claims, &params.StoreID)
+}
+
+// scopeStores limits the store-filter to the stores the claims allow.
+func scopeStores(claims *auth.Claims, storeID **report.InComparator) error {
+	if claims.AllowsAllStores() {
+		return nil
+	}
+	if len(claims.Stores) == 0 {
+		return errors.New("Claims allow no stores")
+	}
+
+	if *storeID == nil {
+		*storeID = &report.InComparator{
+			In: claims.Stores,
+		}
+		return nil
+	}
+	if !claims.AllowsStores((*storeID).In) {
+		return errors.Errorf(
+			"Claims do not allow stores: %v", (*storeID).In,
+		)
+	}
+	return nil
+}
+
+// checkReportScope checks if the claims allow all the stores
+// the stored WasteReport was generated for.
+func checkReportScope(claims *auth.Claims, wasteReport *report.WasteReport) error {
+	if !claims.AllowsStores(wasteReport.SearchQuery.Stores()) {
+		return errors.New("Claims do not allow the stores in report")
+	}
+	return nil
+}
+
+// scopedStores returns the stores the claims allow, or nil if they allow
+// every store.
+func scopedStores(claims *auth.Claims) []string {
+	if claims.AllowsAllStores() {
+		return nil
+	}
+	return claims.Stores
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/TerrexTech/agg-itemwaste-report/auth"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
+	"github.com/TerrexTech/go-eventstore-models/model"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// ShelfLifeAction is the ServiceAction for the shelf-life report.
+const ShelfLifeAction = "ShelfLife"
+
+// ShelfLife handles "query" events for the shelf-life report, having the
+// distributions per SKU of the days its waste-events happened after receiving
+// and before expiry. Items without their own dates use their lot's dates from
+// lotColl (if not nil). The waste-events are limited to the stores allowed
+// by the claims. The report is not stored.
+func ShelfLife(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	lotColl *mongo.Collection,
+	claims *auth.Claims,
+	event *model.Event,
+) *model.KafkaResponse {
+	// event.Data should be in this format: `{"timestamp":{"$gt":1529315000,"$lt":1551997372}}`
+	// Optional `"sku":{"$in":["<sku>"]}` and `"storeID":{"$in":["<store>"]}` filter the waste-events.
+	// An optional `"format"` of "json" (default) or "csv", and `"unit"` as for Query.
+	params := report.ShelfLifeParams{}
+	err := json.Unmarshal(event.Data, &params)
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLife: Error while unmarshalling Event-data")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	output := report.OutputParams{}
+	err = json.Unmarshal(event.Data, &output)
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLife: Error while unmarshalling Event-data - OutputParams")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	if !report.ValidDataFormat(output.Format) || !report.ValidUnit(output.Unit) {
+		err = errors.Errorf(
+			"ShelfLife: Unsupported output-format or weight-unit: %s, %s", output.Format, output.Unit,
+		)
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, output)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = params.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLife: Invalid params")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InvalidRequestError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	err = scopeStores(claims, &params.StoreID)
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLife: Error scoping report to claims")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, claims)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     UnauthorizedError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	_, shelfSpan := tracing.Tracer().Start(ctx, "mongo.shelf_life")
+	results, err := report.ShelfLifeReport(params, itemWasteColl, lotColl)
+	if err != nil {
+		tracing.RecordError(shelfSpan, err)
+	}
+	shelfSpan.End()
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLife: Error getting shelf-life results")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     DatabaseError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+	// The unit is already validated, so this can't fail
+	results, _ = report.ShelfLifeInUnit(results, output.Unit)
+
+	result, err := report.MarshalShelfLife(results, output.Format)
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLife: Error marshalling shelf-life results")
+		logger.E(tlog.Entry{
+			Description: err.Error(),
+			ErrorCode:   1,
+		}, params)
+		return &model.KafkaResponse{
+			AggregateID:   event.AggregateID,
+			CorrelationID: event.CorrelationID,
+			Error:         err.Error(),
+			ErrorCode:     InternalError,
+			EventAction:   event.EventAction,
+			ServiceAction: event.ServiceAction,
+			UUID:          event.UUID,
+		}
+	}
+
+	return &model.KafkaResponse{
+		AggregateID:   event.AggregateID,
+		CorrelationID: event.CorrelationID,
+		EventAction:   event.EventAction,
+		Result:        result,
+		ServiceAction: event.ServiceAction,
+		UUID:          event.UUID,
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	tlog "github.com/TerrexTech/go-logtransport/log"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/pkg/errors"
+)
+
+// otherGaugeKey is the label-value for groups beyond the max-series limit.
+const otherGaugeKey = "__other__"
+
+// wasteGaugeWindows are the rolling windows for which waste-gauges are computed.
+var wasteGaugeWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// wasteGaugeGroups are the WasteItem fields waste-gauges are grouped by.
+var wasteGaugeGroups = []string{"sku", "lot", "storeID"}
+
+// wasteGaugeValue is a computed gauge-value, before being set on the gauges.
+type wasteGaugeValue struct {
+	window string
+	group  string
+	total  report.WasteTotal
+}
+
+// wasteGaugeLabels are the label-values of a waste-gauge series.
+type wasteGaugeLabels struct {
+	window string
+	group  string
+	key    string
+}
+
+// updateWasteGauges recomputes the waste-gauges. Atmost maxSeries keys are
+// exported per window and group, with the remaining keys summed into
+// otherGaugeKey, so the label-cardinality stays bounded. The exported are
+// the series set by the previous update, and the series set by this update
+// are returned. The exported series are kept if the update fails.
+func updateWasteGauges(
+	itemWasteColl *mongo.Collection,
+	maxSeries int,
+	exported map[wasteGaugeLabels]bool,
+) (map[wasteGaugeLabels]bool, error) {
+	values := []wasteGaugeValue{}
+	now := time.Now()
+
+	for window, duration := range wasteGaugeWindows {
+		since := now.Add(-duration).Unix()
+		for _, group := range wasteGaugeGroups {
+			totals, err := report.WasteTotals(itemWasteColl, group, since)
+			if err != nil {
+				err = errors.Wrapf(err, "Error getting %s waste-totals for %s", window, group)
+				return exported, err
+			}
+			for _, t := range report.LimitWasteTotals(totals, maxSeries, otherGaugeKey) {
+				values = append(values, wasteGaugeValue{
+					window: window,
+					group:  group,
+					total:  t,
+				})
+			}
+		}
+	}
+
+	// Series are updated in place, so scrapes never see the gauges empty
+	updated := make(map[wasteGaugeLabels]bool, len(values))
+	for _, v := range values {
+		metrics.WasteWeight.WithLabelValues(v.window, v.group, v.total.Key).Set(
+			v.total.WasteWeight,
+		)
+		metrics.WasteRatio.WithLabelValues(v.window, v.group, v.total.Key).Set(
+			v.total.WasteRatio(),
+		)
+		updated[wasteGaugeLabels{v.window, v.group, v.total.Key}] = true
+	}
+	// Then keys no longer having waste in window are dropped
+	for labels := range exported {
+		if updated[labels] {
+			continue
+		}
+		metrics.WasteWeight.DeleteLabelValues(labels.window, labels.group, labels.key)
+		metrics.WasteRatio.DeleteLabelValues(labels.window, labels.group, labels.key)
+	}
+	return updated, nil
+}
+
+// runWasteGauges updates the waste-gauges every interval until ctx is done.
+func runWasteGauges(
+	ctx context.Context,
+	logger tlog.Logger,
+	itemWasteColl *mongo.Collection,
+	interval time.Duration,
+	maxSeries int,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	exported := map[wasteGaugeLabels]bool{}
+	for {
+		var err error
+		exported, err = updateWasteGauges(itemWasteColl, maxSeries, exported)
+		if err != nil {
+			err = errors.Wrap(err, "Error updating waste-gauges")
+			logger.E(tlog.Entry{
+				Description: err.Error(),
+				ErrorCode:   1,
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package metrics contains the Prometheus collectors for the service.
+// All collectors are registered with the default Prometheus registry,
+// and are exposed using Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "agg_itemwaste_report"
+
+var (
+	// QueryEvents counts the query-events received, by ServiceAction.
+	QueryEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_events_total",
+			Help:      "Number of query-events received.",
+		},
+		[]string{"service_action"},
+	)
+
+	// Responses counts the responses produced for query-events, by ErrorCode.
+	// Successful responses have the ErrorCode 0.
+	Responses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "responses_total",
+			Help:      "Number of responses produced, by error-code.",
+		},
+		[]string{"error_code"},
+	)
+
+	// ReportsGenerated counts the reports generated and stored.
+	ReportsGenerated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reports_generated_total",
+			Help:      "Number of reports generated and stored.",
+		},
+	)
+
+	// ReportResultRows observes the number of result-rows in generated reports.
+	ReportResultRows = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "report_result_rows",
+			Help:      "Number of result-rows in generated reports.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+		},
+	)
+
+	// AggregationDuration observes the time taken by report-aggregations.
+	AggregationDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "aggregation_duration_seconds",
+			Help:      "Time taken by report-aggregation pipelines.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+
+	// ReportInsertDuration observes the time taken to store generated reports.
+	ReportInsertDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "report_insert_duration_seconds",
+			Help:      "Time taken to insert generated reports.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+
+	// WasteWeight is the summed waste-weight over a rolling window,
+	// by group (such as "sku" or "lot") and the group's key.
+	WasteWeight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "waste_weight",
+			Help:      "Summed waste-weight over rolling window.",
+		},
+		[]string{"window", "group", "key"},
+	)
+
+	// WasteRatio is the ratio of waste-weight to total-weight over a rolling
+	// window, by group (such as "sku" or "lot") and the group's key.
+	WasteRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "waste_ratio",
+			Help:      "Ratio of waste-weight to total-weight over rolling window.",
+		},
+		[]string{"window", "group", "key"},
+	)
+
+	// AlertEvents counts the alert-events produced, by rule and status.
+	AlertEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "alert_events_total",
+			Help:      "Number of alert-events produced, by rule and status.",
+		},
+		[]string{"rule", "status"},
+	)
+
+	// AnomalyEvents counts the anomaly-events produced, by direction.
+	AnomalyEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "anomaly_events_total",
+			Help:      "Number of anomaly-events produced, by direction.",
+		},
+		[]string{"direction"},
+	)
+
+	// ScheduledRuns counts the runs of scheduled reports, by status.
+	ScheduledRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "scheduled_runs_total",
+			Help:      "Number of scheduled report-runs, by status.",
+		},
+		[]string{"status"},
+	)
+
+	// InFlightHandlers is the number of event-handling goroutines running.
+	InFlightHandlers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "inflight_handlers",
+			Help:      "Number of event-handler goroutines currently running.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		QueryEvents,
+		Responses,
+		ReportsGenerated,
+		ReportResultRows,
+		AggregationDuration,
+		ReportInsertDuration,
+		InFlightHandlers,
+		WasteWeight,
+		WasteRatio,
+		AlertEvents,
+		AnomalyEvents,
+		ScheduledRuns,
+	)
+}
+
+// Handler returns the http.Handler for the "/metrics" endpoint.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,389 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	util "github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// Defaults for the AnomalyParams.
+const (
+	DefaultBaselineDays     = 28
+	DefaultAnomalyThreshold = 3
+)
+
+// MinBaselineDays is the days of history a SKU needs at a store before
+// its days are checked for anomalies.
+const MinBaselineDays = 7
+
+// minStdDevRatio floors the baseline's standard-deviation at this ratio
+// of its mean, so SKUs with very steady waste don't have every small
+// change flagged.
+const minStdDevRatio = 0.1
+
+// Directions of an Anomaly.
+const (
+	AnomalyHigh = "high"
+	AnomalyLow  = "low"
+)
+
+// AnomalyParams are the filters for the anomalies report.
+type AnomalyParams struct {
+	// Timestamp is the time-window whose UTC-days are checked for anomalies.
+	// Both $gt and $lt are required.
+	Timestamp *Comparator   `json:"timestamp,omitempty"`
+	SKU       *InComparator `json:"sku,omitempty"`
+	StoreID   *InComparator `json:"storeID,omitempty"`
+	// BaselineDays is the number of days before each checked day that its
+	// baseline is learned from. Defaults to DefaultBaselineDays.
+	BaselineDays int `json:"baselineDays,omitempty"`
+	// Threshold is the number of standard-deviations from the baseline's
+	// mean beyond which a day is an anomaly. Defaults to DefaultAnomalyThreshold.
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// Stores returns the stores the params are limited to, or nil for all stores.
+func (p AnomalyParams) Stores() []string {
+	if p.StoreID == nil {
+		return nil
+	}
+	return p.StoreID.In
+}
+
+// Validate checks the time-window and sets the defaults.
+func (p *AnomalyParams) Validate() error {
+	if p.Timestamp == nil || p.Timestamp.Gt == 0 || p.Timestamp.Lt == 0 {
+		return errors.New("Both timestamp $gt and $lt are required")
+	}
+	if p.Timestamp.Lt <= p.Timestamp.Gt {
+		return errors.New("Timestamp $lt must be after $gt")
+	}
+	if p.BaselineDays == 0 {
+		p.BaselineDays = DefaultBaselineDays
+	}
+	if p.BaselineDays < MinBaselineDays {
+		return errors.Errorf("BaselineDays must be atleast %d", MinBaselineDays)
+	}
+	if p.Threshold == 0 {
+		p.Threshold = DefaultAnomalyThreshold
+	}
+	if p.Threshold < 0 {
+		return errors.New("Threshold must be positive")
+	}
+	return nil
+}
+
+// days returns the start of the first and last UTC-day in the time-window.
+func (p AnomalyParams) days() (int64, int64) {
+	first := dayStart(int64(p.Timestamp.Gt))
+	// $lt is exclusive, so a window ending at midnight excludes that day
+	last := dayStart(int64(math.Ceil(p.Timestamp.Lt)) - 1)
+	return first, last
+}
+
+// pipeline returns the aggregation-pipeline summing the waste per SKU and
+// store on each UTC-day of the time-window and the baseline before it.
+func (p AnomalyParams) pipeline() ([]*bson.Document, error) {
+	first, _ := p.days()
+	window := &Comparator{
+		// Timestamps are integers, so this includes the baseline's first day
+		Gt: float64(first - int64(p.BaselineDays)*secondsPerDay - 1),
+		Lt: p.Timestamp.Lt,
+	}
+
+	b := newPipelineBuilder()
+	b.matchField("timestamp", timestampConds(window)...)
+	b.matchIn("sku", p.SKU)
+	b.matchIn("storeID", p.StoreID)
+	b.groupBy("sku", "name", "storeID")
+	b.groupByDay()
+	b.accumulate("wasteWeight", "$sum", "weight")
+	return b.build()
+}
+
+// dayStart returns the start of the timestamp's UTC-day.
+func dayStart(timestamp int64) int64 {
+	return timestamp - ((timestamp%secondsPerDay)+secondsPerDay)%secondsPerDay
+}
+
+// Anomaly is a day on which a SKU's waste at a store deviated from its
+// baseline by more than the threshold. Weights are in Unit.
+type Anomaly struct {
+	SKU     string `json:"sku"`
+	Name    string `json:"name,omitempty"`
+	StoreID string `json:"storeID,omitempty"`
+	// Day is the start of the UTC-day, as a unix-timestamp.
+	Day         int64   `json:"day"`
+	WasteWeight float64 `json:"wasteWeight"`
+	// BaselineMean and BaselineStdDev are of the daily waste-weight over
+	// the baseline-days before Day.
+	BaselineMean   float64 `json:"baselineMean"`
+	BaselineStdDev float64 `json:"baselineStdDev"`
+	// Score is the number of standard-deviations the WasteWeight is from
+	// the BaselineMean, and is negative for AnomalyLow.
+	Score     float64 `json:"score"`
+	Direction string  `json:"direction"`
+	Unit      string  `json:"unit"`
+}
+
+// AnomalyReport returns the Anomalies in the time-window of the params,
+// sorted by day, SKU and store. The params must be valid.
+//
+// Each SKU at each store has its daily waste compared with its baseline,
+// which is the mean and standard-deviation of its daily waste over the
+// baseline-days before, counting days without waste as 0. Days are only
+// checked once the SKU has MinBaselineDays of history at the store, and
+// have any waste in their baseline.
+func AnomalyReport(params AnomalyParams, itemWasteColl *mongo.Collection) ([]Anomaly, error) {
+	pipeline, err := params.pipeline()
+	if err != nil {
+		err = errors.Wrap(err, "AnomalyReport: Error in generating pipeline")
+		log.Println(err)
+		return nil, err
+	}
+	aggResults, err := itemWasteColl.Aggregate(pipeline)
+	if err != nil {
+		err = errors.Wrap(err, "AnomalyReport: Error in getting aggregate results")
+		log.Println(err)
+		return nil, err
+	}
+
+	series, err := dailyWasteFromAggregate(aggResults)
+	if err != nil {
+		err = errors.Wrap(err, "AnomalyReport: Error converting aggregate results")
+		log.Println(err)
+		return nil, err
+	}
+	return detectAnomalies(series, params), nil
+}
+
+// dailyWaste is the waste-weight per UTC-day of a SKU at a store.
+type dailyWaste struct {
+	sku     string
+	name    string
+	storeID string
+	// first is the first day having waste
+	first int64
+	days  map[int64]float64
+}
+
+// dailyWasteFromAggregate converts the results of a pipeline grouping by
+// day to the dailyWaste of each SKU and store. The storeID is blank if the
+// pipeline does not group by store.
+func dailyWasteFromAggregate(aggResults []interface{}) ([]*dailyWaste, error) {
+	index := map[[2]string]*dailyWaste{}
+	series := []*dailyWaste{}
+
+	for i, v := range aggResults {
+		m, assertOK := v.(map[string]interface{})
+		if !assertOK {
+			return nil, errors.Errorf(
+				"Error asserting aggregate-result at index %d to map[string]interface{}", i,
+			)
+		}
+		groupBy, assertOK := m["_id"].(map[string]interface{})
+		if !assertOK {
+			return nil, errors.Errorf("Error asserting _id of aggregate-result at index %d", i)
+		}
+		day, err := util.AssertInt64(groupBy["day"])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error asserting day of aggregate-result at index %d", i)
+		}
+		weight, err := util.AssertFloat64(m["wasteWeight"])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error asserting wasteWeight of aggregate-result at index %d", i)
+		}
+
+		sku, _ := groupBy["sku"].(string)
+		err = checkUnknownUnits(m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error in aggregate-result of SKU %s", sku)
+		}
+		storeID, _ := groupBy["storeID"].(string)
+		key := [2]string{sku, storeID}
+		s, exists := index[key]
+		if !exists {
+			s = &dailyWaste{
+				sku:     sku,
+				storeID: storeID,
+				first:   day,
+				days:    map[int64]float64{},
+			}
+			index[key] = s
+			series = append(series, s)
+		}
+		if name, _ := groupBy["name"].(string); name != "" {
+			s.name = name
+		}
+		if day < s.first {
+			s.first = day
+		}
+		// The pipeline also groups by name, so a SKU can have several results per day
+		s.days[day] += weight
+	}
+	return series, nil
+}
+
+// detectAnomalies checks each day in the time-window of the params against
+// the baseline of each dailyWaste.
+func detectAnomalies(series []*dailyWaste, params AnomalyParams) []Anomaly {
+	first, last := params.days()
+	anomalies := []Anomaly{}
+
+	for _, s := range series {
+		for day := first; day <= last; day += secondsPerDay {
+			baseline := []float64{}
+			for b := day - int64(params.BaselineDays)*secondsPerDay; b < day; b += secondsPerDay {
+				if b >= s.first {
+					baseline = append(baseline, s.days[b])
+				}
+			}
+			if len(baseline) < MinBaselineDays {
+				continue
+			}
+
+			mean, stdDev := meanStdDev(baseline)
+			stdDev = math.Max(stdDev, minStdDevRatio*mean)
+			if stdDev == 0 {
+				continue
+			}
+			score := (s.days[day] - mean) / stdDev
+			if math.Abs(score) <= params.Threshold {
+				continue
+			}
+
+			direction := AnomalyHigh
+			if score < 0 {
+				direction = AnomalyLow
+			}
+			anomalies = append(anomalies, Anomaly{
+				SKU:            s.sku,
+				Name:           s.name,
+				StoreID:        s.storeID,
+				Day:            day,
+				WasteWeight:    s.days[day],
+				BaselineMean:   mean,
+				BaselineStdDev: stdDev,
+				Score:          score,
+				Direction:      direction,
+				Unit:           CanonicalUnit,
+			})
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		a, b := anomalies[i], anomalies[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.SKU != b.SKU {
+			return a.SKU < b.SKU
+		}
+		return a.StoreID < b.StoreID
+	})
+	return anomalies
+}
+
+// meanStdDev returns the mean and population standard-deviation of the values.
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var sqDiff float64
+	for _, v := range values {
+		sqDiff += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sqDiff / float64(len(values)))
+}
+
+// AnomaliesInUnit returns a copy of the Anomalies with weights converted
+// to the weight-unit. The Scores are not affected.
+func AnomaliesInUnit(anomalies []Anomaly, unit string) ([]Anomaly, error) {
+	if unit == "" {
+		unit = CanonicalUnit
+	}
+	converted := make([]Anomaly, len(anomalies))
+	for i, a := range anomalies {
+		ratio, err := ConvertWeight(1, a.Unit, unit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting anomaly at index %d", i)
+		}
+		a.WasteWeight *= ratio
+		a.BaselineMean *= ratio
+		a.BaselineStdDev *= ratio
+		a.Unit = unit
+		converted[i] = a
+	}
+	return converted, nil
+}
+
+// MarshalAnomalies converts the Anomalies to the output-format, which is
+// either JSON, or CSV having a row per Anomaly.
+func MarshalAnomalies(anomalies []Anomaly, format string) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(anomalies)
+	case FormatCSV:
+		buf := &bytes.Buffer{}
+		err := WriteAnomalyCSV(buf, anomalies)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, errors.Errorf("Unsupported output-format for anomalies: %s", format)
+}
+
+// WriteAnomalyCSV writes a row for each Anomaly, with the day as an
+// RFC3339 date.
+func WriteAnomalyCSV(w io.Writer, anomalies []Anomaly) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{
+		"day", "sku", "name", "storeID", "wasteWeight",
+		"baselineMean", "baselineStdDev", "score", "direction", "unit",
+	})
+	if err != nil {
+		err = errors.Wrap(err, "WriteAnomalyCSV: Error writing header-row")
+		return err
+	}
+
+	for i, a := range anomalies {
+		err = cw.Write([]string{
+			time.Unix(a.Day, 0).UTC().Format(time.RFC3339),
+			a.SKU,
+			a.Name,
+			a.StoreID,
+			formatFloat(a.WasteWeight),
+			formatFloat(a.BaselineMean),
+			formatFloat(a.BaselineStdDev),
+			formatFloat(a.Score),
+			a.Direction,
+			a.Unit,
+		})
+		if err != nil {
+			err = errors.Wrapf(err, "WriteAnomalyCSV: Error writing row at index: %d", i)
+			return err
+		}
+	}
+
+	cw.Flush()
+	err = cw.Error()
+	if err != nil {
+		err = errors.Wrap(err, "WriteAnomalyCSV: Error flushing CSV-writer")
+		return err
+	}
+	return nil
+}
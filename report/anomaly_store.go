@@ -0,0 +1,64 @@
+package report
+
+import (
+	"log"
+
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	mgo "github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/pkg/errors"
+)
+
+// duplicateKeyCode is the Mongo error-code for inserts violating
+// a unique index.
+const duplicateKeyCode = 11000
+
+// anomalyBSON is the stored Anomaly.
+type anomalyBSON struct {
+	SKU            string  `bson:"sku"`
+	Name           string  `bson:"name"`
+	StoreID        string  `bson:"storeID"`
+	Day            int64   `bson:"day"`
+	WasteWeight    float64 `bson:"wasteWeight"`
+	BaselineMean   float64 `bson:"baselineMean"`
+	BaselineStdDev float64 `bson:"baselineStdDev"`
+	Score          float64 `bson:"score"`
+	Direction      string  `bson:"direction"`
+	Unit           string  `bson:"unit"`
+}
+
+func (a Anomaly) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(anomalyBSON(a))
+}
+
+func (a *Anomaly) UnmarshalBSON(in []byte) error {
+	ab := &anomalyBSON{}
+	err := bson.Unmarshal(in, ab)
+	if err != nil {
+		err = errors.Wrap(err, "UnmarshalBSON Error")
+		return err
+	}
+	*a = Anomaly(*ab)
+	return nil
+}
+
+// ClaimAnomaly stores the Anomaly, and returns false if it was already
+// stored, such as by another replica or before a restart. The unique index
+// on the day, SKU and store makes this a claim, so each anomaly-event is
+// only produced once.
+func ClaimAnomaly(anomalyColl *mongo.Collection, a Anomaly) (bool, error) {
+	_, err := anomalyColl.InsertOne(a)
+	if err == nil {
+		return true, nil
+	}
+	if writeErrs, ok := errors.Cause(err).(mgo.WriteErrors); ok {
+		for _, writeErr := range writeErrs {
+			if writeErr.Code == duplicateKeyCode {
+				return false, nil
+			}
+		}
+	}
+	err = errors.Wrapf(err, "Error claiming anomaly of SKU %s at store %s", a.SKU, a.StoreID)
+	log.Println(err)
+	return false, err
+}
@@ -0,0 +1,191 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	"github.com/mongodb/mongo-go-driver/bson"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Anomalies", func() {
+	const day = secondsPerDay
+	// firstDay is the first checked day in params
+	const firstDay = 100 * day
+
+	var params AnomalyParams
+
+	BeforeEach(func() {
+		params = AnomalyParams{
+			Timestamp: &Comparator{Gt: firstDay + 3600, Lt: firstDay + 2*day},
+		}
+		Expect(params.Validate()).To(Succeed())
+	})
+
+	It("validates the params and sets defaults", func() {
+		Expect(params.BaselineDays).To(Equal(DefaultBaselineDays))
+		Expect(params.Threshold).To(Equal(float64(DefaultAnomalyThreshold)))
+
+		first, last := params.days()
+		Expect(first).To(Equal(int64(firstDay)))
+		Expect(last).To(Equal(int64(firstDay + day)))
+
+		Expect((&AnomalyParams{}).Validate()).ToNot(Succeed())
+		Expect((&AnomalyParams{
+			Timestamp:    &Comparator{Gt: 1, Lt: 2},
+			BaselineDays: 3,
+		}).Validate()).ToNot(Succeed())
+	})
+
+	It("sums the waste per day, including the baseline", func() {
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline).To(HaveLen(2))
+		Expect(pipeline[0].Lookup("$match", "timestamp", "$gt").Double()).To(
+			Equal(float64(firstDay - DefaultBaselineDays*day - 1)),
+		)
+		Expect(pipeline[1].Lookup("$group", "_id", "day", "$subtract")).ToNot(BeNil())
+		Expect(pipeline[1].Lookup("$group", "wasteWeight", "$sum")).ToNot(BeNil())
+	})
+
+	It("flags days deviating from the baseline", func() {
+		days := map[int64]float64{}
+		for d := int64(1); d <= DefaultBaselineDays; d++ {
+			// Alternates between 9 and 11 kg, so the stddev is 1
+			days[firstDay-d*day] = 10 + float64(d%2*2-1)
+		}
+		days[firstDay] = 15
+		days[firstDay+day] = 12
+
+		series := []*dailyWaste{
+			&dailyWaste{
+				sku: "sku1", name: "gala", storeID: "store1",
+				first: firstDay - DefaultBaselineDays*day, days: days,
+			},
+		}
+		anomalies := detectAnomalies(series, params)
+		Expect(anomalies).To(Equal([]Anomaly{
+			Anomaly{
+				SKU:            "sku1",
+				Name:           "gala",
+				StoreID:        "store1",
+				Day:            firstDay,
+				WasteWeight:    15,
+				BaselineMean:   10,
+				BaselineStdDev: 1,
+				Score:          5,
+				Direction:      AnomalyHigh,
+				Unit:           CanonicalUnit,
+			},
+		}))
+
+		days[firstDay] = 0
+		anomalies = detectAnomalies(series, params)
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Direction).To(Equal(AnomalyLow))
+		Expect(anomalies[0].Score).To(Equal(-10.0))
+	})
+
+	It("skips SKUs without enough history or baseline waste", func() {
+		series := []*dailyWaste{
+			&dailyWaste{
+				sku: "new", first: firstDay - 3*day,
+				days: map[int64]float64{firstDay - 3*day: 1, firstDay: 100},
+			},
+			&dailyWaste{
+				sku: "rare", first: firstDay - 60*day,
+				days: map[int64]float64{firstDay - 60*day: 1, firstDay: 100},
+			},
+		}
+		Expect(detectAnomalies(series, params)).To(BeEmpty())
+	})
+
+	It("floors the stddev of steady waste", func() {
+		days := map[int64]float64{}
+		for d := int64(1); d <= 10; d++ {
+			days[firstDay-d*day] = 10
+		}
+		days[firstDay] = 12
+		days[firstDay+day] = 14
+
+		anomalies := detectAnomalies([]*dailyWaste{
+			&dailyWaste{sku: "sku1", first: firstDay - 10*day, days: days},
+		}, params)
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Day).To(Equal(int64(firstDay + day)))
+		Expect(anomalies[0].BaselineStdDev).To(Equal(minStdDevRatio * anomalies[0].BaselineMean))
+	})
+
+	It("converts the aggregate-results to daily waste", func() {
+		series, err := dailyWasteFromAggregate([]interface{}{
+			map[string]interface{}{
+				"_id": map[string]interface{}{
+					"sku": "sku1", "name": "gala", "storeID": "store1", "day": int64(2 * day),
+				},
+				"wasteWeight": 2.0,
+			},
+			map[string]interface{}{
+				"_id": map[string]interface{}{
+					"sku": "sku1", "name": "Gala", "storeID": "store1", "day": int64(day),
+				},
+				"wasteWeight": 1.0,
+			},
+			map[string]interface{}{
+				"_id": map[string]interface{}{
+					"sku": "sku1", "storeID": "store1", "day": int64(2 * day),
+				},
+				"wasteWeight": 3.0,
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(series).To(HaveLen(1))
+		Expect(series[0].first).To(Equal(int64(day)))
+		Expect(series[0].days).To(Equal(map[int64]float64{day: 1, 2 * day: 5}))
+		Expect(series[0].name).To(Equal("Gala"))
+	})
+
+	It("round-trips claimed anomalies through BSON", func() {
+		anomaly := Anomaly{
+			SKU: "sku1", Name: "gala", StoreID: "store1", Day: day, WasteWeight: 2,
+			BaselineMean: 1, BaselineStdDev: 0.25, Score: 4,
+			Direction: AnomalyHigh, Unit: CanonicalUnit,
+		}
+		in, err := bson.Marshal(anomaly)
+		Expect(err).ToNot(HaveOccurred())
+		doc, err := bson.ReadDocument(in)
+		Expect(err).ToNot(HaveOccurred())
+		// The unique index is on these keys
+		Expect(doc.Lookup("day")).ToNot(BeNil())
+		Expect(doc.Lookup("sku")).ToNot(BeNil())
+		Expect(doc.Lookup("storeID")).ToNot(BeNil())
+
+		out := Anomaly{}
+		err = bson.Unmarshal(in, &out)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal(anomaly))
+	})
+
+	It("converts weights and writes a CSV-row per anomaly", func() {
+		anomalies := []Anomaly{
+			Anomaly{
+				SKU: "sku1", StoreID: "store1", Day: day, WasteWeight: 2,
+				BaselineMean: 1, BaselineStdDev: 0.25, Score: 4,
+				Direction: AnomalyHigh, Unit: CanonicalUnit,
+			},
+		}
+		converted, err := AnomaliesInUnit(anomalies, UnitGram)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(converted[0].WasteWeight).To(BeNumerically("~", 2000, 1e-9))
+		Expect(converted[0].BaselineStdDev).To(BeNumerically("~", 250, 1e-9))
+		Expect(converted[0].Score).To(Equal(4.0))
+
+		doc, err := MarshalAnomalies(anomalies, FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		rows, err := csv.NewReader(bytes.NewReader(doc)).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows[1]).To(Equal([]string{
+			"1970-01-02T00:00:00Z", "sku1", "", "store1", "2", "1", "0.25", "4", "high", "kg",
+		}))
+	})
+})
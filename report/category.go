@@ -0,0 +1,318 @@
+package report
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// Levels of the product-category hierarchy, from the broadest.
+const (
+	LevelDepartment  = "department"
+	LevelCategory    = "category"
+	LevelSubcategory = "subcategory"
+	LevelSKU         = "sku"
+)
+
+// Levels lists the hierarchy-levels, from the broadest.
+var Levels = []string{LevelDepartment, LevelCategory, LevelSubcategory, LevelSKU}
+
+// Uncategorized is used for hierarchy-levels of SKUs missing in the catalogue.
+const Uncategorized = "(uncategorized)"
+
+// CategoryPath is the position of a product in the category hierarchy.
+// As a filter, the blank levels match every value.
+type CategoryPath struct {
+	Department  string `bson:"department,omitempty" json:"department,omitempty"`
+	Category    string `bson:"category,omitempty" json:"category,omitempty"`
+	Subcategory string `bson:"subcategory,omitempty" json:"subcategory,omitempty"`
+}
+
+// validate checks that narrower levels are only set along with broader levels.
+func (c CategoryPath) validate() error {
+	if c.Department == "" {
+		return errors.New("Category-filter requires the department")
+	}
+	if c.Subcategory != "" && c.Category == "" {
+		return errors.New("Category-filter requires the category to filter by subcategory")
+	}
+	return nil
+}
+
+// levelDepth returns the index of the hierarchy-level in Levels, or -1.
+func levelDepth(level string) int {
+	for i, l := range Levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// Product is a SKU in the catalogue, and its position in the category hierarchy.
+type Product struct {
+	SKU string `bson:"sku,omitempty" json:"sku,omitempty"`
+	CategoryPath
+}
+
+func (p *Product) UnmarshalBSON(in []byte) error {
+	m := make(map[string]interface{})
+	err := bson.Unmarshal(in, m)
+	if err != nil {
+		err = errors.Wrap(err, "Unmarshal Error")
+		return err
+	}
+
+	p.SKU, _ = m["sku"].(string)
+	p.Department, _ = m["department"].(string)
+	p.Category, _ = m["category"].(string)
+	p.Subcategory, _ = m["subcategory"].(string)
+	return nil
+}
+
+// ProductCategories returns the CategoryPaths of the SKUs in the catalogue,
+// keyed by SKU. SKUs not in the catalogue are left out.
+func ProductCategories(catalog *mongo.Collection, skus []string) (map[string]CategoryPath, error) {
+	findResults, err := catalog.Find(map[string]interface{}{
+		"sku": map[string]interface{}{
+			"$in": skus,
+		},
+	})
+	if err != nil {
+		err = errors.Wrap(err, "ProductCategories: Error finding products")
+		log.Println(err)
+		return nil, err
+	}
+
+	paths := map[string]CategoryPath{}
+	for _, v := range findResults {
+		product, assertOK := v.(*Product)
+		if !assertOK {
+			return nil, errors.New("ProductCategories: Error asserting find-result to Product")
+		}
+		paths[product.SKU] = product.CategoryPath
+	}
+	return paths, nil
+}
+
+// CategorySKUs returns the SKUs in the catalogue within the CategoryPath.
+func CategorySKUs(catalog *mongo.Collection, path CategoryPath) ([]string, error) {
+	filter := map[string]interface{}{
+		"department": path.Department,
+	}
+	if path.Category != "" {
+		filter["category"] = path.Category
+	}
+	if path.Subcategory != "" {
+		filter["subcategory"] = path.Subcategory
+	}
+
+	findResults, err := catalog.Find(filter)
+	if err != nil {
+		err = errors.Wrap(err, "CategorySKUs: Error finding products")
+		log.Println(err)
+		return nil, err
+	}
+
+	skus := make([]string, 0, len(findResults))
+	for _, v := range findResults {
+		product, assertOK := v.(*Product)
+		if !assertOK {
+			return nil, errors.New("CategorySKUs: Error asserting find-result to Product")
+		}
+		skus = append(skus, product.SKU)
+	}
+	return skus, nil
+}
+
+// drillDownSKUs returns the SKUs in the params' Category, limited to the
+// params' SKUs if any.
+func drillDownSKUs(catalog *mongo.Collection, params WasteItemParams) ([]string, error) {
+	skus, err := CategorySKUs(catalog, *params.Category)
+	if err != nil {
+		return nil, err
+	}
+	if params.SKU == nil {
+		return skus, nil
+	}
+
+	allowed := map[string]bool{}
+	for _, sku := range params.SKU.In {
+		allowed[sku] = true
+	}
+	filtered := []string{}
+	for _, sku := range skus {
+		if allowed[sku] {
+			filtered = append(filtered, sku)
+		}
+	}
+	return filtered, nil
+}
+
+// categorize sets the CategoryPath of each result from the catalogue.
+func categorize(catalog *mongo.Collection, results []ReportResult) error {
+	skus := make([]string, 0, len(results))
+	for _, r := range results {
+		skus = append(skus, r.SKU)
+	}
+	paths, err := ProductCategories(catalog, skus)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range results {
+		path := paths[r.SKU]
+		if path.Department == "" {
+			path.Department = Uncategorized
+		}
+		if path.Category == "" {
+			path.Category = Uncategorized
+		}
+		if path.Subcategory == "" {
+			path.Subcategory = Uncategorized
+		}
+		results[i].setPath(path)
+	}
+	return nil
+}
+
+// path returns the result's position in the category hierarchy.
+func (r ReportResult) path() CategoryPath {
+	return CategoryPath{
+		Department:  r.Department,
+		Category:    r.Category,
+		Subcategory: r.Subcategory,
+	}
+}
+
+func (r *ReportResult) setPath(path CategoryPath) {
+	r.Department = path.Department
+	r.Category = path.Category
+	r.Subcategory = path.Subcategory
+}
+
+// itemLabel returns the SKU and name, or the narrowest hierarchy-level
+// for rolled-up results.
+func (r ReportResult) itemLabel() string {
+	if r.SKU != "" {
+		return r.SKU + " " + r.Name
+	}
+	levels := []string{}
+	for _, l := range []string{r.Department, r.Category, r.Subcategory} {
+		if l != "" {
+			levels = append(levels, l)
+		}
+	}
+	return strings.Join(levels, " > ")
+}
+
+// pathAt returns the CategoryPath upto the hierarchy-level at depth.
+func pathAt(path CategoryPath, depth int) CategoryPath {
+	p := CategoryPath{
+		Department: path.Department,
+	}
+	if depth >= 1 {
+		p.Category = path.Category
+	}
+	if depth >= 2 {
+		p.Subcategory = path.Subcategory
+	}
+	return p
+}
+
+// resultKey is used to group and sort ReportResults by the hierarchy,
+// followed by the other group-by fields.
+func resultKey(r ReportResult) string {
+	return strings.Join([]string{
+		r.Department, r.Category, r.Subcategory,
+		r.SKU, r.Name, r.StoreID, r.Reason, r.Disposal,
+	}, "\x00")
+}
+
+// addWeights adds the weights and values of r to the ReportResult.
+func (t *ReportResult) addWeights(r ReportResult) {
+	t.WasteWeight += r.WasteWeight
+	t.TotalWeight += r.TotalWeight
+	t.WasteValue += r.WasteValue
+	t.TotalValue += r.TotalValue
+	if t.Unit == "" {
+		t.Unit = r.Unit
+	}
+	if t.Currency == "" {
+		t.Currency = r.Currency
+	}
+}
+
+// rollUpResults sums the categorized results at the hierarchy-level, keeping
+// the other group-by fields such as the store. The results' weights and values
+// are sums over their waste-events, so adding them gives each level's whole
+// waste, and not a sum of per-SKU averages. Each group of results is
+// followed by a Subtotal result for every broader level. The results are
+// sorted by the hierarchy.
+func rollUpResults(results []ReportResult, level string) []ReportResult {
+	depth := levelDepth(level)
+
+	rolled := []ReportResult{}
+	index := map[string]int{}
+	for _, r := range results {
+		leaf := r
+		if level != LevelSKU {
+			leaf = ReportResult{
+				StoreID:  r.StoreID,
+				Reason:   r.Reason,
+				Disposal: r.Disposal,
+			}
+			leaf.setPath(pathAt(r.path(), depth))
+		}
+		key := resultKey(leaf)
+		i, exists := index[key]
+		if !exists {
+			if level != LevelSKU {
+				leaf.addWeights(r)
+			}
+			index[key] = len(rolled)
+			rolled = append(rolled, leaf)
+			continue
+		}
+		rolled[i].addWeights(r)
+	}
+	sort.SliceStable(rolled, func(i, j int) bool {
+		return resultKey(rolled[i]) < resultKey(rolled[j])
+	})
+
+	// subtotals are the open subtotals for each broader level
+	out := make([]ReportResult, 0, len(rolled)*2)
+	subtotals := make([]*ReportResult, depth)
+	flush := func(from int) {
+		for k := len(subtotals) - 1; k >= from; k-- {
+			if subtotals[k] != nil {
+				out = append(out, *subtotals[k])
+				subtotals[k] = nil
+			}
+		}
+	}
+	for _, leaf := range rolled {
+		for k := range subtotals {
+			if subtotals[k] != nil && subtotals[k].path() != pathAt(leaf.path(), k) {
+				flush(k)
+				break
+			}
+		}
+		for k := range subtotals {
+			if subtotals[k] == nil {
+				subtotals[k] = &ReportResult{
+					Subtotal: true,
+				}
+				subtotals[k].setPath(pathAt(leaf.path(), k))
+			}
+			subtotals[k].addWeights(leaf)
+		}
+		out = append(out, leaf)
+	}
+	flush(0)
+	return out
+}
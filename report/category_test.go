@@ -0,0 +1,192 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Category hierarchy", func() {
+	// categorized are SKU-results as set by categorize
+	var categorized []ReportResult
+
+	BeforeEach(func() {
+		categorized = []ReportResult{
+			ReportResult{
+				Department: "produce", Category: "fruit", Subcategory: "apples",
+				SKU: "sku1", Name: "gala", WasteWeight: 10, TotalWeight: 100,
+			},
+			ReportResult{
+				Department: "produce", Category: "vegetables", Subcategory: "greens",
+				SKU: "sku3", Name: "kale", WasteWeight: 5, TotalWeight: 20,
+			},
+			ReportResult{
+				Department: "produce", Category: "fruit", Subcategory: "apples",
+				SKU: "sku2", Name: "fuji", WasteWeight: 20, TotalWeight: 100,
+			},
+			ReportResult{
+				Department: "bakery", Category: "bread", Subcategory: "loaves",
+				SKU: "sku4", Name: "rye", WasteWeight: 1, TotalWeight: 10,
+			},
+		}
+	})
+
+	It("drills-down and rolls-up using params", func() {
+		params := WasteItemParams{}
+		err := json.Unmarshal([]byte(`{
+			"category": {"department": "produce", "category": "fruit"},
+			"rollUp": "subcategory"
+		}`), &params)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params.Validate()).To(Succeed())
+		Expect(params.Category.Department).To(Equal("produce"))
+		Expect(params.RollUp).To(Equal(LevelSubcategory))
+
+		params.SKU = &InComparator{In: []string{"sku1", "sku2"}}
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline[0].Lookup("$match", "sku", "$in").MutableArray().Len()).To(Equal(2))
+	})
+
+	It("rejects unknown levels and incomplete category-filters", func() {
+		params := WasteItemParams{RollUp: "aisle"}
+		Expect(params.Validate()).ToNot(Succeed())
+
+		params = WasteItemParams{Category: &CategoryPath{Category: "fruit"}}
+		Expect(params.Validate()).ToNot(Succeed())
+
+		params = WasteItemParams{
+			Category: &CategoryPath{Department: "produce", Subcategory: "apples"},
+		}
+		Expect(params.Validate()).ToNot(Succeed())
+	})
+
+	It("rolls-up to category with department subtotals", func() {
+		results := rollUpResults(categorized, LevelCategory)
+
+		Expect(results).To(Equal([]ReportResult{
+			ReportResult{
+				Department: "bakery", Category: "bread", WasteWeight: 1, TotalWeight: 10,
+			},
+			ReportResult{
+				Department: "bakery", Subtotal: true, WasteWeight: 1, TotalWeight: 10,
+			},
+			ReportResult{
+				Department: "produce", Category: "fruit", WasteWeight: 30, TotalWeight: 200,
+			},
+			ReportResult{
+				Department: "produce", Category: "vegetables", WasteWeight: 5, TotalWeight: 20,
+			},
+			ReportResult{
+				Department: "produce", Subtotal: true, WasteWeight: 35, TotalWeight: 220,
+			},
+		}))
+	})
+
+	It("rolls-up the summed weights and values of aggregate-results", func() {
+		// sku1 has 4 waste-events of 5kg at 2.0, sku2 has one of 10kg at 1.0
+		results, err := ResultsFromAggregate([]interface{}{
+			map[string]interface{}{
+				"_id":              map[string]interface{}{"sku": "sku1"},
+				"sum_waste":        float64(20),
+				"sum_total":        float64(100),
+				"sum_cost":         float64(40),
+				"sum_priced_waste": float64(20),
+			},
+			map[string]interface{}{
+				"_id":              map[string]interface{}{"sku": "sku2"},
+				"sum_waste":        float64(10),
+				"sum_total":        float64(50),
+				"sum_cost":         float64(10),
+				"sum_priced_waste": float64(10),
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		err = Pricing{Currency: "USD"}.applyPricing(results)
+		Expect(err).ToNot(HaveOccurred())
+		for i := range results {
+			results[i].setPath(CategoryPath{
+				Department: "produce", Category: "fruit", Subcategory: "apples",
+			})
+		}
+
+		rolled := rollUpResults(results, LevelDepartment)
+		Expect(rolled).To(HaveLen(1))
+		Expect(rolled[0].WasteWeight).To(Equal(float64(30)))
+		Expect(rolled[0].TotalWeight).To(Equal(float64(150)))
+		Expect(rolled[0].WasteValue).To(Equal(float64(50)))
+		Expect(rolled[0].TotalValue).To(Equal(float64(250)))
+	})
+
+	It("keeps SKU-results with subtotals for every level", func() {
+		results := rollUpResults(categorized, LevelSKU)
+
+		skus := []string{}
+		for _, r := range results {
+			if r.Subtotal {
+				skus = append(skus, "subtotal:"+r.itemLabel())
+				continue
+			}
+			skus = append(skus, r.SKU)
+		}
+		Expect(skus).To(Equal([]string{
+			"sku4",
+			"subtotal:bakery > bread > loaves",
+			"subtotal:bakery > bread",
+			"subtotal:bakery",
+			"sku1",
+			"sku2",
+			"subtotal:produce > fruit > apples",
+			"subtotal:produce > fruit",
+			"sku3",
+			"subtotal:produce > vegetables > greens",
+			"subtotal:produce > vegetables",
+			"subtotal:produce",
+		}))
+	})
+
+	It("keeps stores separate when rolling-up", func() {
+		categorized[0].StoreID = "store1"
+		categorized[2].StoreID = "store2"
+		results := rollUpResults(categorized[:3], LevelDepartment)
+
+		Expect(results).To(HaveLen(3))
+		Expect(results[0].StoreID).To(BeEmpty())
+		Expect(results[0].WasteWeight).To(Equal(5.0))
+		Expect(results[1].StoreID).To(Equal("store1"))
+		Expect(results[2].StoreID).To(Equal("store2"))
+	})
+
+	It("leaves subtotals out of the summary", func() {
+		summary := Summarize(rollUpResults(categorized, LevelSKU), TopOffendersCount)
+
+		Expect(summary.WasteWeight).To(Equal(36.0))
+		Expect(summary.TotalWeight).To(Equal(230.0))
+		Expect(summary.TopOffenders).To(HaveLen(4))
+		Expect(summary.TopOffenders[0].SKU).To(Equal("sku2"))
+	})
+
+	It("writes hierarchy and subtotal columns", func() {
+		buf := &bytes.Buffer{}
+		err := WriteCSV(buf, &WasteReport{
+			SearchQuery: WasteItemParams{
+				RollUp: LevelCategory,
+			},
+			ReportResult: rollUpResults(categorized, LevelCategory),
+			CostsHidden:  true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		rows, err := csv.NewReader(buf).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows[0]).To(Equal([]string{
+			"department", "category", "subtotal", "sku", "name",
+			"wasteWeight", "totalWeight", "unit",
+		}))
+		Expect(rows[1]).To(Equal([]string{"bakery", "bread", "", "", "", "1", "10", ""}))
+		Expect(rows[2]).To(Equal([]string{"bakery", "", "subtotal", "", "", "1", "10", ""}))
+	})
+})
@@ -0,0 +1,179 @@
+package report
+
+import "strconv"
+
+// resultColumn is a single column when ReportResults are exported as a table.
+// Group-by columns have Text set, while metric columns have Metric set.
+// Money is set for metric columns having monetary values in the result's currency.
+type resultColumn struct {
+	Header string
+	Text   func(r ReportResult) string
+	Metric func(r ReportResult) float64
+	Money  bool
+}
+
+// Value returns the column's value for the ReportResult as string.
+func (c resultColumn) Value(r ReportResult) string {
+	if c.Metric != nil {
+		return formatFloat(c.Metric(r))
+	}
+	return c.Text(r)
+}
+
+// hierarchyColumns are the columns for the category hierarchy-levels of
+// rolled-up report-results, from the broadest.
+var hierarchyColumns = []resultColumn{
+	resultColumn{
+		Header: "department",
+		Text:   func(r ReportResult) string { return r.Department },
+	},
+	resultColumn{
+		Header: "category",
+		Text:   func(r ReportResult) string { return r.Category },
+	},
+	resultColumn{
+		Header: "subcategory",
+		Text:   func(r ReportResult) string { return r.Subcategory },
+	},
+}
+
+var subtotalColumn = resultColumn{
+	Header: "subtotal",
+	Text: func(r ReportResult) string {
+		if r.Subtotal {
+			return "subtotal"
+		}
+		return ""
+	},
+}
+
+var storeColumn = resultColumn{
+	Header: "storeID",
+	Text:   func(r ReportResult) string { return r.StoreID },
+}
+
+// itemColumns are the columns identifying the SKU of report-results.
+var itemColumns = []resultColumn{
+	resultColumn{
+		Header: "sku",
+		Text:   func(r ReportResult) string { return r.SKU },
+	},
+	resultColumn{
+		Header: "name",
+		Text:   func(r ReportResult) string { return r.Name },
+	},
+}
+
+var reasonColumn = resultColumn{
+	Header: "reason",
+	Text:   func(r ReportResult) string { return r.Reason },
+}
+
+var disposalColumn = resultColumn{
+	Header: "disposal",
+	Text:   func(r ReportResult) string { return r.Disposal },
+}
+
+// weightColumns are the columns for aggregated weights in report-results.
+var weightColumns = []resultColumn{
+	resultColumn{
+		Header: "wasteWeight",
+		Metric: func(r ReportResult) float64 { return r.WasteWeight },
+	},
+	resultColumn{
+		Header: "totalWeight",
+		Metric: func(r ReportResult) float64 { return r.TotalWeight },
+	},
+	resultColumn{
+		Header: "unit",
+		Text:   func(r ReportResult) string { return r.Unit },
+	},
+}
+
+// costColumns are the columns for the monetary fields in report-results.
+var costColumns = []resultColumn{
+	resultColumn{
+		Header: "unitCost",
+		Metric: func(r ReportResult) float64 { return r.UnitCost },
+		Money:  true,
+	},
+	resultColumn{
+		Header: "wasteValue",
+		Metric: func(r ReportResult) float64 { return r.WasteValue },
+		Money:  true,
+	},
+	resultColumn{
+		Header: "totalValue",
+		Metric: func(r ReportResult) float64 { return r.TotalValue },
+		Money:  true,
+	},
+	resultColumn{
+		Header: "currency",
+		Text:   func(r ReportResult) string { return r.Currency },
+	},
+}
+
+// resultColumns returns the columns for the report-results of the WasteReport.
+// The columns only depend on the report's group-by and roll-up options, and
+// on whether costs are hidden, so every report run with the same options has
+// the same columns, whatever its results.
+func (s *WasteReport) resultColumns() []resultColumn {
+	params := s.SearchQuery
+	columns := []resultColumn{}
+
+	if params.RollUp != "" {
+		depth := levelDepth(params.RollUp)
+		for i, c := range hierarchyColumns {
+			if i <= depth {
+				columns = append(columns, c)
+			}
+		}
+		// Subtotals are only added for levels broader than the roll-up level
+		if depth > 0 {
+			columns = append(columns, subtotalColumn)
+		}
+	}
+	if params.GroupByStore {
+		columns = append(columns, storeColumn)
+	}
+	columns = append(columns, itemColumns...)
+	if params.GroupByReason {
+		columns = append(columns, reasonColumn)
+	}
+	if params.GroupByDisposal {
+		columns = append(columns, disposalColumn)
+	}
+
+	columns = append(columns, weightColumns...)
+	if !s.CostsHidden {
+		columns = append(columns, costColumns...)
+	}
+	return columns
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatWeight formats weights for display in documents.
+func formatWeight(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// weightLabel adds the weight-unit to the label, if known.
+func weightLabel(label string, unit string) string {
+	if unit == "" {
+		return label
+	}
+	return label + " (" + unit + ")"
+}
+
+// formatMoney formats monetary values with their currency for display in documents.
+func formatMoney(f float64, currency string) string {
+	return strconv.FormatFloat(f, 'f', 2, 64) + " " + currency
+}
+
+// formatRatio formats ratios as percentage for display in documents.
+func formatRatio(f float64) string {
+	return strconv.FormatFloat(f*100, 'f', 2, 64) + "%"
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// WriteCSV writes the WasteReport's ReportResults as CSV to the provided writer.
+// The first row is the header-row, followed by a row for each ReportResult.
+// The header-row follows the report's options, even if there are no results.
+func WriteCSV(w io.Writer, wasteReport *WasteReport) error {
+	columns := wasteReport.resultColumns()
+	results := wasteReport.ReportResult
+
+	cw := csv.NewWriter(w)
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.Header
+	}
+	err := cw.Write(headers)
+	if err != nil {
+		err = errors.Wrap(err, "WriteCSV: Error writing header-row")
+		return err
+	}
+
+	for i, r := range results {
+		row := make([]string, len(columns))
+		for j, c := range columns {
+			row[j] = c.Value(r)
+		}
+		err = cw.Write(row)
+		if err != nil {
+			err = errors.Wrapf(err, "WriteCSV: Error writing row at index: %d", i)
+			return err
+		}
+	}
+
+	cw.Flush()
+	err = cw.Error()
+	if err != nil {
+		err = errors.Wrap(err, "WriteCSV: Error flushing CSV-writer")
+		return err
+	}
+	return nil
+}
+
+// WriteReportListCSV writes a row for each WasteReport with its reportID,
+// report-window and totals, for listing reports.
+func WriteReportListCSV(w io.Writer, reports []*WasteReport) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{
+		"reportID", "timestampGt", "timestampLt", "results", "wasteWeight", "totalWeight",
+	})
+	if err != nil {
+		err = errors.Wrap(err, "WriteReportListCSV: Error writing header-row")
+		return err
+	}
+
+	for i, r := range reports {
+		var gt, lt string
+		if r.SearchQuery.Timestamp != nil {
+			gt = formatFloat(r.SearchQuery.Timestamp.Gt)
+			lt = formatFloat(r.SearchQuery.Timestamp.Lt)
+		}
+		summary := Summarize(r.ReportResult, 0)
+		err = cw.Write([]string{
+			r.ReportID.String(),
+			gt,
+			lt,
+			strconv.Itoa(len(r.ReportResult)),
+			formatFloat(summary.WasteWeight),
+			formatFloat(summary.TotalWeight),
+		})
+		if err != nil {
+			err = errors.Wrapf(err, "WriteReportListCSV: Error writing row at index: %d", i)
+			return err
+		}
+	}
+
+	cw.Flush()
+	err = cw.Error()
+	if err != nil {
+		err = errors.Wrap(err, "WriteReportListCSV: Error flushing CSV-writer")
+		return err
+	}
+	return nil
+}
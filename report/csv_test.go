@@ -0,0 +1,120 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CSV export", func() {
+	results := []ReportResult{
+		ReportResult{
+			SKU:         "test-sku1",
+			Name:        "test-name1",
+			WasteWeight: 101.5,
+			TotalWeight: 120,
+		},
+		ReportResult{
+			SKU:         "test-sku2",
+			Name:        "name, with comma",
+			WasteWeight: 105,
+			TotalWeight: 140.25,
+		},
+	}
+
+	It("writes header-row followed by a row per result", func() {
+		buf := &bytes.Buffer{}
+		err := WriteCSV(buf, &WasteReport{
+			ReportResult: results,
+			CostsHidden:  true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		rows, err := csv.NewReader(buf).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows).To(Equal([][]string{
+			[]string{"sku", "name", "wasteWeight", "totalWeight", "unit"},
+			[]string{"test-sku1", "test-name1", "101.5", "120", ""},
+			[]string{"test-sku2", "name, with comma", "105", "140.25", ""},
+		}))
+	})
+
+	It("includes storeID column if results are grouped by store", func() {
+		buf := &bytes.Buffer{}
+		err := WriteCSV(buf, &WasteReport{
+			SearchQuery: WasteItemParams{
+				GroupByStore: true,
+			},
+			ReportResult: []ReportResult{
+				ReportResult{
+					StoreID:     "store-1",
+					SKU:         "test-sku1",
+					Name:        "test-name1",
+					WasteWeight: 10,
+					TotalWeight: 100,
+					Unit:        "kg",
+				},
+			},
+			CostsHidden: true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		rows, err := csv.NewReader(buf).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows).To(Equal([][]string{
+			[]string{"storeID", "sku", "name", "wasteWeight", "totalWeight", "unit"},
+			[]string{"store-1", "test-sku1", "test-name1", "10", "100", "kg"},
+		}))
+	})
+
+	It("writes the group-by and cost headers of an empty report", func() {
+		buf := &bytes.Buffer{}
+		err := WriteCSV(buf, &WasteReport{
+			SearchQuery: WasteItemParams{
+				GroupByStore:    true,
+				GroupByDisposal: true,
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		rows, err := csv.NewReader(buf).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows).To(Equal([][]string{
+			[]string{
+				"storeID", "sku", "name", "disposal", "wasteWeight", "totalWeight", "unit",
+				"unitCost", "wasteValue", "totalValue", "currency",
+			},
+		}))
+	})
+
+	It("keeps the storeID column when results have no storeID", func() {
+		buf := &bytes.Buffer{}
+		err := WriteCSV(buf, &WasteReport{
+			SearchQuery: WasteItemParams{
+				GroupByStore: true,
+			},
+			ReportResult: results,
+			CostsHidden:  true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		rows, err := csv.NewReader(buf).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows[0][0]).To(Equal("storeID"))
+		Expect(rows[1][0]).To(BeEmpty())
+	})
+
+	It("marshals results to JSON by default", func() {
+		out, err := MarshalReport(&WasteReport{ReportResult: results}, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out[0]).To(Equal(byte('[')))
+	})
+
+	It("returns error on unsupported format", func() {
+		Expect(ValidFormat("xml")).To(BeFalse())
+		_, err := MarshalReport(&WasteReport{ReportResult: results}, "xml")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,122 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Reasons why items are wasted.
+const (
+	ReasonExpired   = "expired"
+	ReasonDamaged   = "damaged"
+	ReasonRecalled  = "recalled"
+	ReasonQuality   = "quality"
+	ReasonOverstock = "overstock"
+)
+
+// Methods by which wasted items are disposed.
+const (
+	DisposalLandfill   = "landfill"
+	DisposalCompost    = "compost"
+	DisposalDonation   = "donation"
+	DisposalAnimalFeed = "animal_feed"
+)
+
+// Reasons lists all the known waste-reasons.
+var Reasons = []string{
+	ReasonExpired, ReasonDamaged, ReasonRecalled, ReasonQuality, ReasonOverstock,
+}
+
+// Disposals lists all the known disposal-methods.
+var Disposals = []string{
+	DisposalLandfill, DisposalCompost, DisposalDonation, DisposalAnimalFeed,
+}
+
+// checkCodes returns an error if any of the codes is not one of the known codes.
+func checkCodes(name string, codes []string, known []string) error {
+	for _, c := range codes {
+		found := false
+		for _, k := range known {
+			if c == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("Unknown %s: %q, must be one of: %v", name, c, known)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the params only filter by known reasons and
+// disposal-methods, and roll-up to a known hierarchy-level.
+func (p WasteItemParams) Validate() error {
+	if p.Reason != nil {
+		err := checkCodes("reason", p.Reason.In, Reasons)
+		if err != nil {
+			return err
+		}
+	}
+	if p.Disposal != nil {
+		err := checkCodes("disposal", p.Disposal.In, Disposals)
+		if err != nil {
+			return err
+		}
+	}
+	if p.Category != nil {
+		err := p.Category.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if p.RollUp != "" {
+		err := checkCodes("rollUp level", []string{p.RollUp}, Levels)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisposalShare is the waste-weight disposed by a disposal-method,
+// and its share of all waste-weight.
+type DisposalShare struct {
+	Disposal    string
+	WasteWeight float64
+	Share       float64
+}
+
+// disposalShares sums the waste-weight of results by disposal-method, sorted
+// by highest waste-weight first. Results without a disposal-method are left
+// out, so this is empty unless the results are grouped by disposal-method.
+// The results' weights are sums over their waste-events, so each share is
+// of the whole weight wasted, however many events each method had.
+func disposalShares(results []ReportResult, totalWaste float64) []DisposalShare {
+	byDisposal := map[string]float64{}
+	for _, r := range results {
+		if r.Disposal != "" {
+			byDisposal[r.Disposal] += r.WasteWeight
+		}
+	}
+
+	shares := make([]DisposalShare, 0, len(byDisposal))
+	for disposal, weight := range byDisposal {
+		share := DisposalShare{
+			Disposal:    disposal,
+			WasteWeight: weight,
+		}
+		if totalWaste != 0 {
+			share.Share = weight / totalWaste
+		}
+		shares = append(shares, share)
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].WasteWeight == shares[j].WasteWeight {
+			return shares[i].Disposal < shares[j].Disposal
+		}
+		return shares[i].WasteWeight > shares[j].WasteWeight
+	})
+	return shares
+}
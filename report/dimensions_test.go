@@ -0,0 +1,87 @@
+package report
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reason and disposal", func() {
+	It("filters and groups by reason and disposal", func() {
+		params := WasteItemParams{}
+		err := json.Unmarshal([]byte(`{
+			"timestamp": {"$gt": 9, "$lt": 21},
+			"reason": {"$in": ["expired", "damaged"]},
+			"disposal": {"$in": ["donation"]},
+			"groupByReason": true,
+			"groupByDisposal": true
+		}`), &params)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params.Validate()).To(Succeed())
+
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline[0].Lookup("$match", "reason", "$in").MutableArray().Len()).To(Equal(2))
+		Expect(pipeline[0].Lookup("$match", "disposal", "$in").MutableArray().Len()).To(Equal(1))
+		Expect(pipeline[1].Lookup("$group", "_id", "reason").StringValue()).To(Equal("$reason"))
+		Expect(pipeline[1].Lookup("$group", "_id", "disposal").StringValue()).To(Equal("$disposal"))
+	})
+
+	It("rejects unknown reasons and disposal-methods", func() {
+		params := WasteItemParams{
+			Reason: &InComparator{In: []string{"expired", "stolen"}},
+		}
+		Expect(params.Validate()).ToNot(Succeed())
+
+		params = WasteItemParams{
+			Disposal: &InComparator{In: []string{"incinerate"}},
+		}
+		Expect(params.Validate()).ToNot(Succeed())
+	})
+
+	It("summarizes waste by disposal-method", func() {
+		summary := Summarize([]ReportResult{
+			ReportResult{SKU: "sku1", Disposal: DisposalDonation, WasteWeight: 30, TotalWeight: 100},
+			ReportResult{SKU: "sku1", Disposal: DisposalLandfill, WasteWeight: 50, TotalWeight: 100},
+			ReportResult{SKU: "sku2", Disposal: DisposalDonation, WasteWeight: 10, TotalWeight: 40},
+			ReportResult{SKU: "sku2", Disposal: DisposalCompost, WasteWeight: 10, TotalWeight: 40},
+		}, TopOffendersCount)
+
+		Expect(summary.ByDisposal).To(Equal([]DisposalShare{
+			DisposalShare{Disposal: DisposalLandfill, WasteWeight: 50, Share: 0.5},
+			DisposalShare{Disposal: DisposalDonation, WasteWeight: 40, Share: 0.4},
+			DisposalShare{Disposal: DisposalCompost, WasteWeight: 10, Share: 0.1},
+		}))
+	})
+
+	It("shares the summed weights of aggregate-results", func() {
+		// 12 donations of 5kg each, and one landfill of 20kg
+		results, err := ResultsFromAggregate([]interface{}{
+			map[string]interface{}{
+				"_id":       map[string]interface{}{"sku": "sku1", "disposal": DisposalDonation},
+				"sum_waste": float64(60),
+				"sum_total": float64(200),
+			},
+			map[string]interface{}{
+				"_id":       map[string]interface{}{"sku": "sku1", "disposal": DisposalLandfill},
+				"sum_waste": float64(20),
+				"sum_total": float64(40),
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		summary := Summarize(results, TopOffendersCount)
+		Expect(summary.ByDisposal).To(Equal([]DisposalShare{
+			DisposalShare{Disposal: DisposalDonation, WasteWeight: 60, Share: 0.75},
+			DisposalShare{Disposal: DisposalLandfill, WasteWeight: 20, Share: 0.25},
+		}))
+	})
+
+	It("leaves out disposal-shares if not grouped by disposal", func() {
+		summary := Summarize([]ReportResult{
+			ReportResult{SKU: "sku1", WasteWeight: 30, TotalWeight: 100},
+		}, TopOffendersCount)
+		Expect(summary.ByDisposal).To(BeEmpty())
+	})
+})
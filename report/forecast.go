@@ -0,0 +1,378 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// Defaults and limits for the ForecastParams.
+const (
+	DefaultForecastDays = 7
+	MaxForecastDays     = 90
+	DefaultHistoryDays  = 56
+	MaxHistoryDays      = 365
+	DefaultConfidence   = 0.95
+)
+
+// The forecast-model is exponential smoothing with weekly (day-of-week)
+// seasonality, and without trend.
+const (
+	// seasonLength is the days in a season.
+	seasonLength = 7
+	// MinHistoryDays is the days of history a SKU needs to be forecast,
+	// being two seasons so the seasonality can be learned.
+	MinHistoryDays = 2 * seasonLength
+	// levelSmoothing and seasonSmoothing are how much the level and the
+	// seasonal-offsets adapt to each new day, from 0 to 1.
+	levelSmoothing  = 0.2
+	seasonSmoothing = 0.1
+)
+
+// confidenceZ maps the supported confidence-levels to their z-scores
+// in a normal distribution.
+var confidenceZ = map[float64]float64{
+	0.8:  1.2816,
+	0.9:  1.6449,
+	0.95: 1.9600,
+	0.99: 2.5758,
+}
+
+// ForecastParams are the filters and options for the forecast report.
+type ForecastParams struct {
+	SKU     *InComparator `json:"sku,omitempty"`
+	StoreID *InComparator `json:"storeID,omitempty"`
+	// GroupByStore forecasts each SKU per store. Otherwise the waste of the
+	// matched stores is summed.
+	GroupByStore bool `json:"groupByStore,omitempty"`
+	// Days is the number of days forecast. Defaults to DefaultForecastDays.
+	Days int `json:"days,omitempty"`
+	// HistoryDays is the number of days before AsOf the model is fitted
+	// on. Defaults to DefaultHistoryDays.
+	HistoryDays int `json:"historyDays,omitempty"`
+	// Confidence is the confidence-level of the bands, one of 0.8, 0.9, 0.95
+	// and 0.99. Defaults to DefaultConfidence.
+	Confidence float64 `json:"confidence,omitempty"`
+	// AsOf is the unix-timestamp the forecast is made at, such as for
+	// back-testing. The history ends, and the forecast starts, at the
+	// start of its UTC-day. Defaults to now.
+	AsOf int64 `json:"asOf,omitempty"`
+}
+
+// Stores returns the stores the params are limited to, or nil for all stores.
+func (p ForecastParams) Stores() []string {
+	if p.StoreID == nil {
+		return nil
+	}
+	return p.StoreID.In
+}
+
+// Validate checks the options and sets the defaults.
+func (p *ForecastParams) Validate() error {
+	if p.Days == 0 {
+		p.Days = DefaultForecastDays
+	}
+	if p.Days < 0 || p.Days > MaxForecastDays {
+		return errors.Errorf("Days must be from 1 to %d", MaxForecastDays)
+	}
+	if p.HistoryDays == 0 {
+		p.HistoryDays = DefaultHistoryDays
+	}
+	if p.HistoryDays < MinHistoryDays || p.HistoryDays > MaxHistoryDays {
+		return errors.Errorf("HistoryDays must be from %d to %d", MinHistoryDays, MaxHistoryDays)
+	}
+	if p.Confidence == 0 {
+		p.Confidence = DefaultConfidence
+	}
+	if _, ok := confidenceZ[p.Confidence]; !ok {
+		return errors.Errorf("Unsupported confidence: %g", p.Confidence)
+	}
+	if p.AsOf == 0 {
+		p.AsOf = time.Now().Unix()
+	}
+	return nil
+}
+
+// historyDays returns the start of the first UTC-day of the history,
+// and the start of the first forecast day.
+func (p ForecastParams) historyDays() (int64, int64) {
+	start := dayStart(p.AsOf)
+	return start - int64(p.HistoryDays)*secondsPerDay, start
+}
+
+// pipeline returns the aggregation-pipeline summing the waste per SKU
+// (and store) on each UTC-day of the history.
+func (p ForecastParams) pipeline() ([]*bson.Document, error) {
+	first, start := p.historyDays()
+	window := &Comparator{
+		// Timestamps are integers, so this includes the first day
+		Gt: float64(first - 1),
+		Lt: float64(start),
+	}
+
+	b := newPipelineBuilder()
+	b.matchField("timestamp", timestampConds(window)...)
+	b.matchIn("sku", p.SKU)
+	b.matchIn("storeID", p.StoreID)
+	if p.GroupByStore {
+		b.groupBy("sku", "name", "storeID")
+	} else {
+		b.groupBy("sku", "name")
+	}
+	b.groupByDay()
+	b.accumulate("wasteWeight", "$sum", "weight")
+	return b.build()
+}
+
+// ForecastDay is the expected waste on a day, with the confidence-band.
+type ForecastDay struct {
+	// Day is the start of the UTC-day, as a unix-timestamp.
+	Day      int64   `json:"day"`
+	Expected float64 `json:"expected"`
+	Lower    float64 `json:"lower"`
+	Upper    float64 `json:"upper"`
+}
+
+// ForecastResult is the forecast waste of a SKU (at a store, if grouped by
+// store), for each of the forecast days. Weights are in Unit.
+type ForecastResult struct {
+	SKU     string `json:"sku"`
+	Name    string `json:"name,omitempty"`
+	StoreID string `json:"storeID,omitempty"`
+	// HistoryDays is the days of history the model was fitted on.
+	HistoryDays int `json:"historyDays"`
+	// Expected is the total expected waste over the forecast days.
+	Expected   float64       `json:"expected"`
+	Confidence float64       `json:"confidence"`
+	Unit       string        `json:"unit"`
+	Days       []ForecastDay `json:"days"`
+}
+
+// ForecastReport returns the ForecastResult of each SKU (and store) having
+// waste in the history, sorted by SKU and store. The params must be valid.
+// SKUs with less than MinHistoryDays since their first waste in the
+// history are left out.
+func ForecastReport(params ForecastParams, itemWasteColl *mongo.Collection) ([]ForecastResult, error) {
+	pipeline, err := params.pipeline()
+	if err != nil {
+		err = errors.Wrap(err, "ForecastReport: Error in generating pipeline")
+		log.Println(err)
+		return nil, err
+	}
+	aggResults, err := itemWasteColl.Aggregate(pipeline)
+	if err != nil {
+		err = errors.Wrap(err, "ForecastReport: Error in getting aggregate results")
+		log.Println(err)
+		return nil, err
+	}
+
+	series, err := dailyWasteFromAggregate(aggResults)
+	if err != nil {
+		err = errors.Wrap(err, "ForecastReport: Error converting aggregate results")
+		log.Println(err)
+		return nil, err
+	}
+	return forecastResults(series, params), nil
+}
+
+// forecastResults fits the model to each dailyWaste, and forecasts it.
+func forecastResults(series []*dailyWaste, params ForecastParams) []ForecastResult {
+	first, start := params.historyDays()
+	z := confidenceZ[params.Confidence]
+
+	results := []ForecastResult{}
+	for _, s := range series {
+		// Days before the first waste are not counted as days without waste,
+		// since the SKU might not have been sold then.
+		from := first
+		if s.first > from {
+			from = s.first
+		}
+		values := []float64{}
+		for day := from; day < start; day += secondsPerDay {
+			values = append(values, s.days[day])
+		}
+		if len(values) < MinHistoryDays {
+			continue
+		}
+
+		model := fitSeasonal(values)
+		r := ForecastResult{
+			SKU:         s.sku,
+			Name:        s.name,
+			StoreID:     s.storeID,
+			HistoryDays: len(values),
+			Confidence:  params.Confidence,
+			Unit:        CanonicalUnit,
+			Days:        make([]ForecastDay, params.Days),
+		}
+		for h := 1; h <= params.Days; h++ {
+			expected := model.forecast(h)
+			band := z * model.stdDev(h)
+			r.Days[h-1] = ForecastDay{
+				Day:      start + int64(h-1)*secondsPerDay,
+				Expected: expected,
+				Lower:    math.Max(expected-band, 0),
+				Upper:    expected + band,
+			}
+			r.Expected += expected
+		}
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].SKU != results[j].SKU {
+			return results[i].SKU < results[j].SKU
+		}
+		return results[i].StoreID < results[j].StoreID
+	})
+	return results
+}
+
+// seasonalModel is exponential smoothing with additive seasonality,
+// fitted on the daily values.
+type seasonalModel struct {
+	level float64
+	// season are the offsets from the level for each day of the season,
+	// indexed by the day's position since the first value.
+	season []float64
+	// n is the number of values fitted
+	n int
+	// residualStdDev is the standard-deviation of the one-day-ahead errors
+	residualStdDev float64
+}
+
+// fitSeasonal fits the seasonalModel to the values, oldest first. There must
+// be atleast MinHistoryDays values. The first season initializes the level
+// and offsets, and the rest are smoothed.
+func fitSeasonal(values []float64) seasonalModel {
+	m := seasonalModel{
+		season: make([]float64, seasonLength),
+		n:      len(values),
+	}
+	for _, v := range values[:seasonLength] {
+		m.level += v / seasonLength
+	}
+	for i, v := range values[:seasonLength] {
+		m.season[i] = v - m.level
+	}
+
+	var sqErr float64
+	for t := seasonLength; t < len(values); t++ {
+		offset := m.season[t%seasonLength]
+		err := values[t] - (m.level + offset)
+		sqErr += err * err
+
+		level := levelSmoothing*(values[t]-offset) + (1-levelSmoothing)*m.level
+		m.season[t%seasonLength] = seasonSmoothing*(values[t]-level) + (1-seasonSmoothing)*offset
+		m.level = level
+	}
+	m.residualStdDev = math.Sqrt(sqErr / float64(len(values)-seasonLength))
+	return m
+}
+
+// forecast returns the expected value h days after the last fitted value.
+// Waste can't be negative, so the forecast is atleast 0.
+func (m seasonalModel) forecast(h int) float64 {
+	return math.Max(m.level+m.season[(m.n+h-1)%seasonLength], 0)
+}
+
+// stdDev returns the approximate standard-deviation of the error of the
+// forecast h days ahead, which grows with h as the level can drift.
+func (m seasonalModel) stdDev(h int) float64 {
+	return m.residualStdDev * math.Sqrt(1+float64(h-1)*levelSmoothing*levelSmoothing)
+}
+
+// ForecastInUnit returns a copy of the ForecastResults with weights
+// converted to the weight-unit.
+func ForecastInUnit(results []ForecastResult, unit string) ([]ForecastResult, error) {
+	if unit == "" {
+		unit = CanonicalUnit
+	}
+	converted := make([]ForecastResult, len(results))
+	for i, r := range results {
+		ratio, err := ConvertWeight(1, r.Unit, unit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting SKU %s", r.SKU)
+		}
+		r.Expected *= ratio
+		r.Unit = unit
+		days := make([]ForecastDay, len(r.Days))
+		for d, day := range r.Days {
+			day.Expected *= ratio
+			day.Lower *= ratio
+			day.Upper *= ratio
+			days[d] = day
+		}
+		r.Days = days
+		converted[i] = r
+	}
+	return converted, nil
+}
+
+// MarshalForecast converts the ForecastResults to the output-format, which
+// is either JSON, or CSV having a row per SKU and forecast day.
+func MarshalForecast(results []ForecastResult, format string) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(results)
+	case FormatCSV:
+		buf := &bytes.Buffer{}
+		err := WriteForecastCSV(buf, results)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, errors.Errorf("Unsupported output-format for forecast: %s", format)
+}
+
+// WriteForecastCSV writes a row for each day of each ForecastResult,
+// with the day as an RFC3339 date.
+func WriteForecastCSV(w io.Writer, results []ForecastResult) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{
+		"sku", "name", "storeID", "day", "expected", "lower", "upper", "confidence", "unit",
+	})
+	if err != nil {
+		err = errors.Wrap(err, "WriteForecastCSV: Error writing header-row")
+		return err
+	}
+
+	for i, r := range results {
+		for _, d := range r.Days {
+			err = cw.Write([]string{
+				r.SKU,
+				r.Name,
+				r.StoreID,
+				time.Unix(d.Day, 0).UTC().Format(time.RFC3339),
+				formatFloat(d.Expected),
+				formatFloat(d.Lower),
+				formatFloat(d.Upper),
+				formatFloat(r.Confidence),
+				r.Unit,
+			})
+			if err != nil {
+				err = errors.Wrapf(err, "WriteForecastCSV: Error writing rows at index: %d", i)
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	err = cw.Error()
+	if err != nil {
+		err = errors.Wrap(err, "WriteForecastCSV: Error flushing CSV-writer")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forecast", func() {
+	const day = secondsPerDay
+	// asOf is mid-day, so the forecast starts at the start of the day
+	const asOf = 100*day + 3600
+
+	var params ForecastParams
+
+	BeforeEach(func() {
+		params = ForecastParams{AsOf: asOf}
+		Expect(params.Validate()).To(Succeed())
+	})
+
+	// weekly returns days of waste over the weeks before the forecast,
+	// repeating the pattern for each day of the week.
+	weekly := func(weeks int, pattern [7]float64) map[int64]float64 {
+		days := map[int64]float64{}
+		for i := 0; i < weeks*7; i++ {
+			days[100*day-int64(weeks*7-i)*day] = pattern[i%7]
+		}
+		return days
+	}
+
+	It("validates the params and sets defaults", func() {
+		Expect(params.Days).To(Equal(DefaultForecastDays))
+		Expect(params.HistoryDays).To(Equal(DefaultHistoryDays))
+		Expect(params.Confidence).To(Equal(DefaultConfidence))
+
+		Expect((&ForecastParams{Days: MaxForecastDays + 1}).Validate()).ToNot(Succeed())
+		Expect((&ForecastParams{HistoryDays: 7}).Validate()).ToNot(Succeed())
+		Expect((&ForecastParams{Confidence: 0.5}).Validate()).ToNot(Succeed())
+	})
+
+	It("sums the waste per day over the history", func() {
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline[0].Lookup("$match", "timestamp", "$gt").Double()).To(
+			Equal(float64(100*day - DefaultHistoryDays*day - 1)),
+		)
+		Expect(pipeline[0].Lookup("$match", "timestamp", "$lt").Double()).To(Equal(float64(100 * day)))
+		Expect(pipeline[1].Lookup("$group", "_id", "storeID")).To(BeNil())
+
+		params.GroupByStore = true
+		pipeline, err = params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline[1].Lookup("$group", "_id", "storeID")).ToNot(BeNil())
+		Expect(pipeline[1].Lookup("$group", "_id", "day")).ToNot(BeNil())
+	})
+
+	It("repeats the weekly seasonality", func() {
+		pattern := [7]float64{10, 2, 2, 2, 2, 2, 10}
+		series := []*dailyWaste{
+			&dailyWaste{sku: "sku1", name: "gala", first: 100*day - 28*day, days: weekly(4, pattern)},
+		}
+		results := forecastResults(series, params)
+		Expect(results).To(HaveLen(1))
+
+		r := results[0]
+		Expect(r.HistoryDays).To(Equal(28))
+		Expect(r.Unit).To(Equal(CanonicalUnit))
+		Expect(r.Days).To(HaveLen(7))
+		for i, d := range r.Days {
+			Expect(d.Day).To(Equal(int64(100*day + i*day)))
+			Expect(d.Expected).To(BeNumerically("~", pattern[i], 1e-9))
+			// A perfect fit has no error
+			Expect(d.Lower).To(BeNumerically("~", d.Expected, 1e-9))
+			Expect(d.Upper).To(BeNumerically("~", d.Expected, 1e-9))
+		}
+		Expect(r.Expected).To(BeNumerically("~", 30, 1e-9))
+	})
+
+	It("widens the confidence-bands with noise and distance", func() {
+		days := weekly(8, [7]float64{5, 5, 5, 5, 5, 5, 5})
+		for d, v := range days {
+			if (d/day)%3 == 0 {
+				days[d] = v + 3
+			}
+		}
+		series := []*dailyWaste{
+			&dailyWaste{sku: "sku1", first: 100*day - 56*day, days: days},
+		}
+		r := forecastResults(series, params)[0]
+		Expect(r.Days[0].Upper).To(BeNumerically(">", r.Days[0].Expected))
+		Expect(r.Days[0].Lower).To(BeNumerically(">=", 0))
+		first := r.Days[0].Upper - r.Days[0].Expected
+		last := r.Days[6].Upper - r.Days[6].Expected
+		Expect(last).To(BeNumerically(">", first))
+
+		params.Confidence = 0.8
+		narrow := forecastResults(series, params)[0]
+		Expect(narrow.Days[0].Upper).To(BeNumerically("<", r.Days[0].Upper))
+	})
+
+	It("skips SKUs with too little history", func() {
+		series := []*dailyWaste{
+			&dailyWaste{sku: "sku1", first: 100*day - 10*day, days: map[int64]float64{100*day - 10*day: 4}},
+		}
+		Expect(forecastResults(series, params)).To(BeEmpty())
+	})
+
+	It("converts weights and writes a CSV-row per forecast day", func() {
+		results := []ForecastResult{
+			ForecastResult{
+				SKU: "sku1", StoreID: "store1", HistoryDays: 14, Expected: 3,
+				Confidence: 0.95, Unit: CanonicalUnit,
+				Days: []ForecastDay{
+					ForecastDay{Day: day, Expected: 1, Lower: 0.5, Upper: 1.5},
+					ForecastDay{Day: 2 * day, Expected: 2, Lower: 1, Upper: 3},
+				},
+			},
+		}
+		converted, err := ForecastInUnit(results, UnitGram)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(converted[0].Expected).To(BeNumerically("~", 3000, 1e-9))
+		Expect(converted[0].Days[1].Upper).To(BeNumerically("~", 3000, 1e-9))
+		Expect(results[0].Days[1].Upper).To(Equal(3.0))
+
+		doc, err := MarshalForecast(results, FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		rows, err := csv.NewReader(bytes.NewReader(doc)).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows).To(HaveLen(3))
+		Expect(rows[2]).To(Equal([]string{
+			"sku1", "", "store1", "1970-01-03T00:00:00Z", "2", "1", "3", "0.95", "kg",
+		}))
+	})
+})
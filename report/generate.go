@@ -0,0 +1,256 @@
+package report
+
+import (
+	"context"
+	"log"
+
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
+	"github.com/mongodb/mongo-go-driver/mongo/findopt"
+	"github.com/pkg/errors"
+)
+
+// GenerateReport runs the ItemWasteReport aggregation for the provided params,
+// prices the results using pricing, and stores the results as a new WasteReport
+// in reportColl. If the params drill-down or roll-up the category hierarchy,
+// the product-catalogue is used, and is otherwise allowed to be nil.
+// The aggregation and insert are traced as child-spans of the span in ctx.
+func GenerateReport(
+	ctx context.Context,
+	params WasteItemParams,
+	itemWasteColl *mongo.Collection,
+	reportColl *mongo.Collection,
+	pricing Pricing,
+	catalog *mongo.Collection,
+) (*WasteReport, error) {
+	aggParams, err := aggregateParams(ctx, params, catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	_, aggSpan := tracing.Tracer().Start(ctx, "mongo.aggregate")
+	aggResults, err := ItemWasteReport(aggParams, itemWasteColl)
+	if err != nil {
+		err = errors.Wrap(err, "Error getting results from ItemWasteCollection")
+		tracing.RecordError(aggSpan, err)
+		aggSpan.End()
+		return nil, err
+	}
+	aggSpan.End()
+	if len(aggResults) < 1 {
+		err = errors.New(
+			"Error: No result found from agg_itemwaste collection - Function = ItemWasteReport",
+		)
+		return nil, err
+	}
+
+	results, err := ResultsFromAggregate(aggResults)
+	if err != nil {
+		err = errors.Wrap(err, "Error converting aggregate-results to ReportResults")
+		return nil, err
+	}
+
+	_, priceSpan := tracing.Tracer().Start(ctx, "mongo.find_prices")
+	err = pricing.applyPricing(results)
+	if err != nil {
+		err = errors.Wrap(err, "Error pricing ReportResults")
+		tracing.RecordError(priceSpan, err)
+		priceSpan.End()
+		return nil, err
+	}
+	priceSpan.End()
+
+	if params.RollUp != "" {
+		_, rollUpSpan := tracing.Tracer().Start(ctx, "mongo.find_categories")
+		err = categorize(catalog, results)
+		if err != nil {
+			err = errors.Wrap(err, "Error categorizing ReportResults")
+			tracing.RecordError(rollUpSpan, err)
+			rollUpSpan.End()
+			return nil, err
+		}
+		rollUpSpan.End()
+		results = rollUpResults(results, params.RollUp)
+	}
+
+	reportID, err := uuuid.NewV4()
+	if err != nil {
+		err = errors.Wrap(err, "Error in generating reportID")
+		return nil, err
+	}
+	wasteReport := &WasteReport{
+		ReportID:     reportID,
+		SearchQuery:  params,
+		ReportResult: results,
+	}
+	err = storeReport(ctx, wasteReport, reportColl)
+	if err != nil {
+		return nil, err
+	}
+	return wasteReport, nil
+}
+
+// aggregateParams returns the params for the ItemWasteReport aggregation.
+// If the params drill-down the category hierarchy, the SKUs in the category
+// are found in the product-catalogue, and the params are limited to those.
+func aggregateParams(
+	ctx context.Context,
+	params WasteItemParams,
+	catalog *mongo.Collection,
+) (WasteItemParams, error) {
+	if catalog == nil && (params.Category != nil || params.RollUp != "") {
+		err := errors.New("Category hierarchy requires the product-catalogue collection")
+		return params, err
+	}
+
+	aggParams := params
+	if params.Category != nil {
+		_, drillSpan := tracing.Tracer().Start(ctx, "mongo.find_category_skus")
+		skus, err := drillDownSKUs(catalog, params)
+		if err != nil {
+			err = errors.Wrap(err, "Error finding SKUs in category")
+			tracing.RecordError(drillSpan, err)
+			drillSpan.End()
+			return params, err
+		}
+		drillSpan.End()
+		aggParams.SKU = &InComparator{
+			In: skus,
+		}
+	}
+	return aggParams, nil
+}
+
+// storeReport inserts the generated WasteReport into reportColl.
+func storeReport(ctx context.Context, wasteReport *WasteReport, reportColl *mongo.Collection) error {
+	_, insertSpan := tracing.Tracer().Start(ctx, "mongo.insert_report")
+	_, err := CreateReport(*wasteReport, reportColl)
+	if err != nil {
+		err = errors.Wrap(err, "Error in inserting report to mongo")
+		tracing.RecordError(insertSpan, err)
+		insertSpan.End()
+		return err
+	}
+	insertSpan.End()
+
+	metrics.ReportsGenerated.Inc()
+	metrics.ReportResultRows.Observe(float64(len(wasteReport.ReportResult)))
+	return nil
+}
+
+// ResultsFromAggregate converts the results from ItemWasteReport
+// aggregation to ReportResults.
+func ResultsFromAggregate(aggResults []interface{}) ([]ReportResult, error) {
+	results := make([]ReportResult, 0, len(aggResults))
+
+	for i, v := range aggResults {
+		m, assertOK := v.(map[string]interface{})
+		if !assertOK {
+			return nil, errors.Errorf(
+				"Error asserting aggregate-result at index %d to map[string]interface{}", i,
+			)
+		}
+		groupBy, assertOK := m["_id"].(map[string]interface{})
+		if !assertOK {
+			return nil, errors.Errorf(
+				"Error asserting _id of aggregate-result at index %d", i,
+			)
+		}
+
+		// The field-names here must match the ones in aggregate-pipeline
+		// in ItemWasteReport.
+		storeID, _ := groupBy["storeID"].(string)
+		sku, _ := groupBy["sku"].(string)
+		err := checkUnknownUnits(m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error in aggregate-result of SKU %s", sku)
+		}
+		name, _ := groupBy["name"].(string)
+		reason, _ := groupBy["reason"].(string)
+		disposal, _ := groupBy["disposal"].(string)
+		sumWaste, assertOK := m["sum_waste"].(float64)
+		if !assertOK {
+			return nil, errors.Errorf(
+				"Error asserting sum_waste of aggregate-result at index %d", i,
+			)
+		}
+		sumTotal, assertOK := m["sum_total"].(float64)
+		if !assertOK {
+			return nil, errors.Errorf(
+				"Error asserting sum_total of aggregate-result at index %d", i,
+			)
+		}
+		// The unit-cost is weighted by the wasted weight of the waste-events
+		// having it, and is 0 if none had it.
+		var unitCost float64
+		sumCost, _ := m["sum_cost"].(float64)
+		sumPricedWaste, _ := m["sum_priced_waste"].(float64)
+		if sumPricedWaste > 0 {
+			unitCost = sumCost / sumPricedWaste
+		}
+
+		results = append(results, ReportResult{
+			StoreID:     storeID,
+			SKU:         sku,
+			Name:        name,
+			Reason:      reason,
+			Disposal:    disposal,
+			WasteWeight: sumWaste,
+			TotalWeight: sumTotal,
+			Unit:        CanonicalUnit,
+			UnitCost:    unitCost,
+		})
+	}
+	return results, nil
+}
+
+// ListReports returns the stored WasteReports, newest first.
+// A limit of 0 returns all reports. If stores is not nil, only the reports
+// limited to some of those stores are returned.
+func ListReports(
+	reportColl *mongo.Collection,
+	stores []string,
+	limit int64,
+	skip int64,
+) ([]*WasteReport, error) {
+	filter := map[string]interface{}{}
+	if stores != nil {
+		// Only reports limited to a subset of the stores
+		filter["searchQuery.storeid.in"] = map[string]interface{}{
+			"$exists": true,
+			"$not": map[string]interface{}{
+				"$elemMatch": map[string]interface{}{
+					"$nin": stores,
+				},
+			},
+		}
+	}
+
+	findResults, err := reportColl.Find(
+		filter,
+		findopt.Sort(map[string]interface{}{
+			"_id": -1,
+		}),
+		findopt.Limit(limit),
+		findopt.Skip(skip),
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error listing reports")
+		log.Println(err)
+		return nil, err
+	}
+
+	reports := make([]*WasteReport, 0, len(findResults))
+	for _, v := range findResults {
+		wasteReport, assertOK := v.(*WasteReport)
+		if !assertOK {
+			err = errors.New("Error asserting find-result to WasteReport")
+			log.Println(err)
+			return nil, err
+		}
+		reports = append(reports, wasteReport)
+	}
+	return reports, nil
+}
@@ -0,0 +1,229 @@
+package report
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// chartMaxBars is the maximum number of bars in the waste-per-SKU chart.
+// Only the results with highest waste-weight are charted.
+const chartMaxBars = 25
+
+// Dimensions (in px) for the waste-per-SKU chart.
+const (
+	chartWidth      = 640
+	chartLabelWidth = 160
+	chartBarHeight  = 18
+	chartBarGap     = 6
+)
+
+type htmlChartBar struct {
+	Label      string
+	Value      string
+	Y          int
+	WasteWidth float64
+	TotalWidth float64
+}
+
+type htmlChart struct {
+	Width      int
+	Height     int
+	LabelWidth int
+	BarHeight  int
+	Bars       []htmlChartBar
+}
+
+type htmlReport struct {
+	ReportID    string
+	GeneratedAt string
+	From        string
+	To          string
+	Filters     string
+	WeightUnit  string
+	WasteWeight string
+	TotalWeight string
+	WasteRatio  string
+	WasteValue  string
+	TotalValue  string
+	ByDisposal  [][]string
+	Headers     []string
+	Offenders   [][]string
+	Rows        [][]string
+	Chart       htmlChart
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Waste Report {{.ReportID}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 12px; margin: 24px; }
+h1 { font-size: 18px; }
+h2 { font-size: 14px; margin-top: 24px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; }
+th { background: #eee; text-align: left; }
+td.num { text-align: right; }
+@media print { h2 { page-break-after: avoid; } table { page-break-inside: auto; } }
+</style>
+</head>
+<body>
+<h1>Waste Report</h1>
+<table>
+<tr><th>Report ID</th><td>{{.ReportID}}</td></tr>
+{{if .From}}<tr><th>Window</th><td>{{.From}} - {{.To}}</td></tr>{{end}}
+<tr><th>Filters</th><td>{{.Filters}}</td></tr>
+<tr><th>Total Waste Weight{{with .WeightUnit}} ({{.}}){{end}}</th><td class="num">{{.WasteWeight}}</td></tr>
+<tr><th>Total Weight{{with .WeightUnit}} ({{.}}){{end}}</th><td class="num">{{.TotalWeight}}</td></tr>
+<tr><th>Waste Ratio</th><td class="num">{{.WasteRatio}}</td></tr>
+{{if .WasteValue}}<tr><th>Total Waste Value</th><td class="num">{{.WasteValue}}</td></tr>
+<tr><th>Total Value</th><td class="num">{{.TotalValue}}</td></tr>{{end}}
+<tr><th>Generated At</th><td>{{.GeneratedAt}}</td></tr>
+</table>
+{{if .ByDisposal}}
+<h2>Waste by Disposal</h2>
+<table>
+<tr><th>Disposal</th><th>Waste Weight{{with .WeightUnit}} ({{.}}){{end}}</th><th>Share</th></tr>
+{{range .ByDisposal}}<tr><td>{{index . 0}}</td><td class="num">{{index . 1}}</td><td class="num">{{index . 2}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Waste per SKU</h2>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{.Chart.Width}}" height="{{.Chart.Height}}">
+{{- range .Chart.Bars}}
+<text x="0" y="{{.Y}}" dy="13" font-size="11">{{.Label}}</text>
+<rect x="{{$.Chart.LabelWidth}}" y="{{.Y}}" width="{{.TotalWidth}}" height="{{$.Chart.BarHeight}}" fill="#dddddd"></rect>
+<rect x="{{$.Chart.LabelWidth}}" y="{{.Y}}" width="{{.WasteWidth}}" height="{{$.Chart.BarHeight}}" fill="#c0392b"><title>{{.Value}}</title></rect>
+{{- end}}
+</svg>
+
+<h2>Top Offenders</h2>
+<table>
+<tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Offenders}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+
+<h2>Detail</h2>
+<table>
+<tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML renders the WasteReport as a self-contained HTML document,
+// with summary and result tables, and an inline SVG chart of waste per SKU.
+// The document uses no external resources, so it can be printed or archived as is.
+func WriteHTML(w io.Writer, wasteReport *WasteReport) error {
+	filters, err := json.Marshal(wasteReport.SearchQuery)
+	if err != nil {
+		err = errors.Wrap(err, "WriteHTML: Error marshalling SearchQuery")
+		return err
+	}
+
+	summary := Summarize(wasteReport.ReportResult, TopOffendersCount)
+	columns := wasteReport.resultColumns()
+	data := htmlReport{
+		ReportID:    wasteReport.ReportID.String(),
+		GeneratedAt: time.Now().UTC().Format(time.RFC1123),
+		Filters:     string(filters),
+		WeightUnit:  summary.Unit,
+		WasteWeight: formatWeight(summary.WasteWeight),
+		TotalWeight: formatWeight(summary.TotalWeight),
+		WasteRatio:  formatRatio(summary.WasteRatio),
+		Offenders:   tableRows(columns, summary.TopOffenders),
+		Rows:        tableRows(columns, wasteReport.ReportResult),
+		Chart:       wasteChart(wasteReport.ReportResult),
+	}
+	for _, c := range columns {
+		data.Headers = append(data.Headers, c.Header)
+	}
+	for _, d := range summary.ByDisposal {
+		data.ByDisposal = append(data.ByDisposal, []string{
+			d.Disposal, formatWeight(d.WasteWeight), formatRatio(d.Share),
+		})
+	}
+	if summary.Currency != "" {
+		data.WasteValue = formatMoney(summary.WasteValue, summary.Currency)
+		data.TotalValue = formatMoney(summary.TotalValue, summary.Currency)
+	}
+	ts := wasteReport.SearchQuery.Timestamp
+	if ts != nil {
+		data.From = time.Unix(int64(ts.Gt), 0).UTC().Format(time.RFC1123)
+		data.To = time.Unix(int64(ts.Lt), 0).UTC().Format(time.RFC1123)
+	}
+
+	err = htmlTemplate.Execute(w, data)
+	if err != nil {
+		err = errors.Wrap(err, "WriteHTML: Error executing template")
+		return err
+	}
+	return nil
+}
+
+func tableRows(columns []resultColumn, results []ReportResult) [][]string {
+	rows := make([][]string, len(results))
+	for i, r := range results {
+		rows[i] = make([]string, len(columns))
+		for j, c := range columns {
+			if c.Metric != nil {
+				rows[i][j] = formatWeight(c.Metric(r))
+			} else {
+				rows[i][j] = c.Text(r)
+			}
+		}
+	}
+	return rows
+}
+
+// wasteChart creates a horizontal bar-chart of waste-weight per SKU, with
+// the total-weight drawn behind each bar. Bar-widths are scaled to the
+// largest weight charted.
+func wasteChart(results []ReportResult) htmlChart {
+	sorted := Summarize(results, chartMaxBars).TopOffenders
+
+	var maxWeight float64
+	for _, r := range sorted {
+		if r.TotalWeight > maxWeight {
+			maxWeight = r.TotalWeight
+		}
+		if r.WasteWeight > maxWeight {
+			maxWeight = r.WasteWeight
+		}
+	}
+
+	chart := htmlChart{
+		Width:      chartWidth,
+		Height:     len(sorted) * (chartBarHeight + chartBarGap),
+		LabelWidth: chartLabelWidth,
+		BarHeight:  chartBarHeight,
+	}
+	barSpace := float64(chartWidth - chartLabelWidth)
+	for i, r := range sorted {
+		label := r.itemLabel()
+		if r.StoreID != "" {
+			label = r.StoreID + ": " + label
+		}
+		for _, dim := range []string{r.Reason, r.Disposal} {
+			if dim != "" {
+				label += " (" + dim + ")"
+			}
+		}
+		bar := htmlChartBar{
+			Label: label,
+			Value: formatWeight(r.WasteWeight),
+			Y:     i * (chartBarHeight + chartBarGap),
+		}
+		if maxWeight > 0 {
+			bar.WasteWidth = r.WasteWeight / maxWeight * barSpace
+			bar.TotalWidth = r.TotalWeight / maxWeight * barSpace
+		}
+		chart.Bars = append(chart.Bars, bar)
+	}
+	return chart
+}
@@ -44,6 +44,8 @@ func generateNewUUID() uuuid.UUID {
 }
 
 var productsName = []string{"Banana", "Orange", "Apple", "Mango", "Strawberry", "Tomato", "Lettuce", "Pear", "Grapes", "Sweet Pepper"}
+var stores = []string{"store-1", "store-2", "store-3"}
+var units = []string{UnitKilogram, UnitPound, UnitGram}
 var lot = []string{"A101", "B201", "O301", "M401", "S501", "T601", "L701", "P801", "G901", "SW1001"}
 
 func InsertItemWaste() WasteItem {
@@ -61,13 +63,22 @@ func InsertItemWaste() WasteItem {
 	item := WasteItem{
 		ItemID:      generateNewUUID(),
 		WasteID:     generateNewUUID(),
+		StoreID:     stores[rand.Intn(len(stores))],
 		SKU:         t,
 		Name:        name,
 		Lot:         lot,
 		TotalWeight: float64(randTotalWeight),
 		Weight:      float64(randWasteWeight),
+		Unit:        units[rand.Intn(len(units))],
+		Reason:      Reasons[rand.Intn(len(Reasons))],
+		Disposal:    Disposals[rand.Intn(len(Disposals))],
 		Timestamp:   timestamp,
 	}
+	// Only some waste-events carry the unit-cost,
+	// the rest are priced from the price-catalogue
+	if rand.Intn(2) == 0 {
+		item.UnitCost = float64(generateRandomValue(50, 500)) / 100
+	}
 
 	return item
 }
@@ -1,68 +1,55 @@
 package report
 
 import (
-	"encoding/json"
-	"fmt"
 	"log"
 
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
 	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
 	"github.com/mongodb/mongo-go-driver/bson"
 	mgo "github.com/mongodb/mongo-go-driver/mongo"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func ItemWasteReport(aggParams WasteItemParams, itemWasteColl *mongo.Collection) ([]interface{}, error) {
-
-	if aggParams.Timestamp.Lt == 0 || aggParams.Timestamp.Gt == 0 {
-		err := errors.New("Missing timestamp value")
-		log.Println(err)
+	pipelineAgg, err := aggParams.reportPipeline()
+	if err != nil {
 		return nil, err
 	}
-	input, err := json.Marshal(aggParams)
+
+	timer := prometheus.NewTimer(metrics.AggregationDuration)
+	findResult, err := itemWasteColl.Aggregate(pipelineAgg)
+	timer.ObserveDuration()
 	if err != nil {
-		err = errors.Wrap(err, "Unable to marshal aggParams")
+		err = errors.Wrap(err, "Query: Error in getting aggregate results ")
 		log.Println(err)
 		return nil, err
 	}
+	return findResult, nil
+}
 
-	log.Println(input)
-	log.Println(aggParams)
-
-	pipelineBuilder := fmt.Sprintf(`[
-		{
-			"$match": %s
-		},
-		{
-			"$group" : {
-			"_id" : {"sku" : "$sku","name":"$name"},
-			"avg_waste": {
-				"$avg": "$weight",
-			},
-			"avg_total": {
-				"$avg": "$totalWeight",
-			}
-		}
-		}
-	]`, input)
-
-	pipelineAgg, err := bson.ParseExtJSONArray(pipelineBuilder)
-	if err != nil {
-		err = errors.Wrap(err, "Query: Error in generating pipeline for report")
+// reportPipeline returns the aggregation-pipeline for ItemWasteReport,
+// which requires both timestamp bounds.
+func (p WasteItemParams) reportPipeline() ([]*bson.Document, error) {
+	if p.Timestamp == nil || p.Timestamp.Lt == 0 || p.Timestamp.Gt == 0 {
+		err := errors.New("Missing timestamp value")
 		log.Println(err)
 		return nil, err
 	}
-
-	findResult, err := itemWasteColl.Aggregate(pipelineAgg)
+	pipeline, err := p.pipeline()
 	if err != nil {
-		err = errors.Wrap(err, "Query: Error in getting aggregate results ")
+		err = errors.Wrap(err, "Query: Error in generating pipeline for report")
 		log.Println(err)
 		return nil, err
 	}
-	return findResult, nil
+	return pipeline, nil
 }
 
 func CreateReport(reportGen WasteReport, reportColl *mongo.Collection) (*mgo.InsertOneResult, error) {
+	timer := prometheus.NewTimer(metrics.ReportInsertDuration)
 	insertRep, err := reportColl.InsertOne(reportGen)
+	timer.ObserveDuration()
 	if err != nil {
 		err = errors.Wrap(err, "Query: Error in generating report ")
 		log.Println(err)
@@ -71,3 +58,29 @@ func CreateReport(reportGen WasteReport, reportColl *mongo.Collection) (*mgo.Ins
 
 	return insertRep, nil
 }
+
+// FindReport finds the stored WasteReport with the provided reportID.
+func FindReport(reportID uuuid.UUID, reportColl *mongo.Collection) (*WasteReport, error) {
+	findResult, err := reportColl.FindOne(map[string]interface{}{
+		"reportID": reportID.String(),
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Error finding report with ID: %s", reportID.String())
+		log.Println(err)
+		return nil, err
+	}
+
+	wasteReport, assertOK := findResult.(*WasteReport)
+	if !assertOK {
+		err = errors.New("Error asserting find-result to WasteReport")
+		log.Println(err)
+		return nil, err
+	}
+	return wasteReport, nil
+}
+
+// IsNotFound checks if the error is because no document matched the filter,
+// such as when FindReport is used with an unknown reportID.
+func IsNotFound(err error) bool {
+	return errors.Cause(err) == mgo.ErrNoDocuments
+}
@@ -155,7 +155,7 @@ var _ = Describe("Mongo service test", func() {
 		for _, v := range findResults {
 			m, assertOK := v.(map[string]interface{})
 			Expect(assertOK).To(BeTrue())
-			log.Println(m["avg_waste"])
+			log.Println(m["sum_waste"])
 			Expect(m["wasteID"]).To(Equal(item1.WasteID.String()))
 		}
 	})
@@ -178,7 +178,7 @@ var _ = Describe("Mongo service test", func() {
 			log.Println(m["_id"])
 			log.Println(m)
 
-			avgSold, assertOK := m["avg_waste"].(float64)
+			avgSold, assertOK := m["sum_waste"].(float64)
 			Expect(assertOK).To(BeTrue())
 			log.Println(avgSold)
 			if avgSold == item1.Weight {
@@ -241,7 +241,7 @@ var _ = Describe("Mongo service test", func() {
 			m, assertOK := v.(map[string]interface{})
 			Expect(assertOK).To(BeTrue())
 
-			avgSold, assertOK := m["avg_waste"].(float64)
+			avgSold, assertOK := m["sum_waste"].(float64)
 			Expect(assertOK).To(BeTrue())
 			log.Println(avgSold)
 			if avgSold == item1.Weight {
@@ -264,8 +264,8 @@ var _ = Describe("Mongo service test", func() {
 				ReportResult{
 					Name:        name,
 					SKU:         sku,
-					WasteWeight: m["avg_waste"].(float64),
-					TotalWeight: m["avg_total"].(float64),
+					WasteWeight: m["sum_waste"].(float64),
+					TotalWeight: m["sum_total"].(float64),
 				},
 			}
 		}
@@ -0,0 +1,462 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	util "github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// secondsPerDay is used to report the time between receiving
+// a lot and its first waste-event in days.
+const secondsPerDay = 24 * 60 * 60
+
+// LotInfo is a received lot in the lot-metadata collection.
+type LotInfo struct {
+	Lot      string `bson:"lot,omitempty" json:"lot,omitempty"`
+	SKU      string `bson:"sku,omitempty" json:"sku,omitempty"`
+	Supplier string `bson:"supplier,omitempty" json:"supplier,omitempty"`
+	// ReceivedAt is the unix-timestamp when the lot was received.
+	ReceivedAt int64 `bson:"receivedAt,omitempty" json:"receivedAt,omitempty"`
+	// ExpiresAt is the unix-timestamp when the lot expires, if known.
+	ExpiresAt int64 `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	// ReceivedWeight is the total weight received in the lot, in Unit.
+	ReceivedWeight float64 `bson:"receivedWeight,omitempty" json:"receivedWeight,omitempty"`
+	Unit           string  `bson:"unit,omitempty" json:"unit,omitempty"`
+}
+
+func (l *LotInfo) UnmarshalBSON(in []byte) error {
+	m := make(map[string]interface{})
+	err := bson.Unmarshal(in, m)
+	if err != nil {
+		err = errors.Wrap(err, "Unmarshal Error")
+		return err
+	}
+
+	l.Lot, _ = m["lot"].(string)
+	l.SKU, _ = m["sku"].(string)
+	l.Supplier, _ = m["supplier"].(string)
+	l.Unit, _ = m["unit"].(string)
+	if m["receivedAt"] != nil {
+		l.ReceivedAt, err = util.AssertInt64(m["receivedAt"])
+		if err != nil {
+			err = errors.Wrap(err, "Error while asserting ReceivedAt")
+			return err
+		}
+	}
+	if m["expiresAt"] != nil {
+		l.ExpiresAt, err = util.AssertInt64(m["expiresAt"])
+		if err != nil {
+			err = errors.Wrap(err, "Error while asserting ExpiresAt")
+			return err
+		}
+	}
+	if m["receivedWeight"] != nil {
+		l.ReceivedWeight, err = util.AssertFloat64(m["receivedWeight"])
+		if err != nil {
+			err = errors.Wrap(err, "Error while asserting ReceivedWeight")
+			return err
+		}
+	}
+	return nil
+}
+
+// LotTraceParams are the filters for the lot-traceability report.
+type LotTraceParams struct {
+	// Timestamp limits the waste-events to the time-window.
+	Timestamp *Comparator   `json:"timestamp,omitempty"`
+	Lot       *InComparator `json:"lot,omitempty"`
+	// Supplier limits the report to lots from the listed suppliers,
+	// and requires the lot-metadata collection.
+	Supplier *InComparator `json:"supplier,omitempty"`
+	StoreID  *InComparator `json:"storeID,omitempty"`
+}
+
+// Validate checks that the params are bounded, by listing the lots or
+// having both timestamp $gt and $lt, so they can't match every waste-event.
+func (p LotTraceParams) Validate() error {
+	if p.Lot != nil && len(p.Lot.In) > 0 {
+		return nil
+	}
+	if p.Timestamp == nil || p.Timestamp.Gt == 0 || p.Timestamp.Lt == 0 {
+		return errors.New("Lots, or both timestamp $gt and $lt are required")
+	}
+	if p.Timestamp.Lt <= p.Timestamp.Gt {
+		return errors.New("Timestamp $lt must be after $gt")
+	}
+	return nil
+}
+
+// Stores returns the stores the params are limited to, or nil for all stores.
+func (p LotTraceParams) Stores() []string {
+	if p.StoreID == nil {
+		return nil
+	}
+	return p.StoreID.In
+}
+
+// LotWasteEvent is a single waste-event against a lot.
+type LotWasteEvent struct {
+	WasteID   string  `json:"wasteID,omitempty"`
+	StoreID   string  `json:"storeID,omitempty"`
+	Reason    string  `json:"reason,omitempty"`
+	Disposal  string  `json:"disposal,omitempty"`
+	Weight    float64 `json:"weight,omitempty"`
+	Timestamp int64   `json:"timestamp,omitempty"`
+}
+
+// LotTrace is the waste of a lot, with the lot's supplier and receipt if
+// known in the lot-metadata. Weights are in Unit.
+type LotTrace struct {
+	Lot            string  `json:"lot"`
+	SKU            string  `json:"sku,omitempty"`
+	Name           string  `json:"name,omitempty"`
+	Supplier       string  `json:"supplier,omitempty"`
+	ReceivedAt     int64   `json:"receivedAt,omitempty"`
+	ReceivedWeight float64 `json:"receivedWeight,omitempty"`
+	WasteWeight    float64 `json:"wasteWeight"`
+	// WasteRatio is WasteWeight/ReceivedWeight, and is 0 if the
+	// ReceivedWeight is unknown.
+	WasteRatio float64 `json:"wasteRatio,omitempty"`
+	// DaysToFirstWaste is the days from receiving the lot to its first
+	// waste-event, and is 0 if ReceivedAt is unknown.
+	DaysToFirstWaste float64 `json:"daysToFirstWaste,omitempty"`
+	Unit             string  `json:"unit"`
+	// Events is the timeline of waste-events against the lot, oldest first.
+	Events []LotWasteEvent `json:"events"`
+}
+
+// pipeline returns the aggregation-pipeline listing the matched
+// WasteItems, oldest first.
+func (p LotTraceParams) pipeline() ([]*bson.Document, error) {
+	b := newPipelineBuilder()
+	b.matchField("timestamp", timestampConds(p.Timestamp)...)
+	b.matchIn("lot", p.Lot)
+	b.matchIn("storeID", p.StoreID)
+	stages, err := b.buildMatch()
+	if err != nil {
+		return nil, err
+	}
+	return append(stages, bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$sort", bson.EC.Int32("timestamp", 1)),
+	)), nil
+}
+
+// FindLots returns the LotInfos in the lot-metadata collection matching the
+// filter, keyed by lot.
+func FindLots(lotColl *mongo.Collection, filter map[string]interface{}) (map[string]LotInfo, error) {
+	findResults, err := lotColl.Find(filter)
+	if err != nil {
+		err = errors.Wrap(err, "FindLots: Error finding lots")
+		log.Println(err)
+		return nil, err
+	}
+
+	lots := map[string]LotInfo{}
+	for _, v := range findResults {
+		info, assertOK := v.(*LotInfo)
+		if !assertOK {
+			return nil, errors.New("FindLots: Error asserting find-result to LotInfo")
+		}
+		lots[info.Lot] = *info
+	}
+	return lots, nil
+}
+
+// supplierLots narrows the params' lots to the ones from its suppliers.
+func (p LotTraceParams) supplierLots(lotColl *mongo.Collection) (*InComparator, error) {
+	if lotColl == nil {
+		return nil, errors.New("Filtering by supplier requires the lot-metadata collection")
+	}
+	lots, err := FindLots(lotColl, map[string]interface{}{
+		"supplier": map[string]interface{}{
+			"$in": p.Supplier.In,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := map[string]bool{}
+	if p.Lot != nil {
+		for _, lot := range p.Lot.In {
+			allowed[lot] = true
+		}
+	}
+	filter := &InComparator{
+		In: []string{},
+	}
+	for lot := range lots {
+		if p.Lot == nil || allowed[lot] {
+			filter.In = append(filter.In, lot)
+		}
+	}
+	sort.Strings(filter.In)
+	return filter, nil
+}
+
+// LotTraces returns the LotTrace for each lot having waste-events matching
+// the params, sorted by lot. The lots are joined with the lot-metadata
+// collection if lotColl is not nil. Weights are in the CanonicalUnit.
+func LotTraces(
+	params LotTraceParams,
+	itemWasteColl *mongo.Collection,
+	lotColl *mongo.Collection,
+) ([]LotTrace, error) {
+	err := params.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "LotTraces: Invalid params")
+		return nil, err
+	}
+	if params.Supplier != nil {
+		lots, err := params.supplierLots(lotColl)
+		if err != nil {
+			return nil, err
+		}
+		if len(lots.In) == 0 {
+			return []LotTrace{}, nil
+		}
+		params.Lot = lots
+	}
+
+	pipeline, err := params.pipeline()
+	if err != nil {
+		err = errors.Wrap(err, "LotTraces: Error in generating pipeline")
+		log.Println(err)
+		return nil, err
+	}
+	aggResults, err := itemWasteColl.Aggregate(pipeline)
+	if err != nil {
+		err = errors.Wrap(err, "LotTraces: Error in getting aggregate results")
+		log.Println(err)
+		return nil, err
+	}
+
+	traces, err := lotTracesFromItems(aggResults)
+	if err != nil {
+		return nil, err
+	}
+	if lotColl == nil || len(traces) == 0 {
+		return traces, nil
+	}
+
+	lotIDs := make([]string, len(traces))
+	for i, t := range traces {
+		lotIDs[i] = t.Lot
+	}
+	infos, err := FindLots(lotColl, map[string]interface{}{
+		"lot": map[string]interface{}{
+			"$in": lotIDs,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range traces {
+		info, ok := infos[traces[i].Lot]
+		if !ok {
+			continue
+		}
+		err = traces[i].setInfo(info)
+		if err != nil {
+			return nil, errors.Wrapf(err, "LotTraces: Error joining lot %s", info.Lot)
+		}
+	}
+	return traces, nil
+}
+
+// lotTracesFromItems groups the WasteItems from the lot-trace pipeline by lot.
+// Items without a lot are left out.
+func lotTracesFromItems(aggResults []interface{}) ([]LotTrace, error) {
+	index := map[string]int{}
+	traces := []LotTrace{}
+
+	for i, v := range aggResults {
+		m, assertOK := v.(map[string]interface{})
+		if !assertOK {
+			return nil, errors.Errorf(
+				"Error asserting aggregate-result at index %d to map[string]interface{}", i,
+			)
+		}
+		lot, _ := m["lot"].(string)
+		if lot == "" {
+			continue
+		}
+
+		unit, _ := m["unit"].(string)
+		factor, err := unitFactor(unit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting aggregate-result at index %d", i)
+		}
+		var weight float64
+		if m["weight"] != nil {
+			weight, err = util.AssertFloat64(m["weight"])
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error asserting weight of aggregate-result at index %d", i)
+			}
+		}
+		var timestamp int64
+		if m["timestamp"] != nil {
+			timestamp, err = util.AssertInt64(m["timestamp"])
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error asserting timestamp of aggregate-result at index %d", i)
+			}
+		}
+
+		event := LotWasteEvent{
+			Weight:    weight * factor,
+			Timestamp: timestamp,
+		}
+		event.WasteID, _ = m["wasteID"].(string)
+		event.StoreID, _ = m["storeID"].(string)
+		event.Reason, _ = m["reason"].(string)
+		event.Disposal, _ = m["disposal"].(string)
+
+		t, exists := index[lot]
+		if !exists {
+			t = len(traces)
+			index[lot] = t
+			trace := LotTrace{
+				Lot:    lot,
+				Unit:   CanonicalUnit,
+				Events: []LotWasteEvent{},
+			}
+			trace.SKU, _ = m["sku"].(string)
+			trace.Name, _ = m["name"].(string)
+			traces = append(traces, trace)
+		}
+		traces[t].WasteWeight += event.Weight
+		traces[t].Events = append(traces[t].Events, event)
+	}
+
+	for i := range traces {
+		sort.SliceStable(traces[i].Events, func(a, b int) bool {
+			return traces[i].Events[a].Timestamp < traces[i].Events[b].Timestamp
+		})
+	}
+	sort.Slice(traces, func(i, j int) bool {
+		return traces[i].Lot < traces[j].Lot
+	})
+	return traces, nil
+}
+
+// setInfo sets the supplier and receipt of the lot, and the metrics
+// derived from them.
+func (t *LotTrace) setInfo(info LotInfo) error {
+	received, err := ConvertWeight(info.ReceivedWeight, info.Unit, t.Unit)
+	if err != nil {
+		return err
+	}
+	t.Supplier = info.Supplier
+	t.ReceivedAt = info.ReceivedAt
+	t.ReceivedWeight = received
+	if t.SKU == "" {
+		t.SKU = info.SKU
+	}
+
+	if t.ReceivedWeight != 0 {
+		t.WasteRatio = t.WasteWeight / t.ReceivedWeight
+	}
+	if t.ReceivedAt != 0 && len(t.Events) > 0 {
+		t.DaysToFirstWaste = float64(t.Events[0].Timestamp-t.ReceivedAt) / secondsPerDay
+	}
+	return nil
+}
+
+// LotTracesInUnit returns a copy of the LotTraces with weights converted to
+// the weight-unit.
+func LotTracesInUnit(traces []LotTrace, unit string) ([]LotTrace, error) {
+	if unit == "" {
+		unit = CanonicalUnit
+	}
+	converted := make([]LotTrace, len(traces))
+	for i, t := range traces {
+		ratio, err := ConvertWeight(1, t.Unit, unit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting lot %s", t.Lot)
+		}
+		t.ReceivedWeight *= ratio
+		t.WasteWeight *= ratio
+		t.Unit = unit
+		events := make([]LotWasteEvent, len(t.Events))
+		for j, e := range t.Events {
+			e.Weight *= ratio
+			events[j] = e
+		}
+		t.Events = events
+		converted[i] = t
+	}
+	return converted, nil
+}
+
+// MarshalLotTraces converts the LotTraces to the output-format, which is
+// either JSON, or CSV having a row per lot without the events.
+func MarshalLotTraces(traces []LotTrace, format string) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(traces)
+	case FormatCSV:
+		buf := &bytes.Buffer{}
+		err := WriteLotTraceCSV(buf, traces)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, errors.Errorf("Unsupported output-format for lot-traces: %s", format)
+}
+
+// WriteLotTraceCSV writes a row for each LotTrace, having its totals
+// and the number of waste-events.
+func WriteLotTraceCSV(w io.Writer, traces []LotTrace) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{
+		"lot", "sku", "name", "supplier", "receivedAt", "receivedWeight",
+		"wasteWeight", "wasteRatio", "daysToFirstWaste", "events", "unit",
+	})
+	if err != nil {
+		err = errors.Wrap(err, "WriteLotTraceCSV: Error writing header-row")
+		return err
+	}
+
+	for i, t := range traces {
+		var receivedAt string
+		if t.ReceivedAt != 0 {
+			receivedAt = time.Unix(t.ReceivedAt, 0).UTC().Format(time.RFC3339)
+		}
+		err = cw.Write([]string{
+			t.Lot,
+			t.SKU,
+			t.Name,
+			t.Supplier,
+			receivedAt,
+			formatFloat(t.ReceivedWeight),
+			formatFloat(t.WasteWeight),
+			formatFloat(t.WasteRatio),
+			formatFloat(t.DaysToFirstWaste),
+			strconv.Itoa(len(t.Events)),
+			t.Unit,
+		})
+		if err != nil {
+			err = errors.Wrapf(err, "WriteLotTraceCSV: Error writing row at index: %d", i)
+			return err
+		}
+	}
+
+	cw.Flush()
+	err = cw.Error()
+	if err != nil {
+		err = errors.Wrap(err, "WriteLotTraceCSV: Error flushing CSV-writer")
+		return err
+	}
+	return nil
+}
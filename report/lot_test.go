@@ -0,0 +1,144 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lot traceability", func() {
+	// items are WasteItems as returned by the lot-trace pipeline
+	var items []interface{}
+
+	BeforeEach(func() {
+		items = []interface{}{
+			map[string]interface{}{
+				"lot": "lot2", "sku": "sku2", "name": "kale", "storeID": "store1",
+				"weight": 2.0, "timestamp": int64(400), "reason": ReasonExpired,
+			},
+			map[string]interface{}{
+				"lot": "lot1", "sku": "sku1", "name": "gala", "storeID": "store1",
+				"weight": 1000.0, "unit": UnitGram, "timestamp": int64(86400 * 3),
+			},
+			map[string]interface{}{
+				"sku": "sku3", "weight": 7.0, "timestamp": int64(100),
+			},
+			map[string]interface{}{
+				"lot": "lot1", "sku": "sku1", "name": "gala", "storeID": "store2",
+				"weight": 4.0, "timestamp": int64(86400 * 2), "reason": ReasonDamaged,
+			},
+		}
+	})
+
+	It("builds the pipeline from whitelisted filters", func() {
+		params := LotTraceParams{}
+		err := json.Unmarshal([]byte(`{
+			"timestamp": {"$gt": 9, "$lt": 21},
+			"lot": {"$in": ["lot1", "lot2"]},
+			"storeID": {"$in": ["store1"]}
+		}`), &params)
+		Expect(err).ToNot(HaveOccurred())
+
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline).To(HaveLen(2))
+		Expect(pipeline[0].Lookup("$match", "lot", "$in").MutableArray().Len()).To(Equal(2))
+		Expect(pipeline[0].Lookup("$match", "timestamp", "$gt").Double()).To(Equal(9.0))
+		Expect(pipeline[1].Lookup("$sort", "timestamp").Int32()).To(Equal(int32(1)))
+	})
+
+	It("requires lots or a bounded time-window", func() {
+		Expect(LotTraceParams{}.Validate()).ToNot(Succeed())
+		Expect(LotTraceParams{
+			Supplier:  &InComparator{In: []string{"acme"}},
+			Timestamp: &Comparator{Gt: 9},
+		}.Validate()).ToNot(Succeed())
+		Expect(LotTraceParams{
+			Lot: &InComparator{In: []string{}},
+		}.Validate()).ToNot(Succeed())
+		Expect(LotTraceParams{
+			Timestamp: &Comparator{Gt: 21, Lt: 9},
+		}.Validate()).ToNot(Succeed())
+
+		Expect(LotTraceParams{
+			Lot: &InComparator{In: []string{"lot1"}},
+		}.Validate()).To(Succeed())
+		Expect(LotTraceParams{
+			Supplier:  &InComparator{In: []string{"acme"}},
+			Timestamp: &Comparator{Gt: 9, Lt: 21},
+		}.Validate()).To(Succeed())
+	})
+
+	It("groups waste-events by lot", func() {
+		traces, err := lotTracesFromItems(items)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(traces).To(HaveLen(2))
+		Expect(traces[0].Lot).To(Equal("lot1"))
+		Expect(traces[0].WasteWeight).To(Equal(5.0))
+		Expect(traces[0].Unit).To(Equal(CanonicalUnit))
+		Expect(traces[0].Events).To(Equal([]LotWasteEvent{
+			LotWasteEvent{StoreID: "store2", Reason: ReasonDamaged, Weight: 4, Timestamp: 86400 * 2},
+			LotWasteEvent{StoreID: "store1", Weight: 1, Timestamp: 86400 * 3},
+		}))
+		Expect(traces[1].Lot).To(Equal("lot2"))
+		Expect(traces[1].Name).To(Equal("kale"))
+	})
+
+	It("joins the supplier and receipt of lots", func() {
+		traces, err := lotTracesFromItems(items)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = traces[0].setInfo(LotInfo{
+			Lot:            "lot1",
+			Supplier:       "acme",
+			ReceivedAt:     86400 / 2,
+			ReceivedWeight: 50,
+			Unit:           UnitKilogram,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(traces[0].Supplier).To(Equal("acme"))
+		Expect(traces[0].WasteRatio).To(Equal(0.1))
+		Expect(traces[0].DaysToFirstWaste).To(Equal(1.5))
+
+		Expect(traces[1].setInfo(LotInfo{Lot: "lot2", Unit: "stone"})).ToNot(Succeed())
+	})
+
+	It("converts lot-traces to the weight-unit", func() {
+		traces, err := lotTracesFromItems(items)
+		Expect(err).ToNot(HaveOccurred())
+		traces[0].ReceivedWeight = 50
+
+		converted, err := LotTracesInUnit(traces, UnitGram)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(converted[0].Unit).To(Equal(UnitGram))
+		Expect(converted[0].WasteWeight).To(BeNumerically("~", 5000, 1e-9))
+		Expect(converted[0].ReceivedWeight).To(BeNumerically("~", 50000, 1e-9))
+		Expect(converted[0].Events[0].Weight).To(BeNumerically("~", 4000, 1e-9))
+		// The original traces are not modified
+		Expect(traces[0].Events[0].Weight).To(Equal(4.0))
+	})
+
+	It("writes a CSV-row per lot", func() {
+		traces, err := lotTracesFromItems(items)
+		Expect(err).ToNot(HaveOccurred())
+		err = traces[0].setInfo(LotInfo{Lot: "lot1", Supplier: "acme", ReceivedAt: 86400})
+		Expect(err).ToNot(HaveOccurred())
+
+		doc, err := MarshalLotTraces(traces, FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		rows, err := csv.NewReader(bytes.NewReader(doc)).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows).To(HaveLen(3))
+		Expect(rows[1]).To(Equal([]string{
+			"lot1", "sku1", "gala", "acme", "1970-01-02T00:00:00Z", "0",
+			"5", "0", "1", "2", "kg",
+		}))
+
+		_, err = MarshalLotTraces(traces, FormatXLSX)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -9,31 +9,96 @@ import (
 )
 
 type WasteItem struct {
-	ID          objectid.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
-	ItemID      uuuid.UUID        `bson:"itemID,omitempty" json:"itemID,omitempty"`
-	WasteID     uuuid.UUID        `bson:"wasteID,omitempty" json:"wasteID,omitempty"`
-	SKU         string            `bson:"sku,omitempty" json:"sku,omitempty"`
-	Name        string            `bson:"name,omitempty" json:"name,omitempty"`
-	Lot         string            `bson:"lot,omitempty" json:"lot,omitempty"`
-	Weight      float64           `bson:"weight,omitempty" json:"weight,omitempty"`
-	TotalWeight float64           `bson:"totalWeight,omitempty" json:"totalWeight,omitempty"`
-	Timestamp   int64             `bson:"timestamp,omitempty" json:"timestamp,omitempty"`
+	ID      objectid.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	ItemID  uuuid.UUID        `bson:"itemID,omitempty" json:"itemID,omitempty"`
+	WasteID uuuid.UUID        `bson:"wasteID,omitempty" json:"wasteID,omitempty"`
+	StoreID string            `bson:"storeID,omitempty" json:"storeID,omitempty"`
+	SKU     string            `bson:"sku,omitempty" json:"sku,omitempty"`
+	Name    string            `bson:"name,omitempty" json:"name,omitempty"`
+	Lot     string            `bson:"lot,omitempty" json:"lot,omitempty"`
+	// Reason is why the item was wasted, such as "expired".
+	Reason string `bson:"reason,omitempty" json:"reason,omitempty"`
+	// Disposal is how the wasted item was disposed, such as "donation".
+	Disposal    string  `bson:"disposal,omitempty" json:"disposal,omitempty"`
+	Weight      float64 `bson:"weight,omitempty" json:"weight,omitempty"`
+	TotalWeight float64 `bson:"totalWeight,omitempty" json:"totalWeight,omitempty"`
+	// Unit is the weight-unit of Weight and TotalWeight, such as "kg".
+	// Items are stored normalized to the CanonicalUnit.
+	Unit string `bson:"unit,omitempty" json:"unit,omitempty"`
+	// UnitCost is the cost per unit of weight when the item was wasted,
+	// if known by the waste-event.
+	UnitCost  float64 `bson:"unitCost,omitempty" json:"unitCost,omitempty"`
+	Timestamp int64   `bson:"timestamp,omitempty" json:"timestamp,omitempty"`
+	// ReceivedAt and ExpiresAt are the unix-timestamps when the item was
+	// received and when it expires, if known. Otherwise, the item's lot's
+	// dates in the lot-metadata are used for shelf-life analysis.
+	ReceivedAt int64 `bson:"receivedAt,omitempty" json:"receivedAt,omitempty"`
+	ExpiresAt  int64 `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
 }
 
 type WasteItemParams struct {
 	Timestamp *Comparator `json:"timestamp,omitempty"`
+	// StoreID limits the report to the listed stores. All stores are
+	// included if this is nil.
+	StoreID *InComparator `json:"storeID,omitempty"`
+	// GroupByStore adds the StoreID to fields the report-results are grouped by,
+	// so each SKU has a result per store.
+	GroupByStore bool `json:"groupByStore,omitempty"`
+	// Reason and Disposal limit the report to the listed waste-reasons and
+	// disposal-methods. All are included if these are nil.
+	Reason   *InComparator `json:"reason,omitempty"`
+	Disposal *InComparator `json:"disposal,omitempty"`
+	// GroupByReason and GroupByDisposal add the waste-reason and
+	// disposal-method to fields the report-results are grouped by.
+	GroupByReason   bool `json:"groupByReason,omitempty"`
+	GroupByDisposal bool `json:"groupByDisposal,omitempty"`
+	// SKU limits the report to the listed SKUs. All SKUs are included if this is nil.
+	SKU *InComparator `json:"sku,omitempty"`
+	// Category drills-down the report to the SKUs within the CategoryPath in
+	// the product-catalogue.
+	Category *CategoryPath `json:"category,omitempty"`
+	// RollUp is the hierarchy-level, such as "category", the report-results are
+	// summed at. The results are then followed by subtotals for each broader level.
+	// The results are per SKU, without the hierarchy, if this is blank.
+	RollUp string `json:"rollUp,omitempty"`
+}
+
+// Stores returns the stores the params are limited to, or nil for all stores.
+func (p WasteItemParams) Stores() []string {
+	if p.StoreID == nil {
+		return nil
+	}
+	return p.StoreID.In
 }
 
 func (s WasteItem) MarshalBSON() ([]byte, error) {
+	err := s.Normalize()
+	if err != nil {
+		err = errors.Wrap(err, "Error normalizing WasteItem")
+		return nil, err
+	}
 	si := map[string]interface{}{
 		"itemID":      s.ItemID.String(),
 		"wasteID":     s.WasteID.String(),
 		"lot":         s.Lot,
+		"storeID":     s.StoreID,
+		"reason":      s.Reason,
+		"disposal":    s.Disposal,
 		"name":        s.Name,
 		"sku":         s.SKU,
 		"weight":      s.Weight,
 		"timestamp":   s.Timestamp,
 		"totalWeight": s.TotalWeight,
+		"unit":        s.Unit,
+	}
+	if s.UnitCost != 0 {
+		si["unitCost"] = s.UnitCost
+	}
+	if s.ReceivedAt != 0 {
+		si["receivedAt"] = s.ReceivedAt
+	}
+	if s.ExpiresAt != 0 {
+		si["expiresAt"] = s.ExpiresAt
 	}
 
 	if s.ID != objectid.NilObjectID {
@@ -85,6 +150,27 @@ func (s WasteItem) unmarshalFromMap(m map[string]interface{}) error {
 		}
 	}
 
+	if m["storeID"] != nil {
+		s.StoreID, assertOK = m["storeID"].(string)
+		if !assertOK {
+			return errors.New("Error while asserting StoreID")
+		}
+	}
+
+	if m["reason"] != nil {
+		s.Reason, assertOK = m["reason"].(string)
+		if !assertOK {
+			return errors.New("Error while asserting Reason")
+		}
+	}
+
+	if m["disposal"] != nil {
+		s.Disposal, assertOK = m["disposal"].(string)
+		if !assertOK {
+			return errors.New("Error while asserting Disposal")
+		}
+	}
+
 	if m["lot"] != nil {
 		s.Lot, assertOK = m["lot"].(string)
 		if !assertOK {
@@ -126,5 +212,32 @@ func (s WasteItem) unmarshalFromMap(m map[string]interface{}) error {
 			return err
 		}
 	}
+	if m["unit"] != nil {
+		s.Unit, assertOK = m["unit"].(string)
+		if !assertOK {
+			return errors.New("Error while asserting Unit")
+		}
+	}
+	if m["unitCost"] != nil {
+		s.UnitCost, err = util.AssertFloat64(m["unitCost"])
+		if err != nil {
+			err = errors.Wrap(err, "Error while asserting UnitCost")
+			return err
+		}
+	}
+	if m["receivedAt"] != nil {
+		s.ReceivedAt, err = util.AssertInt64(m["receivedAt"])
+		if err != nil {
+			err = errors.Wrap(err, "Error while asserting ReceivedAt")
+			return err
+		}
+	}
+	if m["expiresAt"] != nil {
+		s.ExpiresAt, err = util.AssertInt64(m["expiresAt"])
+		if err != nil {
+			err = errors.Wrap(err, "Error while asserting ExpiresAt")
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Output-formats supported for report-results.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	// FormatXLSX is an Excel-workbook. Since KafkaResponse.Result is bytes,
+	// the workbook is base64-encoded when the response is marshalled to JSON.
+	FormatXLSX = "xlsx"
+	// FormatHTML is a self-contained printable HTML document.
+	FormatHTML = "html"
+)
+
+// OutputParams are the options in query-event data which control how
+// the report-results are returned.
+type OutputParams struct {
+	Format string `json:"format,omitempty"`
+	// Unit is the weight-unit of the results, and defaults to the CanonicalUnit.
+	Unit string `json:"unit,omitempty"`
+}
+
+// ValidFormat checks if the provided output-format is supported.
+// A blank format is valid, and defaults to JSON.
+func ValidFormat(format string) bool {
+	switch format {
+	case "", FormatJSON, FormatCSV, FormatXLSX, FormatHTML:
+		return true
+	}
+	return false
+}
+
+// ValidDataFormat checks if the output-format is supported for analyses
+// other than WasteReports, such as lot-traces, which are only JSON or CSV.
+// A blank format is valid, and defaults to JSON.
+func ValidDataFormat(format string) bool {
+	switch format {
+	case "", FormatJSON, FormatCSV:
+		return true
+	}
+	return false
+}
+
+// ContentType returns the MIME-type for the output-format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// MarshalReport converts the WasteReport to the specified output-format.
+// JSON and CSV formats only include the ReportResults, while XLSX and HTML
+// also include a summary of the report.
+func MarshalReport(wasteReport *WasteReport, format string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(wasteReport.ReportResult)
+	case FormatCSV:
+		err = WriteCSV(buf, wasteReport)
+	case FormatXLSX:
+		err = WriteXLSX(buf, wasteReport)
+	case FormatHTML:
+		err = WriteHTML(buf, wasteReport)
+	default:
+		return nil, errors.Errorf("Unsupported output-format: %s", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// WritePDF renders the WasteReport as a single-document PDF, with the same
+// sections as the HTML document. This is intended for generating reports
+// offline (such as from the report-export command), and is not used when
+// responding to query-events.
+func WritePDF(w io.Writer, wasteReport *WasteReport) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Waste Report", "", 1, "L", false, 0, "")
+
+	filters, err := json.Marshal(wasteReport.SearchQuery)
+	if err != nil {
+		err = errors.Wrap(err, "WritePDF: Error marshalling SearchQuery")
+		return err
+	}
+	summary := Summarize(wasteReport.ReportResult, TopOffendersCount)
+	columns := wasteReport.resultColumns()
+
+	pdf.SetFont("Helvetica", "", 9)
+	pdfLabelValue(pdf, "Report ID", wasteReport.ReportID.String())
+	ts := wasteReport.SearchQuery.Timestamp
+	if ts != nil {
+		pdfLabelValue(
+			pdf,
+			"Window",
+			time.Unix(int64(ts.Gt), 0).UTC().Format(time.RFC1123)+" - "+
+				time.Unix(int64(ts.Lt), 0).UTC().Format(time.RFC1123),
+		)
+	}
+	pdfLabelValue(pdf, "Filters", string(filters))
+	pdfLabelValue(pdf, weightLabel("Total Waste Weight", summary.Unit), formatWeight(summary.WasteWeight))
+	pdfLabelValue(pdf, weightLabel("Total Weight", summary.Unit), formatWeight(summary.TotalWeight))
+	pdfLabelValue(pdf, "Waste Ratio", formatRatio(summary.WasteRatio))
+	if summary.Currency != "" {
+		pdfLabelValue(pdf, "Total Waste Value", formatMoney(summary.WasteValue, summary.Currency))
+		pdfLabelValue(pdf, "Total Value", formatMoney(summary.TotalValue, summary.Currency))
+	}
+
+	for _, d := range summary.ByDisposal {
+		pdfLabelValue(
+			pdf,
+			"Disposal: "+d.Disposal,
+			formatWeight(d.WasteWeight)+" ("+formatRatio(d.Share)+")",
+		)
+	}
+
+	pdfHeading(pdf, "Waste per SKU")
+	pdfChart(pdf, wasteChart(wasteReport.ReportResult))
+
+	pdfHeading(pdf, "Top Offenders")
+	pdfTable(pdf, columns, tableRows(columns, summary.TopOffenders))
+
+	pdfHeading(pdf, "Detail")
+	pdfTable(pdf, columns, tableRows(columns, wasteReport.ReportResult))
+
+	err = pdf.Output(w)
+	if err != nil {
+		err = errors.Wrap(err, "WritePDF: Error writing PDF")
+		return err
+	}
+	return nil
+}
+
+func pdfHeading(pdf *gofpdf.Fpdf, heading string) {
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 8, heading, "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+}
+
+func pdfLabelValue(pdf *gofpdf.Fpdf, label string, value string) {
+	pdf.CellFormat(40, 6, label, "1", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 6, value, "1", 1, "L", false, 0, "")
+}
+
+// pdfChart draws the bar-chart created for the HTML document, scaled
+// from px to the page-width.
+func pdfChart(pdf *gofpdf.Fpdf, chart htmlChart) {
+	pageWidth, pageHeight := pdf.GetPageSize()
+	left, _, right, bottom := pdf.GetMargins()
+	scale := (pageWidth - left - right) / float64(chart.Width)
+
+	// Rects don't trigger automatic page-breaks, so start chart on new page
+	// if it won't fit in the current one.
+	if pdf.GetY()+float64(chart.Height)*scale > pageHeight-bottom {
+		pdf.AddPage()
+	}
+	x, y := pdf.GetXY()
+	for _, bar := range chart.Bars {
+		barY := y + float64(bar.Y)*scale
+		barX := x + float64(chart.LabelWidth)*scale
+		barHeight := float64(chart.BarHeight) * scale
+
+		pdf.SetXY(x, barY)
+		pdf.CellFormat(
+			float64(chart.LabelWidth)*scale, barHeight, bar.Label, "", 0, "L", false, 0, "",
+		)
+		pdf.SetFillColor(221, 221, 221)
+		pdf.Rect(barX, barY, bar.TotalWidth*scale, barHeight, "F")
+		pdf.SetFillColor(192, 57, 43)
+		pdf.Rect(barX, barY, bar.WasteWidth*scale, barHeight, "F")
+	}
+	pdf.SetXY(x, y+float64(chart.Height)*scale)
+}
+
+func pdfTable(pdf *gofpdf.Fpdf, columns []resultColumn, rows [][]string) {
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	colWidth := (pageWidth - left - right) / float64(len(columns))
+
+	pdf.SetFillColor(238, 238, 238)
+	for _, c := range columns {
+		pdf.CellFormat(colWidth, 6, c.Header, "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	for _, row := range rows {
+		for i, value := range row {
+			align := "L"
+			if columns[i].Metric != nil {
+				align = "R"
+			}
+			pdf.CellFormat(colWidth, 6, value, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
@@ -0,0 +1,361 @@
+package report
+
+import (
+	util "github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// matchOperators are the query-operators allowed on each field in the
+// $match stage. Fields and operators not listed here cannot be used.
+var matchOperators = map[string]map[string]bool{
+	"timestamp": {"$lt": true, "$gt": true, "$eq": true},
+	"storeID":   {"$in": true},
+	"lot":       {"$in": true},
+	"sku":       {"$in": true},
+	"reason":    {"$in": true},
+	"disposal":  {"$in": true},
+}
+
+// groupFields are the fields that results can be grouped by.
+var groupFields = map[string]bool{
+	"sku":      true,
+	"name":     true,
+	"storeID":  true,
+	"reason":   true,
+	"disposal": true,
+}
+
+// groupAccumulators are the accumulator-operators allowed in the $group stage.
+var groupAccumulators = map[string]bool{
+	"$avg": true,
+	"$sum": true,
+}
+
+// pipelineBuilder builds an aggregation-pipeline having a $match and a $group
+// stage. Only the whitelisted fields and operators are accepted, and values
+// are always literals, so callers' params can't add operators such as $where.
+// The first error is kept, and returned by build.
+type pipelineBuilder struct {
+	match *bson.Document
+	group *bson.Document
+	err   error
+}
+
+func newPipelineBuilder() *pipelineBuilder {
+	return &pipelineBuilder{
+		match: bson.NewDocument(),
+		group: bson.NewDocument(),
+	}
+}
+
+// matchField adds the conditions on field to the $match stage.
+func (b *pipelineBuilder) matchField(field string, conds ...*bson.Element) {
+	if b.err != nil || len(conds) == 0 {
+		return
+	}
+	ops, ok := matchOperators[field]
+	if !ok {
+		b.err = errors.Errorf("Field %q cannot be filtered", field)
+		return
+	}
+	for _, cond := range conds {
+		if !ops[cond.Key()] {
+			b.err = errors.Errorf("Operator %q is not allowed on field %q", cond.Key(), field)
+			return
+		}
+		if !isLiteral(cond.Value()) {
+			b.err = errors.Errorf("Operator %q on field %q must have a literal value", cond.Key(), field)
+			return
+		}
+	}
+	b.match.Append(bson.EC.SubDocumentFromElements(field, conds...))
+}
+
+// matchIn adds the $in condition on field to the $match stage,
+// if the InComparator is not nil.
+func (b *pipelineBuilder) matchIn(field string, in *InComparator) {
+	if in == nil {
+		return
+	}
+	values := bson.NewArray()
+	for _, v := range in.In {
+		values.Append(bson.VC.String(v))
+	}
+	b.matchField(field, bson.EC.Array("$in", values))
+}
+
+// groupBy sets the fields the $group stage groups by.
+func (b *pipelineBuilder) groupBy(fields ...string) {
+	if b.err != nil {
+		return
+	}
+	id := bson.NewDocument()
+	for _, field := range fields {
+		if !groupFields[field] {
+			b.err = errors.Errorf("Field %q cannot be grouped by", field)
+			return
+		}
+		id.Append(bson.EC.String(field, "$"+field))
+	}
+	b.group.Set(bson.EC.SubDocument("_id", id))
+}
+
+// groupByDay adds the "day" to the fields the $group stage groups by, being
+// the start of each WasteItem's UTC-day as a unix-timestamp. groupBy must be
+// called first.
+func (b *pipelineBuilder) groupByDay() {
+	if b.err != nil {
+		return
+	}
+	id := b.group.Lookup("_id")
+	if id == nil {
+		b.err = errors.New("Pipeline must group by fields before grouping by day")
+		return
+	}
+	id.MutableDocument().Append(bson.EC.SubDocumentFromElements(
+		"day", bson.EC.ArrayFromElements(
+			"$subtract",
+			bson.VC.String("$timestamp"),
+			bson.VC.DocumentFromElements(bson.EC.ArrayFromElements(
+				"$mod", bson.VC.String("$timestamp"), bson.VC.Int64(secondsPerDay),
+			)),
+		),
+	))
+}
+
+// accumulate adds the output-field name to the $group stage, computed using
+// the accumulator-operator op on field. The field is first converted from
+// each WasteItem's weight-unit to the CanonicalUnit, so items having
+// mixed units are aggregated correctly. Items in unsupported units are
+// counted in the unknownUnitsField.
+func (b *pipelineBuilder) accumulate(name string, op string, field string) {
+	if b.err != nil {
+		return
+	}
+	if !groupAccumulators[op] {
+		b.err = errors.Errorf("Accumulator %q is not allowed", op)
+		return
+	}
+	convertOp, ok := numericFields[field]
+	if !ok {
+		b.err = errors.Errorf("Field %q cannot be accumulated", field)
+		return
+	}
+	b.group.Append(bson.EC.SubDocumentFromElements(name, bson.EC.SubDocumentFromElements(
+		op, bson.EC.ArrayFromElements(
+			convertOp, bson.VC.String("$"+field), bson.VC.Document(unitFactorExpr()),
+		),
+	)))
+	b.countUnknownUnits()
+}
+
+// accumulateCost adds the output-fields for the unit-cost weighted by the
+// wasted weight. costName sums the cost of each WasteItem, being its weight
+// times its unit-cost, and weightName sums the weight of the WasteItems having
+// a unit-cost, in the CanonicalUnit. The weight-unit cancels out of the cost,
+// so it needs no conversion.
+func (b *pipelineBuilder) accumulateCost(costName string, weightName string) {
+	if b.err != nil {
+		return
+	}
+	// Items without a unit-cost have a null cost, which $sum ignores
+	b.group.Append(bson.EC.SubDocumentFromElements(costName, bson.EC.SubDocumentFromElements(
+		"$sum", bson.EC.ArrayFromElements(
+			"$multiply", bson.VC.String("$weight"), bson.VC.String("$unitCost"),
+		),
+	)))
+	b.group.Append(bson.EC.SubDocumentFromElements(weightName, bson.EC.SubDocumentFromElements(
+		"$sum", bson.EC.ArrayFromElements(
+			"$cond",
+			bson.VC.DocumentFromElements(bson.EC.ArrayFromElements(
+				"$gt", bson.VC.String("$unitCost"), bson.VC.Double(0),
+			)),
+			bson.VC.DocumentFromElements(bson.EC.ArrayFromElements(
+				"$multiply", bson.VC.String("$weight"), bson.VC.Document(unitFactorExpr()),
+			)),
+			bson.VC.Double(0),
+		),
+	)))
+	b.countUnknownUnits()
+}
+
+func (b *pipelineBuilder) build() ([]*bson.Document, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.group.Lookup("_id") == nil {
+		return nil, errors.New("Pipeline must group by at least one field")
+	}
+	return []*bson.Document{
+		bson.NewDocument(bson.EC.SubDocument("$match", b.match)),
+		bson.NewDocument(bson.EC.SubDocument("$group", b.group)),
+	}, nil
+}
+
+// buildMatch returns the pipeline having only the $match stage,
+// for listing the matched WasteItems.
+func (b *pipelineBuilder) buildMatch() ([]*bson.Document, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return []*bson.Document{
+		bson.NewDocument(bson.EC.SubDocument("$match", b.match)),
+	}, nil
+}
+
+// numericFields are the WasteItem-fields that can be accumulated, mapped to
+// the operator converting them to the CanonicalUnit using unitFactorExpr.
+// Weights are multiplied by the factor, while costs per weight are divided.
+var numericFields = map[string]string{
+	"weight":      "$multiply",
+	"totalWeight": "$multiply",
+	"unitCost":    "$divide",
+}
+
+// unitFactorExpr returns the expression for the kilograms in one of the
+// WasteItem's weight-unit. Items without a unit are considered to be in the
+// CanonicalUnit, as in unitFactor. Unknown units have no factor, so they are
+// never counted as some other unit, and are instead counted by countUnknownUnits.
+func unitFactorExpr() *bson.Document {
+	branches := bson.NewArray()
+	for _, unit := range knownUnits() {
+		// Known units always have a factor
+		factor, _ := unitFactor(unit)
+		branches.Append(bson.VC.DocumentFromElements(
+			bson.EC.SubDocumentFromElements("case", bson.EC.ArrayFromElements(
+				"$eq", bson.VC.Document(unitExpr()), bson.VC.String(unit),
+			)),
+			bson.EC.Double("then", factor),
+		))
+	}
+	return bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$switch",
+			bson.EC.Array("branches", branches),
+			bson.EC.Null("default"),
+		),
+	)
+}
+
+// unitExpr returns the expression for the WasteItem's weight-unit,
+// being blank for items without a unit.
+func unitExpr() *bson.Document {
+	return bson.NewDocument(bson.EC.ArrayFromElements(
+		"$ifNull", bson.VC.String("$unit"), bson.VC.String(""),
+	))
+}
+
+// unknownUnitsField is the output-field of the $group stage counting the
+// WasteItems having an unsupported weight-unit.
+const unknownUnitsField = "unknown_units"
+
+// countUnknownUnits adds the unknownUnitsField to the $group stage, so
+// results having WasteItems in unsupported weight-units are rejected by
+// checkUnknownUnits, like unitFactor rejects them.
+func (b *pipelineBuilder) countUnknownUnits() {
+	if b.err != nil || b.group.Lookup(unknownUnitsField) != nil {
+		return
+	}
+	b.group.Append(bson.EC.SubDocument(unknownUnitsField, unknownUnitsSum()))
+}
+
+// unknownUnitsSum returns the $sum counting WasteItems having an
+// unsupported weight-unit.
+func unknownUnitsSum() *bson.Document {
+	known := bson.NewArray()
+	for _, unit := range knownUnits() {
+		known.Append(bson.VC.String(unit))
+	}
+	return bson.NewDocument(bson.EC.SubDocumentFromElements(
+		"$sum", bson.EC.ArrayFromElements(
+			"$cond",
+			bson.VC.DocumentFromElements(bson.EC.ArrayFromElements(
+				"$in", bson.VC.Document(unitExpr()), bson.VC.Array(known),
+			)),
+			bson.VC.Int32(0),
+			bson.VC.Int32(1),
+		),
+	))
+}
+
+// checkUnknownUnits returns an error if the aggregate-result counted WasteItems
+// having an unsupported weight-unit, since their weights are left out.
+func checkUnknownUnits(m map[string]interface{}) error {
+	if m[unknownUnitsField] == nil {
+		return nil
+	}
+	count, err := util.AssertFloat64(m[unknownUnitsField])
+	if err != nil {
+		return errors.Wrap(err, "Error asserting "+unknownUnitsField)
+	}
+	if count > 0 {
+		return errors.Errorf("%d waste-items have an unsupported weight-unit", int64(count))
+	}
+	return nil
+}
+
+// isLiteral checks if the value is a number or string,
+// or an array of those.
+func isLiteral(v *bson.Value) bool {
+	switch v.Type() {
+	case bson.TypeDouble, bson.TypeInt32, bson.TypeInt64, bson.TypeString:
+		return true
+	case bson.TypeArray:
+		arr := v.MutableArray()
+		for i := 0; i < arr.Len(); i++ {
+			item, err := arr.Lookup(uint(i))
+			if err != nil || item.Type() == bson.TypeArray || !isLiteral(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// timestampConds returns the $match conditions for the Comparator.
+func timestampConds(ts *Comparator) []*bson.Element {
+	conds := []*bson.Element{}
+	if ts == nil {
+		return conds
+	}
+	if ts.Gt != 0 {
+		conds = append(conds, bson.EC.Double("$gt", ts.Gt))
+	}
+	if ts.Lt != 0 {
+		conds = append(conds, bson.EC.Double("$lt", ts.Lt))
+	}
+	if ts.Eq != 0 {
+		conds = append(conds, bson.EC.Double("$eq", ts.Eq))
+	}
+	return conds
+}
+
+// pipeline returns the aggregation-pipeline for the report-results.
+func (p WasteItemParams) pipeline() ([]*bson.Document, error) {
+	b := newPipelineBuilder()
+
+	b.matchField("timestamp", timestampConds(p.Timestamp)...)
+	b.matchIn("storeID", p.StoreID)
+	b.matchIn("sku", p.SKU)
+	b.matchIn("reason", p.Reason)
+	b.matchIn("disposal", p.Disposal)
+
+	groupBy := []string{"sku", "name"}
+	if p.GroupByStore {
+		groupBy = append(groupBy, "storeID")
+	}
+	if p.GroupByReason {
+		groupBy = append(groupBy, "reason")
+	}
+	if p.GroupByDisposal {
+		groupBy = append(groupBy, "disposal")
+	}
+	b.groupBy(groupBy...)
+	// Weights are summed, so results (and their sums) are the whole weight
+	// wasted in the period, rather than the weight of an average event.
+	b.accumulate("sum_waste", "$sum", "weight")
+	b.accumulate("sum_total", "$sum", "totalWeight")
+	b.accumulateCost("sum_cost", "sum_priced_waste")
+	return b.build()
+}
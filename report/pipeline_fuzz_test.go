@@ -0,0 +1,40 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// FuzzPipeline checks that event-data is either rejected, or builds a
+// pipeline having only the allowedPipelineKeys. Only the seed-corpus runs
+// with the other tests, and fuzzing uses: go test -run '^$' -fuzz FuzzPipeline
+func FuzzPipeline(f *testing.F) {
+	for _, data := range hostilePayloads {
+		f.Add(data)
+	}
+	f.Add(`{"timestamp":{"$gt":1,"$lt":2},"sku":{"$in":["sku1"]},"groupByReason":true}`)
+
+	f.Fuzz(func(t *testing.T, data string) {
+		params := WasteItemParams{}
+		err := json.Unmarshal([]byte(data), &params)
+		if err != nil {
+			return
+		}
+		pipeline, err := params.pipeline()
+		if err != nil {
+			return
+		}
+		for _, stage := range pipeline {
+			keys, err := stage.Keys(true)
+			if err != nil {
+				t.Fatalf("Error reading pipeline-keys: %s", err)
+			}
+			for _, k := range keys {
+				if strings.HasPrefix(k.Name, "$") && !allowedPipelineKeys[k.Name] {
+					t.Fatalf("Operator %q in pipeline for payload: %s", k.Name, data)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/mongodb/mongo-go-driver/bson"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// allowedPipelineKeys are all the operators the pipeline can contain.
+var allowedPipelineKeys = map[string]bool{
+	"$match": true,
+	"$group": true,
+	"$gt":    true,
+	"$lt":    true,
+	"$eq":    true,
+	"$in":    true,
+	"$sum":   true,
+	// Weighting the unit-cost
+	"$cond": true,
+	// Converting weight-units
+	"$multiply": true,
+	"$divide":   true,
+	"$switch":   true,
+	"$ifNull":   true,
+}
+
+// pipelineOperators returns all operator-keys in the pipeline.
+func pipelineOperators(pipeline []*bson.Document) []string {
+	ops := []string{}
+	for _, stage := range pipeline {
+		keys, err := stage.Keys(true)
+		Expect(err).ToNot(HaveOccurred())
+		for _, k := range keys {
+			if strings.HasPrefix(k.Name, "$") {
+				ops = append(ops, k.Name)
+			}
+		}
+	}
+	return ops
+}
+
+// expectSafePipeline builds the pipeline from the event-data, and checks that
+// either the data is rejected, or the pipeline only has allowed operators.
+func expectSafePipeline(data string) {
+	params := WasteItemParams{}
+	err := json.Unmarshal([]byte(data), &params)
+	if err != nil {
+		return
+	}
+	pipeline, err := params.pipeline()
+	if err != nil {
+		return
+	}
+	for _, op := range pipelineOperators(pipeline) {
+		Expect(allowedPipelineKeys).To(HaveKey(op), "payload: %s", data)
+	}
+}
+
+// payloadKeys are the keys random payloads are made of, being the
+// params' fields, and the operators the pipeline must keep out.
+var payloadKeys = []string{
+	"timestamp", "storeID", "groupByStore", "sku", "_id",
+	"reason", "disposal", "groupByReason", "groupByDisposal",
+	"$gt", "$lt", "$eq", "$in", "$ne", "$where", "$function", "$expr", "$or", "$regex",
+}
+
+// randomJSON generates a random JSON-value made of the payloadKeys and assorted values.
+func randomJSON(r *rand.Rand, depth int) string {
+	n := r.Intn(6)
+	if depth <= 0 {
+		n = r.Intn(5)
+	}
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d", r.Int63n(2000000000)-1000000000)
+	case 1:
+		return fmt.Sprintf("%q", payloadKeys[r.Intn(len(payloadKeys))])
+	case 2:
+		return `"function() { return true }"`
+	case 3:
+		return []string{"true", "null", "1.5e3"}[r.Intn(3)]
+	case 4:
+		if depth <= 0 {
+			return "[]"
+		}
+		items := []string{}
+		for i := r.Intn(3); i > 0; i-- {
+			items = append(items, randomJSON(r, depth-1))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	default:
+		return randomObject(r, depth)
+	}
+}
+
+// randomObject generates a random JSON-object made of the payloadKeys.
+func randomObject(r *rand.Rand, depth int) string {
+	fields := []string{}
+	for i := r.Intn(4) + 1; i > 0; i-- {
+		fields = append(fields, fmt.Sprintf(
+			"%q:%s", payloadKeys[r.Intn(len(payloadKeys))], randomJSON(r, depth-1),
+		))
+	}
+	return "{" + strings.Join(fields, ",") + "}"
+}
+
+// hostilePayloads are event-data trying to add operators to the pipeline.
+// These are also the seed-corpus of FuzzPipeline.
+var hostilePayloads = []string{
+	`{"timestamp":{"$gt":1,"$lt":2,"$where":"sleep(1000)"}}`,
+	`{"timestamp":{"$gt":1,"$lt":2,"$eq":{"$where":"sleep(1000)"}}}`,
+	`{"timestamp":{"$gt":1,"$lt":2,"$eq":{"$function":{"body":"return 1","args":[],"lang":"js"}}}}`,
+	`{"timestamp":{"$gt":{"$ne":null},"$lt":2}}`,
+	`{"timestamp":{"$gt":1,"$lt":2},"storeID":{"$in":[{"$ne":null}]}}`,
+	`{"timestamp":{"$gt":1,"$lt":2},"storeID":{"$ne":"store-1"}}`,
+	`{"timestamp":{"$gt":1,"$lt":2},"storeID":"$where"}`,
+	`{"timestamp":{"$gt":1,"$lt":2},"$where":"sleep(1000)"}`,
+	`{"timestamp":{"$gt":1,"$lt":2},"$expr":{"$function":{"body":"return 1"}}}`,
+	`{"timestamp":{"$gt":1,"$lt":2},"groupByStore":{"$where":"1"}}`,
+}
+
+var _ = Describe("Pipeline", func() {
+	It("builds the $match and $group stages", func() {
+		params := WasteItemParams{
+			Timestamp:    &Comparator{Gt: 9, Lt: 21},
+			StoreID:      &InComparator{In: []string{"store-1", "store-2"}},
+			GroupByStore: true,
+		}
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline).To(HaveLen(2))
+
+		Expect(pipeline[0].Lookup("$match", "timestamp").MutableDocument().Len()).To(Equal(2))
+		sumWaste := pipeline[1].Lookup("$group", "sum_waste", "$sum", "$multiply").MutableArray()
+		field, err := sumWaste.Lookup(0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(field.StringValue()).To(Equal("$weight"))
+		sumCost := pipeline[1].Lookup("$group", "sum_cost", "$sum", "$multiply").MutableArray()
+		field, err = sumCost.Lookup(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(field.StringValue()).To(Equal("$unitCost"))
+		pricedWaste := pipeline[1].Lookup("$group", "sum_priced_waste", "$sum", "$cond").MutableArray()
+		Expect(pricedWaste.Len()).To(Equal(3))
+		Expect(pipeline[0].Lookup("$match").MutableDocument().Len()).To(Equal(2))
+	})
+
+	It("counts waste-items in unknown weight-units instead of converting them", func() {
+		params := WasteItemParams{
+			Timestamp: &Comparator{Gt: 1, Lt: 2},
+		}
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+
+		// Only the known units, including the blank unit, have a factor
+		factor := pipeline[1].Lookup("$group", "sum_waste", "$sum", "$multiply").MutableArray()
+		expr, err := factor.Lookup(1)
+		Expect(err).ToNot(HaveOccurred())
+		units := expr.MutableDocument().Lookup("$switch", "branches").MutableArray()
+		Expect(units.Len()).To(Equal(len(knownUnits())))
+		Expect(knownUnits()).To(ContainElement(""))
+		Expect(expr.MutableDocument().Lookup("$switch", "default").Type()).To(Equal(bson.TypeNull))
+
+		known := pipeline[1].Lookup("$group", unknownUnitsField, "$sum", "$cond").MutableArray()
+		Expect(known.Len()).To(Equal(3))
+
+		_, err = ResultsFromAggregate([]interface{}{
+			map[string]interface{}{
+				"_id": map[string]interface{}{
+					"sku":  "test-sku1",
+					"name": "test-name1",
+				},
+				"sum_waste":       float64(10),
+				"sum_total":       float64(100),
+				unknownUnitsField: int32(2),
+			},
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("test-sku1"))
+		Expect(err.Error()).To(ContainSubstring("unsupported weight-unit"))
+
+		results, err := ResultsFromAggregate([]interface{}{
+			map[string]interface{}{
+				"_id": map[string]interface{}{
+					"sku":  "test-sku1",
+					"name": "test-name1",
+				},
+				"sum_waste":       float64(10),
+				"sum_total":       float64(100),
+				unknownUnitsField: int32(0),
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+	})
+
+	It("rejects fields and operators not whitelisted", func() {
+		b := newPipelineBuilder()
+		b.groupBy("sku")
+		b.matchField("sku", bson.EC.String("$eq", "123"))
+		_, err := b.build()
+		Expect(err).To(HaveOccurred())
+
+		b = newPipelineBuilder()
+		b.groupBy("sku")
+		b.matchField("timestamp", bson.EC.String("$where", "sleep(1000)"))
+		_, err = b.build()
+		Expect(err).To(HaveOccurred())
+
+		b = newPipelineBuilder()
+		b.groupBy("$where")
+		_, err = b.build()
+		Expect(err).To(HaveOccurred())
+
+		b = newPipelineBuilder()
+		b.groupBy("sku")
+		b.accumulate("x", "$function", "weight")
+		_, err = b.build()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects non-literal values", func() {
+		b := newPipelineBuilder()
+		b.groupBy("sku")
+		b.matchField("timestamp", bson.EC.SubDocumentFromElements(
+			"$eq", bson.EC.String("$where", "sleep(1000)"),
+		))
+		_, err := b.build()
+		Expect(err).To(HaveOccurred())
+
+		b = newPipelineBuilder()
+		b.groupBy("sku")
+		b.matchField("storeID", bson.EC.ArrayFromElements(
+			"$in", bson.VC.DocumentFromElements(bson.EC.String("$function", "")),
+		))
+		_, err = b.build()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("keeps hostile payloads out of the pipeline", func() {
+		for _, data := range hostilePayloads {
+			expectSafePipeline(data)
+		}
+	})
+
+	It("keeps randomly generated payloads out of the pipeline", func() {
+		r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		for i := 0; i < 2000; i++ {
+			expectSafePipeline(randomObject(r, 4))
+		}
+	})
+
+	It("sets store-IDs as literal values", func() {
+		params := WasteItemParams{
+			StoreID: &InComparator{In: []string{"$where", `{"$ne": null}`}},
+		}
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+
+		stores := pipeline[0].Lookup("$match", "storeID", "$in").MutableArray()
+		store, err := stores.Lookup(0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.StringValue()).To(Equal("$where"))
+		Expect(pipelineOperators(pipeline)).ToNot(ContainElement("$where"))
+	})
+})
@@ -0,0 +1,138 @@
+package report
+
+import (
+	"log"
+
+	util "github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// Price is the unit-cost of a SKU in the price-catalogue,
+// which is the cost per unit of weight.
+type Price struct {
+	SKU      string  `bson:"sku,omitempty" json:"sku,omitempty"`
+	UnitCost float64 `bson:"unitCost,omitempty" json:"unitCost,omitempty"`
+	Currency string  `bson:"currency,omitempty" json:"currency,omitempty"`
+}
+
+func (p *Price) UnmarshalBSON(in []byte) error {
+	m := make(map[string]interface{})
+	err := bson.Unmarshal(in, m)
+	if err != nil {
+		err = errors.Wrap(err, "Unmarshal Error")
+		return err
+	}
+
+	p.SKU, _ = m["sku"].(string)
+	p.Currency, _ = m["currency"].(string)
+	if m["unitCost"] != nil {
+		p.UnitCost, err = util.AssertFloat64(m["unitCost"])
+		if err != nil {
+			err = errors.Wrap(err, "Error while asserting UnitCost")
+			return err
+		}
+	}
+	return nil
+}
+
+// Pricing is the source of unit-costs for pricing report-results.
+type Pricing struct {
+	// Catalog is the price-catalogue collection, having Prices. If nil, only
+	// the unit-costs carried in waste-events are used.
+	Catalog *mongo.Collection
+	// Currency is the currency of the unit-costs. Unit-costs in waste-events
+	// are in this currency, and only catalogue-prices in this currency are used.
+	Currency string
+}
+
+// CatalogPrices returns the unit-costs in the price-catalogue for the SKUs,
+// keyed by SKU. SKUs without a price in the currency are left out.
+func CatalogPrices(catalog *mongo.Collection, skus []string, currency string) (map[string]float64, error) {
+	findResults, err := catalog.Find(map[string]interface{}{
+		"sku": map[string]interface{}{
+			"$in": skus,
+		},
+		"currency": currency,
+	})
+	if err != nil {
+		err = errors.Wrap(err, "CatalogPrices: Error finding prices")
+		log.Println(err)
+		return nil, err
+	}
+
+	prices := map[string]float64{}
+	for _, v := range findResults {
+		price, assertOK := v.(*Price)
+		if !assertOK {
+			return nil, errors.New("CatalogPrices: Error asserting find-result to Price")
+		}
+		prices[price.SKU] = price.UnitCost
+	}
+	return prices, nil
+}
+
+// applyPricing sets the unit-cost, values and currency of results.
+// Unit-costs from waste-events are used if present, and otherwise the
+// price-catalogue is used. Results without any known unit-cost are not priced.
+func (p Pricing) applyPricing(results []ReportResult) error {
+	if p.Catalog != nil {
+		skus := []string{}
+		for _, r := range results {
+			if r.UnitCost == 0 {
+				skus = append(skus, r.SKU)
+			}
+		}
+		if len(skus) > 0 {
+			prices, err := CatalogPrices(p.Catalog, skus, p.Currency)
+			if err != nil {
+				return err
+			}
+			for i, r := range results {
+				if r.UnitCost == 0 {
+					results[i].UnitCost = prices[r.SKU]
+				}
+			}
+		}
+	}
+
+	for i := range results {
+		results[i].setValues(p.Currency)
+	}
+	return nil
+}
+
+// setValues calculates the wasted and total values from the unit-cost. The
+// weights are the summed weights of the result, so the values are the whole
+// value wasted. Weight without a unit-cost is valued at the result's unit-cost.
+func (r *ReportResult) setValues(currency string) {
+	if r.UnitCost == 0 {
+		return
+	}
+	r.WasteValue = r.WasteWeight * r.UnitCost
+	r.TotalValue = r.TotalWeight * r.UnitCost
+	r.Currency = currency
+}
+
+// WithoutCosts returns a copy of the WasteReport having the monetary
+// fields removed from results and the cost-columns removed from outputs,
+// for callers not allowed to see costs.
+func (s *WasteReport) WithoutCosts() *WasteReport {
+	report := *s
+	report.CostsHidden = true
+	report.ReportResult = make([]ReportResult, len(s.ReportResult))
+	for i, r := range s.ReportResult {
+		report.ReportResult[i] = r.WithoutCosts()
+	}
+	return &report
+}
+
+// WithoutCosts returns the ReportResult with its monetary fields removed.
+func (r ReportResult) WithoutCosts() ReportResult {
+	r.UnitCost = 0
+	r.WasteValue = 0
+	r.TotalValue = 0
+	r.Currency = ""
+	return r
+}
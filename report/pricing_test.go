@@ -0,0 +1,132 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pricing", func() {
+	var results []ReportResult
+
+	BeforeEach(func() {
+		results = []ReportResult{
+			ReportResult{
+				SKU:         "test-sku1",
+				Name:        "test-name1",
+				WasteWeight: 10,
+				TotalWeight: 100,
+				UnitCost:    2.5,
+			},
+			ReportResult{
+				SKU:         "test-sku2",
+				Name:        "test-name2",
+				WasteWeight: 4,
+				TotalWeight: 40,
+			},
+		}
+	})
+
+	It("prices results having unit-cost from waste-events", func() {
+		err := Pricing{Currency: "EUR"}.applyPricing(results)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(results[0].WasteValue).To(Equal(float64(25)))
+		Expect(results[0].TotalValue).To(Equal(float64(250)))
+		Expect(results[0].Currency).To(Equal("EUR"))
+		// No catalogue, so the second result stays unpriced
+		Expect(results[1].WasteValue).To(BeZero())
+		Expect(results[1].Currency).To(BeEmpty())
+	})
+
+	It("sums values in summary", func() {
+		results[1].UnitCost = 1
+		err := Pricing{Currency: "USD"}.applyPricing(results)
+		Expect(err).ToNot(HaveOccurred())
+
+		summary := Summarize(results, TopOffendersCount)
+		Expect(summary.WasteValue).To(Equal(float64(29)))
+		Expect(summary.TotalValue).To(Equal(float64(290)))
+		Expect(summary.Currency).To(Equal("USD"))
+	})
+
+	It("includes cost columns unless costs are hidden", func() {
+		err := Pricing{Currency: "USD"}.applyPricing(results)
+		Expect(err).ToNot(HaveOccurred())
+
+		buf := &bytes.Buffer{}
+		err = WriteCSV(buf, &WasteReport{ReportResult: results})
+		Expect(err).ToNot(HaveOccurred())
+		rows, err := csv.NewReader(buf).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows[0]).To(Equal([]string{
+			"sku", "name", "wasteWeight", "totalWeight", "unit",
+			"unitCost", "wasteValue", "totalValue", "currency",
+		}))
+		Expect(rows[1]).To(Equal([]string{
+			"test-sku1", "test-name1", "10", "100", "", "2.5", "25", "250", "USD",
+		}))
+	})
+
+	It("removes costs without changing the report", func() {
+		err := Pricing{Currency: "USD"}.applyPricing(results)
+		Expect(err).ToNot(HaveOccurred())
+		wasteReport := &WasteReport{ReportResult: results}
+
+		withoutCosts := wasteReport.WithoutCosts()
+		Expect(withoutCosts.ReportResult[0]).To(Equal(ReportResult{
+			SKU:         "test-sku1",
+			Name:        "test-name1",
+			WasteWeight: 10,
+			TotalWeight: 100,
+		}))
+		Expect(wasteReport.ReportResult[0].Currency).To(Equal("USD"))
+
+		buf := &bytes.Buffer{}
+		err = WriteCSV(buf, withoutCosts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buf.String()).ToNot(ContainSubstring("wasteValue"))
+	})
+
+	It("values the summed weights at the unit-cost weighted by weight", func() {
+		// Waste-events of 2kg at 1.0, 8kg at 2.0, and 10kg without unit-cost
+		aggResults := []interface{}{
+			map[string]interface{}{
+				"_id": map[string]interface{}{
+					"sku":  "test-sku1",
+					"name": "test-name1",
+				},
+				"sum_waste":        float64(20),
+				"sum_total":        float64(100),
+				"sum_cost":         float64(18),
+				"sum_priced_waste": float64(10),
+			},
+		}
+		results, err := ResultsFromAggregate(aggResults)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].WasteWeight).To(Equal(float64(20)))
+		Expect(results[0].UnitCost).To(BeNumerically("~", 1.8, 1e-9))
+
+		err = Pricing{Currency: "USD"}.applyPricing(results)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].WasteValue).To(BeNumerically("~", 36, 1e-9))
+		Expect(results[0].TotalValue).To(BeNumerically("~", 180, 1e-9))
+	})
+
+	It("leaves results unpriced if no waste-event has a unit-cost", func() {
+		results, err := ResultsFromAggregate([]interface{}{
+			map[string]interface{}{
+				"_id":              map[string]interface{}{"sku": "test-sku1"},
+				"sum_waste":        float64(20),
+				"sum_total":        float64(100),
+				"sum_cost":         int32(0),
+				"sum_priced_waste": float64(0),
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].UnitCost).To(BeZero())
+	})
+})
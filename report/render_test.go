@@ -0,0 +1,82 @@
+package report
+
+import (
+	"bytes"
+
+	"github.com/mongodb/mongo-go-driver/bson"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Report rendering", func() {
+	wasteReport := &WasteReport{
+		SearchQuery: WasteItemParams{
+			Timestamp: &Comparator{
+				Gt: 1529315000,
+				Lt: 1551997372,
+			},
+		},
+		ReportResult: []ReportResult{
+			ReportResult{
+				SKU:         "test-sku1",
+				Name:        "<script>alert(1)</script>",
+				WasteWeight: 10,
+				TotalWeight: 100,
+			},
+			ReportResult{
+				SKU:         "test-sku2",
+				Name:        "test-name2",
+				WasteWeight: 30,
+				TotalWeight: 60,
+			},
+		},
+	}
+
+	It("renders HTML with chart and escaped values", func() {
+		buf := &bytes.Buffer{}
+		err := WriteHTML(buf, wasteReport)
+		Expect(err).ToNot(HaveOccurred())
+
+		html := buf.String()
+		Expect(html).To(ContainSubstring("<svg"))
+		Expect(html).To(ContainSubstring("test-sku2"))
+		Expect(html).To(ContainSubstring("40.00"))
+		Expect(html).ToNot(ContainSubstring("<script>"))
+	})
+
+	It("scales chart-bars to the largest weight", func() {
+		chart := wasteChart(wasteReport.ReportResult)
+		Expect(chart.Bars).To(HaveLen(2))
+		// Sorted by waste-weight
+		Expect(chart.Bars[0].Label).To(HavePrefix("test-sku2"))
+		Expect(chart.Bars[1].TotalWidth).To(
+			Equal(float64(chartWidth - chartLabelWidth)),
+		)
+	})
+
+	It("renders PDF", func() {
+		buf := &bytes.Buffer{}
+		err := WritePDF(buf, wasteReport)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buf.String()).To(HavePrefix("%PDF"))
+	})
+
+	It("renders a report read back from storage", func() {
+		in, err := bson.Marshal(wasteReport)
+		Expect(err).ToNot(HaveOccurred())
+		storedReport := &WasteReport{}
+		err = bson.Unmarshal(in, storedReport)
+		Expect(err).ToNot(HaveOccurred())
+
+		buf := &bytes.Buffer{}
+		err = WriteHTML(buf, storedReport)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buf.String()).To(ContainSubstring("test-sku2"))
+
+		document, err := MarshalReport(storedReport, FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(document)).To(ContainSubstring("test-sku1"))
+		Expect(string(document)).To(ContainSubstring("test-sku2"))
+	})
+})
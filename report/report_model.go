@@ -12,6 +12,9 @@ type WasteReport struct {
 	ReportID     uuuid.UUID        `bson:"reportID,omitempty" json:"reportID,omitempty"`
 	SearchQuery  WasteItemParams   `bson:"searchQuery,omitempty" json:"searchQuery,omitempty"`
 	ReportResult []ReportResult    `bson:"reportResult,omitempty" json:"reportResult,omitempty"`
+	// CostsHidden is set by WithoutCosts, so outputs leave out the cost-columns.
+	// It is not stored, since costs are hidden per caller.
+	CostsHidden bool `bson:"-" json:"-"`
 }
 
 type WasteReportBSON struct {
@@ -19,20 +22,45 @@ type WasteReportBSON struct {
 	ReportID     string            `bson:"reportID,omitempty" json:"reportID,omitempty"`
 	SearchQuery  WasteItemParams   `bson:"searchQuery,omitempty" json:"searchQuery,omitempty"`
 	ReportResult []ReportResult    `bson:"reportResult,omitempty" json:"reportResult,omitempty"`
+	// LegacySearchQuery and LegacyReportResult are read from reports stored
+	// under lowercase keys by earlier versions.
+	LegacySearchQuery  *WasteItemParams `bson:"searchquery,omitempty" json:"-"`
+	LegacyReportResult []ReportResult   `bson:"reportresult,omitempty" json:"-"`
 }
 
 type ReportResult struct {
-	SKU         string  `bson:"sku,omitempty" json:"sku,omitempty"`
-	Name        string  `bson:"name,omitempty" json:"name,omitempty"`
+	// Department, Category and Subcategory are the result's position in the
+	// category hierarchy, and are only set if the report is rolled-up.
+	Department  string `bson:"department,omitempty" json:"department,omitempty"`
+	Category    string `bson:"category,omitempty" json:"category,omitempty"`
+	Subcategory string `bson:"subcategory,omitempty" json:"subcategory,omitempty"`
+	// Subtotal marks the sum of the preceding results in its hierarchy-level,
+	// and is left out of the report's totals.
+	Subtotal bool   `bson:"subtotal,omitempty" json:"subtotal,omitempty"`
+	StoreID  string `bson:"storeID,omitempty" json:"storeID,omitempty"`
+	SKU      string `bson:"sku,omitempty" json:"sku,omitempty"`
+	Name     string `bson:"name,omitempty" json:"name,omitempty"`
+	// Reason and Disposal are only set if the report is grouped by them.
+	Reason      string  `bson:"reason,omitempty" json:"reason,omitempty"`
+	Disposal    string  `bson:"disposal,omitempty" json:"disposal,omitempty"`
 	WasteWeight float64 `bson:"wasteWeight,omitempty" json:"wasteWeight,omitempty"`
 	TotalWeight float64 `bson:"totalWeight,omitempty" json:"totalWeight,omitempty"`
+	// Unit is the weight-unit of the weights. Reports are stored in the
+	// CanonicalUnit, and can be converted to other units using InUnit.
+	Unit string `bson:"unit,omitempty" json:"unit,omitempty"`
+	// UnitCost is the cost per unit of weight. The cost-fields are
+	// only set if the unit-cost of the SKU is known.
+	UnitCost   float64 `bson:"unitCost,omitempty" json:"unitCost,omitempty"`
+	WasteValue float64 `bson:"wasteValue,omitempty" json:"wasteValue,omitempty"`
+	TotalValue float64 `bson:"totalValue,omitempty" json:"totalValue,omitempty"`
+	Currency   string  `bson:"currency,omitempty" json:"currency,omitempty"`
 }
 
 func (s WasteReport) MarshalBSON() ([]byte, error) {
 	sm := map[string]interface{}{
 		"reportid":     s.ReportID.String(),
-		"searchquery":  s.SearchQuery,
-		"reportresult": s.ReportResult,
+		"searchQuery":  s.SearchQuery,
+		"reportResult": s.ReportResult,
 	}
 	if s.ID != objectid.NilObjectID {
 		sm["_id"] = s.ID
@@ -62,16 +90,35 @@ func (s *WasteReport) UnmarshalBSON(in []byte) error {
 		err = errors.Wrap(err, "UnmarshalBSON Error: Error parsing SaleID")
 	}
 	s.ReportID = reportID
+	s.SearchQuery = sb.SearchQuery
+	if sb.LegacySearchQuery != nil {
+		s.SearchQuery = *sb.LegacySearchQuery
+	}
+	if sb.ReportResult == nil {
+		sb.ReportResult = sb.LegacyReportResult
+	}
 
 	if s.ReportResult == nil {
 		s.ReportResult = make([]ReportResult, 0)
 	}
 	for _, v := range sb.ReportResult {
 		s.ReportResult = append(s.ReportResult, ReportResult{
+			Department:  v.Department,
+			Category:    v.Category,
+			Subcategory: v.Subcategory,
+			Subtotal:    v.Subtotal,
+			StoreID:     v.StoreID,
 			SKU:         v.SKU,
 			Name:        v.Name,
+			Reason:      v.Reason,
+			Disposal:    v.Disposal,
 			WasteWeight: v.WasteWeight,
 			TotalWeight: v.TotalWeight,
+			Unit:        v.Unit,
+			UnitCost:    v.UnitCost,
+			WasteValue:  v.WasteValue,
+			TotalValue:  v.TotalValue,
+			Currency:    v.Currency,
 		})
 	}
 	return nil
@@ -0,0 +1,77 @@
+package report
+
+import (
+	"github.com/TerrexTech/uuuid"
+	"github.com/mongodb/mongo-go-driver/bson"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WasteReport", func() {
+	It("round-trips through BSON", func() {
+		reportID, err := uuuid.NewV4()
+		Expect(err).ToNot(HaveOccurred())
+		wasteReport := WasteReport{
+			ReportID: reportID,
+			SearchQuery: WasteItemParams{
+				Timestamp: &Comparator{
+					Gt: 1529315000,
+					Lt: 1551997372,
+				},
+				StoreID: &InComparator{
+					In: []string{"test-store"},
+				},
+			},
+			ReportResult: []ReportResult{
+				ReportResult{
+					StoreID:     "test-store",
+					SKU:         "test-sku",
+					Name:        "test-name",
+					WasteWeight: 10,
+					TotalWeight: 100,
+					Unit:        CanonicalUnit,
+					UnitCost:    2,
+					WasteValue:  20,
+					TotalValue:  200,
+				},
+			},
+		}
+
+		in, err := bson.Marshal(wasteReport)
+		Expect(err).ToNot(HaveOccurred())
+		out := &WasteReport{}
+		err = bson.Unmarshal(in, out)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(out.ReportID).To(Equal(reportID))
+		Expect(out.SearchQuery.Timestamp).To(Equal(wasteReport.SearchQuery.Timestamp))
+		Expect(out.SearchQuery.Stores()).To(Equal([]string{"test-store"}))
+		Expect(out.ReportResult).To(Equal(wasteReport.ReportResult))
+	})
+
+	It("reads reports stored under lowercase keys", func() {
+		in, err := bson.Marshal(map[string]interface{}{
+			"reportID": "a6c3a5a6-0b1e-4a5b-9d2f-3c4d5e6f7a8b",
+			"searchquery": WasteItemParams{
+				StoreID: &InComparator{
+					In: []string{"test-store"},
+				},
+			},
+			"reportresult": []ReportResult{
+				ReportResult{
+					SKU:         "test-sku",
+					WasteWeight: 10,
+				},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		out := &WasteReport{}
+		err = bson.Unmarshal(in, out)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(out.SearchQuery.Stores()).To(Equal([]string{"test-store"}))
+		Expect(out.ReportResult).To(HaveLen(1))
+		Expect(out.ReportResult[0].SKU).To(Equal("test-sku"))
+	})
+})
@@ -1,9 +1,15 @@
 package report
 
+// Comparator compares numeric fields, such as the timestamp.
 type Comparator struct {
-	Lt float64     `json:"$lt,omitempty"`
-	Gt float64     `json:"$gt,omitempty"`
-	Eq interface{} `json:"$eq,omitempty"`
+	Lt float64 `json:"$lt,omitempty"`
+	Gt float64 `json:"$gt,omitempty"`
+	Eq float64 `json:"$eq,omitempty"`
+}
+
+// InComparator matches any of the listed values.
+type InComparator struct {
+	In []string `json:"$in"`
 }
 
 // {
@@ -0,0 +1,38 @@
+package report
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WasteItemParams", func() {
+	It("filters and groups by store", func() {
+		params := WasteItemParams{}
+		err := json.Unmarshal(
+			[]byte(`{"timestamp":{"$gt":9,"$lt":21},"storeID":{"$in":["store-1"]},"groupByStore":true}`),
+			&params,
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(params.Stores()).To(Equal([]string{"store-1"}))
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline[0].Lookup("$match", "timestamp", "$gt").Double()).To(Equal(float64(9)))
+		Expect(pipeline[0].Lookup("$match", "timestamp", "$lt").Double()).To(Equal(float64(21)))
+		Expect(pipeline[0].Lookup("$match", "storeID", "$in").MutableArray().Len()).To(Equal(1))
+		Expect(pipeline[1].Lookup("$group", "_id", "storeID").StringValue()).To(Equal("$storeID"))
+	})
+
+	It("includes all stores by default", func() {
+		params := WasteItemParams{
+			Timestamp: &Comparator{Gt: 9, Lt: 21},
+		}
+		Expect(params.Stores()).To(BeNil())
+		pipeline, err := params.pipeline()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pipeline[0].Lookup("$match", "storeID")).To(BeNil())
+		Expect(pipeline[1].Lookup("$group", "_id").MutableDocument().Len()).To(Equal(2))
+	})
+})
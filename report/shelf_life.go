@@ -0,0 +1,422 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+
+	util "github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// DayBucket is a range of days in a DayDistribution, such as "3-7" for
+// 3 (inclusive) to 7 (exclusive) days. The first and last buckets are open-ended.
+type DayBucket struct {
+	Label       string  `json:"label"`
+	Count       int     `json:"count"`
+	WasteWeight float64 `json:"wasteWeight"`
+}
+
+// dayBounds are the upper-bounds (exclusive) of the buckets in a
+// DayDistribution, and the last bucket has no upper-bound.
+type dayBounds struct {
+	bounds []float64
+	labels []string
+}
+
+// receiptBuckets bucket the days after receiving.
+var receiptBuckets = dayBounds{
+	bounds: []float64{1, 3, 7, 14, 30},
+	labels: []string{"<1", "1-3", "3-7", "7-14", "14-30", "30+"},
+}
+
+// expiryBuckets bucket the days before expiry, where negative
+// days are after the item expired.
+var expiryBuckets = dayBounds{
+	bounds: []float64{0, 1, 3, 7, 14},
+	labels: []string{"expired", "<1", "1-3", "3-7", "7-14", "14+"},
+}
+
+// DayDistribution is the distribution of the days waste-events happened
+// after receiving, or before expiry.
+type DayDistribution struct {
+	Count       int         `json:"count"`
+	WasteWeight float64     `json:"wasteWeight"`
+	Min         float64     `json:"min"`
+	Max         float64     `json:"max"`
+	Mean        float64     `json:"mean"`
+	Median      float64     `json:"median"`
+	P90         float64     `json:"p90"`
+	Buckets     []DayBucket `json:"buckets"`
+}
+
+// dayValue is the days for a single waste-event.
+type dayValue struct {
+	days   float64
+	weight float64
+}
+
+// newDayDistribution calculates the DayDistribution of the values, or returns
+// nil if there are none.
+func newDayDistribution(values []dayValue, b dayBounds) *DayDistribution {
+	if len(values) == 0 {
+		return nil
+	}
+
+	d := &DayDistribution{
+		Count:   len(values),
+		Buckets: make([]DayBucket, len(b.labels)),
+	}
+	for i, label := range b.labels {
+		d.Buckets[i].Label = label
+	}
+
+	days := make([]float64, len(values))
+	var sum float64
+	for i, v := range values {
+		days[i] = v.days
+		sum += v.days
+		d.WasteWeight += v.weight
+
+		bucket := sort.SearchFloat64s(b.bounds, v.days)
+		// SearchFloat64s finds the first bound >= days, while
+		// bounds are exclusive
+		if bucket < len(b.bounds) && b.bounds[bucket] == v.days {
+			bucket++
+		}
+		d.Buckets[bucket].Count++
+		d.Buckets[bucket].WasteWeight += v.weight
+	}
+	sort.Float64s(days)
+	d.Min = days[0]
+	d.Max = days[len(days)-1]
+	d.Mean = sum / float64(len(days))
+	d.Median = percentile(days, 0.5)
+	d.P90 = percentile(days, 0.9)
+	return d
+}
+
+// percentile returns the nearest-rank percentile p (0-1) of the sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// ShelfLifeParams are the filters for the shelf-life report.
+type ShelfLifeParams struct {
+	// Timestamp limits the waste-events to the time-window.
+	Timestamp *Comparator   `json:"timestamp,omitempty"`
+	SKU       *InComparator `json:"sku,omitempty"`
+	StoreID   *InComparator `json:"storeID,omitempty"`
+}
+
+// Validate checks that the params have both timestamp $gt and $lt,
+// so they can't match every waste-event.
+func (p ShelfLifeParams) Validate() error {
+	if p.Timestamp == nil || p.Timestamp.Gt == 0 || p.Timestamp.Lt == 0 {
+		return errors.New("Both timestamp $gt and $lt are required")
+	}
+	if p.Timestamp.Lt <= p.Timestamp.Gt {
+		return errors.New("Timestamp $lt must be after $gt")
+	}
+	return nil
+}
+
+// Stores returns the stores the params are limited to, or nil for all stores.
+func (p ShelfLifeParams) Stores() []string {
+	if p.StoreID == nil {
+		return nil
+	}
+	return p.StoreID.In
+}
+
+// pipeline returns the aggregation-pipeline listing the matched WasteItems.
+func (p ShelfLifeParams) pipeline() ([]*bson.Document, error) {
+	b := newPipelineBuilder()
+	b.matchField("timestamp", timestampConds(p.Timestamp)...)
+	b.matchIn("sku", p.SKU)
+	b.matchIn("storeID", p.StoreID)
+	return b.buildMatch()
+}
+
+// ShelfLifeResult is the distribution of the days the waste-events of a
+// SKU happened after receiving, and before expiry. Distributions are nil if
+// no waste-event of the SKU has the date. Weights are in Unit.
+type ShelfLifeResult struct {
+	SKU              string           `json:"sku"`
+	Name             string           `json:"name,omitempty"`
+	Events           int              `json:"events"`
+	WasteWeight      float64          `json:"wasteWeight"`
+	Unit             string           `json:"unit"`
+	DaysAfterReceipt *DayDistribution `json:"daysAfterReceipt,omitempty"`
+	DaysBeforeExpiry *DayDistribution `json:"daysBeforeExpiry,omitempty"`
+}
+
+// ShelfLifeReport returns the ShelfLifeResult for each SKU having waste-events
+// matching the params, sorted by SKU. Waste-events without their own receipt
+// or expiry dates use their lot's dates from lotColl, if not nil.
+func ShelfLifeReport(
+	params ShelfLifeParams,
+	itemWasteColl *mongo.Collection,
+	lotColl *mongo.Collection,
+) ([]ShelfLifeResult, error) {
+	err := params.Validate()
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLifeReport: Invalid params")
+		return nil, err
+	}
+	pipeline, err := params.pipeline()
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLifeReport: Error in generating pipeline")
+		log.Println(err)
+		return nil, err
+	}
+	aggResults, err := itemWasteColl.Aggregate(pipeline)
+	if err != nil {
+		err = errors.Wrap(err, "ShelfLifeReport: Error in getting aggregate results")
+		log.Println(err)
+		return nil, err
+	}
+
+	lots := map[string]LotInfo{}
+	if lotColl != nil {
+		// Many waste-events are against the same lot
+		lotIDs := []string{}
+		seen := map[string]bool{}
+		for _, v := range aggResults {
+			m, _ := v.(map[string]interface{})
+			lot, _ := m["lot"].(string)
+			if lot != "" && !seen[lot] {
+				seen[lot] = true
+				lotIDs = append(lotIDs, lot)
+			}
+		}
+		if len(lotIDs) > 0 {
+			lots, err = FindLots(lotColl, map[string]interface{}{
+				"lot": map[string]interface{}{
+					"$in": lotIDs,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return shelfLifeResults(aggResults, lots)
+}
+
+// shelfLifeResults calculates the ShelfLifeResults from the WasteItems
+// listed by the shelf-life pipeline, using the lots' dates for items
+// without their own.
+func shelfLifeResults(aggResults []interface{}, lots map[string]LotInfo) ([]ShelfLifeResult, error) {
+	type skuValues struct {
+		result  ShelfLifeResult
+		receipt []dayValue
+		expiry  []dayValue
+	}
+	index := map[string]int{}
+	skus := []*skuValues{}
+
+	for i, v := range aggResults {
+		m, assertOK := v.(map[string]interface{})
+		if !assertOK {
+			return nil, errors.Errorf(
+				"Error asserting aggregate-result at index %d to map[string]interface{}", i,
+			)
+		}
+
+		item := WasteItem{}
+		item.SKU, _ = m["sku"].(string)
+		item.Name, _ = m["name"].(string)
+		item.Lot, _ = m["lot"].(string)
+		item.Unit, _ = m["unit"].(string)
+		for field, dst := range map[string]*int64{
+			"timestamp":  &item.Timestamp,
+			"receivedAt": &item.ReceivedAt,
+			"expiresAt":  &item.ExpiresAt,
+		} {
+			if m[field] == nil {
+				continue
+			}
+			value, err := util.AssertInt64(m[field])
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error asserting %s of aggregate-result at index %d", field, i)
+			}
+			*dst = value
+		}
+		if m["weight"] != nil {
+			weight, err := util.AssertFloat64(m["weight"])
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error asserting weight of aggregate-result at index %d", i)
+			}
+			item.Weight = weight
+		}
+		err := item.Normalize()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting aggregate-result at index %d", i)
+		}
+
+		lot := lots[item.Lot]
+		if item.ReceivedAt == 0 {
+			item.ReceivedAt = lot.ReceivedAt
+		}
+		if item.ExpiresAt == 0 {
+			item.ExpiresAt = lot.ExpiresAt
+		}
+
+		s, exists := index[item.SKU]
+		if !exists {
+			s = len(skus)
+			index[item.SKU] = s
+			skus = append(skus, &skuValues{
+				result: ShelfLifeResult{
+					SKU:  item.SKU,
+					Name: item.Name,
+					Unit: CanonicalUnit,
+				},
+			})
+		}
+		values := skus[s]
+		values.result.Events++
+		values.result.WasteWeight += item.Weight
+		if item.ReceivedAt != 0 {
+			values.receipt = append(values.receipt, dayValue{
+				days:   float64(item.Timestamp-item.ReceivedAt) / secondsPerDay,
+				weight: item.Weight,
+			})
+		}
+		if item.ExpiresAt != 0 {
+			values.expiry = append(values.expiry, dayValue{
+				days:   float64(item.ExpiresAt-item.Timestamp) / secondsPerDay,
+				weight: item.Weight,
+			})
+		}
+	}
+
+	results := make([]ShelfLifeResult, len(skus))
+	for i, values := range skus {
+		r := values.result
+		r.DaysAfterReceipt = newDayDistribution(values.receipt, receiptBuckets)
+		r.DaysBeforeExpiry = newDayDistribution(values.expiry, expiryBuckets)
+		results[i] = r
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].SKU < results[j].SKU
+	})
+	return results, nil
+}
+
+// ShelfLifeInUnit returns a copy of the ShelfLifeResults with weights
+// converted to the weight-unit.
+func ShelfLifeInUnit(results []ShelfLifeResult, unit string) ([]ShelfLifeResult, error) {
+	if unit == "" {
+		unit = CanonicalUnit
+	}
+	converted := make([]ShelfLifeResult, len(results))
+	for i, r := range results {
+		ratio, err := ConvertWeight(1, r.Unit, unit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting SKU %s", r.SKU)
+		}
+		r.WasteWeight *= ratio
+		r.Unit = unit
+		r.DaysAfterReceipt = r.DaysAfterReceipt.scaled(ratio)
+		r.DaysBeforeExpiry = r.DaysBeforeExpiry.scaled(ratio)
+		converted[i] = r
+	}
+	return converted, nil
+}
+
+// scaled returns a copy of the DayDistribution with weights multiplied by ratio.
+func (d *DayDistribution) scaled(ratio float64) *DayDistribution {
+	if d == nil {
+		return nil
+	}
+	s := *d
+	s.WasteWeight *= ratio
+	s.Buckets = make([]DayBucket, len(d.Buckets))
+	for i, b := range d.Buckets {
+		b.WasteWeight *= ratio
+		s.Buckets[i] = b
+	}
+	return &s
+}
+
+// MarshalShelfLife converts the ShelfLifeResults to the output-format, which
+// is either JSON, or CSV having a row per SKU without the buckets.
+func MarshalShelfLife(results []ShelfLifeResult, format string) ([]byte, error) {
+	switch format {
+	case "", FormatJSON:
+		return json.Marshal(results)
+	case FormatCSV:
+		buf := &bytes.Buffer{}
+		err := WriteShelfLifeCSV(buf, results)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, errors.Errorf("Unsupported output-format for shelf-life: %s", format)
+}
+
+// WriteShelfLifeCSV writes a row for each ShelfLifeResult, having the
+// summary statistics of its distributions. Statistics of missing
+// distributions are blank.
+func WriteShelfLifeCSV(w io.Writer, results []ShelfLifeResult) error {
+	cw := csv.NewWriter(w)
+	headers := []string{"sku", "name", "events", "wasteWeight", "unit"}
+	for _, prefix := range []string{"receipt", "expiry"} {
+		for _, stat := range []string{"Count", "Min", "Median", "Mean", "P90", "Max"} {
+			headers = append(headers, prefix+stat)
+		}
+	}
+	err := cw.Write(headers)
+	if err != nil {
+		err = errors.Wrap(err, "WriteShelfLifeCSV: Error writing header-row")
+		return err
+	}
+
+	for i, r := range results {
+		row := []string{
+			r.SKU, r.Name, strconv.Itoa(r.Events), formatFloat(r.WasteWeight), r.Unit,
+		}
+		for _, d := range []*DayDistribution{r.DaysAfterReceipt, r.DaysBeforeExpiry} {
+			if d == nil {
+				row = append(row, "", "", "", "", "", "")
+				continue
+			}
+			row = append(
+				row,
+				strconv.Itoa(d.Count),
+				formatFloat(d.Min),
+				formatFloat(d.Median),
+				formatFloat(d.Mean),
+				formatFloat(d.P90),
+				formatFloat(d.Max),
+			)
+		}
+		err = cw.Write(row)
+		if err != nil {
+			err = errors.Wrapf(err, "WriteShelfLifeCSV: Error writing row at index: %d", i)
+			return err
+		}
+	}
+
+	cw.Flush()
+	err = cw.Error()
+	if err != nil {
+		err = errors.Wrap(err, "WriteShelfLifeCSV: Error flushing CSV-writer")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shelf-life", func() {
+	const day = secondsPerDay
+
+	It("requires both timestamp bounds", func() {
+		Expect(ShelfLifeParams{}.Validate()).ToNot(Succeed())
+		Expect(ShelfLifeParams{
+			Timestamp: &Comparator{Gt: 9},
+			SKU:       &InComparator{In: []string{"sku1"}},
+		}.Validate()).ToNot(Succeed())
+		Expect(ShelfLifeParams{
+			Timestamp: &Comparator{Gt: 21, Lt: 9},
+		}.Validate()).ToNot(Succeed())
+		Expect(ShelfLifeParams{
+			Timestamp: &Comparator{Gt: 9, Lt: 21},
+		}.Validate()).To(Succeed())
+	})
+
+	It("calculates distributions of days", func() {
+		values := []dayValue{}
+		for _, days := range []float64{5, 0.5, 1, 3, 10, 2, 40, 7, 6, 4} {
+			values = append(values, dayValue{days: days, weight: 1})
+		}
+		d := newDayDistribution(values, receiptBuckets)
+
+		Expect(d.Count).To(Equal(10))
+		Expect(d.WasteWeight).To(Equal(10.0))
+		Expect(d.Min).To(Equal(0.5))
+		Expect(d.Max).To(Equal(40.0))
+		Expect(d.Mean).To(Equal(7.85))
+		Expect(d.Median).To(Equal(4.0))
+		Expect(d.P90).To(Equal(10.0))
+
+		counts := []int{}
+		for _, b := range d.Buckets {
+			counts = append(counts, b.Count)
+		}
+		// Bounds are exclusive, so 1, 3 and 7 are in the next bucket
+		Expect(counts).To(Equal([]int{1, 2, 4, 2, 0, 1}))
+
+		Expect(newDayDistribution(nil, receiptBuckets)).To(BeNil())
+	})
+
+	It("buckets waste after expiry as expired", func() {
+		d := newDayDistribution([]dayValue{
+			dayValue{days: -2, weight: 3},
+			dayValue{days: 0, weight: 1},
+		}, expiryBuckets)
+		Expect(d.Buckets[0]).To(Equal(DayBucket{Label: "expired", Count: 1, WasteWeight: 3}))
+		Expect(d.Buckets[1]).To(Equal(DayBucket{Label: "<1", Count: 1, WasteWeight: 1}))
+	})
+
+	It("uses the lot's dates for items without their own", func() {
+		items := []interface{}{
+			map[string]interface{}{
+				"sku": "sku1", "name": "gala", "lot": "lot1",
+				"weight": 2.0, "timestamp": int64(3 * day),
+			},
+			map[string]interface{}{
+				"sku": "sku1", "name": "gala", "lot": "lot1",
+				"weight": 1000.0, "unit": UnitGram, "timestamp": int64(5 * day),
+				"receivedAt": int64(4 * day), "expiresAt": int64(4 * day),
+			},
+			map[string]interface{}{
+				"sku": "sku0", "weight": 1.0, "timestamp": int64(day),
+			},
+		}
+		results, err := shelfLifeResults(items, map[string]LotInfo{
+			"lot1": LotInfo{Lot: "lot1", ReceivedAt: day, ExpiresAt: 7 * day},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].SKU).To(Equal("sku0"))
+		Expect(results[0].DaysAfterReceipt).To(BeNil())
+		Expect(results[0].DaysBeforeExpiry).To(BeNil())
+
+		Expect(results[1].Events).To(Equal(2))
+		Expect(results[1].WasteWeight).To(Equal(3.0))
+		Expect(results[1].DaysAfterReceipt.Min).To(Equal(1.0))
+		Expect(results[1].DaysAfterReceipt.Max).To(Equal(2.0))
+		Expect(results[1].DaysBeforeExpiry.Min).To(Equal(-1.0))
+		Expect(results[1].DaysBeforeExpiry.Max).To(Equal(4.0))
+	})
+
+	It("converts weights and writes a CSV-row per SKU", func() {
+		results := []ShelfLifeResult{
+			ShelfLifeResult{
+				SKU: "sku1", Events: 1, WasteWeight: 2, Unit: CanonicalUnit,
+				DaysAfterReceipt: newDayDistribution(
+					[]dayValue{dayValue{days: 2, weight: 2}}, receiptBuckets,
+				),
+			},
+		}
+		converted, err := ShelfLifeInUnit(results, UnitGram)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(converted[0].WasteWeight).To(BeNumerically("~", 2000, 1e-9))
+		Expect(converted[0].DaysAfterReceipt.Buckets[1].WasteWeight).To(BeNumerically("~", 2000, 1e-9))
+		Expect(results[0].DaysAfterReceipt.Buckets[1].WasteWeight).To(Equal(2.0))
+
+		doc, err := MarshalShelfLife(results, FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		rows, err := csv.NewReader(bytes.NewReader(doc)).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rows[1]).To(Equal([]string{
+			"sku1", "", "1", "2", "kg",
+			"1", "2", "2", "2", "2", "2",
+			"", "", "", "", "", "",
+		}))
+	})
+})
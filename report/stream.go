@@ -0,0 +1,201 @@
+package report
+
+import (
+	"context"
+	"log"
+
+	"github.com/TerrexTech/agg-itemwaste-report/metrics"
+	"github.com/TerrexTech/agg-itemwaste-report/tracing"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// streamBatchSize is the number of aggregate-results StreamReport reads from
+// the cursor before pricing and sending them, so catalogue-prices are found
+// for a batch at a time.
+const streamBatchSize = 100
+
+// ResultStream receives the ReportResults of StreamReport.
+type ResultStream interface {
+	// Start is called with the reportID before any results are sent.
+	Start(reportID uuuid.UUID) error
+	Send(r ReportResult) error
+}
+
+// resultCursor is the part of the aggregation-cursor read by streamResults.
+type resultCursor interface {
+	Next(ctx context.Context) bool
+	Decode(v interface{}) error
+	Err() error
+}
+
+// StreamReport generates the report same as GenerateReport, but sends each
+// ReportResult to the stream as it is read from the aggregation-cursor, instead
+// of first building the whole report. The sent results are also kept, and are
+// stored as the WasteReport in reportColl after the last one is sent, so
+// a failure to store the report is returned after the results were sent.
+// Rolled-up reports need all results for their subtotals, so these are
+// generated in full using GenerateReport, and then sent.
+func StreamReport(
+	ctx context.Context,
+	params WasteItemParams,
+	itemWasteColl *mongo.Collection,
+	reportColl *mongo.Collection,
+	pricing Pricing,
+	catalog *mongo.Collection,
+	stream ResultStream,
+) (*WasteReport, error) {
+	if params.RollUp != "" {
+		return sendReport(ctx, params, itemWasteColl, reportColl, pricing, catalog, stream)
+	}
+
+	aggParams, err := aggregateParams(ctx, params, catalog)
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := aggParams.reportPipeline()
+	if err != nil {
+		return nil, err
+	}
+	reportID, err := uuuid.NewV4()
+	if err != nil {
+		err = errors.Wrap(err, "Error in generating reportID")
+		return nil, err
+	}
+	err = stream.Start(reportID)
+	if err != nil {
+		err = errors.Wrap(err, "Error starting result-stream")
+		return nil, err
+	}
+
+	aggCtx, aggSpan := tracing.Tracer().Start(ctx, "mongo.aggregate")
+	timer := prometheus.NewTimer(metrics.AggregationDuration)
+	cur, err := itemWasteColl.Collection().Aggregate(aggCtx, pipeline)
+	timer.ObserveDuration()
+	if err != nil {
+		err = errors.Wrap(err, "Error getting results from ItemWasteCollection")
+		log.Println(err)
+		tracing.RecordError(aggSpan, err)
+		aggSpan.End()
+		return nil, err
+	}
+	results, err := streamResults(aggCtx, cur, pricing, stream)
+	closeErr := cur.Close(aggCtx)
+	if err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "Error closing aggregation-cursor")
+	}
+	if err != nil {
+		tracing.RecordError(aggSpan, err)
+		aggSpan.End()
+		return nil, err
+	}
+	aggSpan.End()
+	if len(results) < 1 {
+		err = errors.New(
+			"Error: No result found from agg_itemwaste collection - Function = StreamReport",
+		)
+		return nil, err
+	}
+
+	wasteReport := &WasteReport{
+		ReportID:     reportID,
+		SearchQuery:  params,
+		ReportResult: results,
+	}
+	err = storeReport(ctx, wasteReport, reportColl)
+	if err != nil {
+		return nil, err
+	}
+	return wasteReport, nil
+}
+
+// sendReport generates the whole report using GenerateReport, and then sends
+// its results to the stream.
+func sendReport(
+	ctx context.Context,
+	params WasteItemParams,
+	itemWasteColl *mongo.Collection,
+	reportColl *mongo.Collection,
+	pricing Pricing,
+	catalog *mongo.Collection,
+	stream ResultStream,
+) (*WasteReport, error) {
+	wasteReport, err := GenerateReport(ctx, params, itemWasteColl, reportColl, pricing, catalog)
+	if err != nil {
+		return nil, err
+	}
+	err = stream.Start(wasteReport.ReportID)
+	if err != nil {
+		err = errors.Wrap(err, "Error starting result-stream")
+		return nil, err
+	}
+	for _, r := range wasteReport.ReportResult {
+		err = stream.Send(r)
+		if err != nil {
+			err = errors.Wrap(err, "Error sending result")
+			return nil, err
+		}
+	}
+	return wasteReport, nil
+}
+
+// streamResults reads the aggregate-results from the cursor in batches of
+// streamBatchSize, and sends each batch to the stream once it is priced.
+// The sent results are returned.
+func streamResults(
+	ctx context.Context,
+	cur resultCursor,
+	pricing Pricing,
+	stream ResultStream,
+) ([]ReportResult, error) {
+	sent := []ReportResult{}
+	batch := make([]interface{}, 0, streamBatchSize)
+
+	sendBatch := func() error {
+		results, err := ResultsFromAggregate(batch)
+		if err != nil {
+			return errors.Wrap(err, "Error converting aggregate-results to ReportResults")
+		}
+		err = pricing.applyPricing(results)
+		if err != nil {
+			return errors.Wrap(err, "Error pricing ReportResults")
+		}
+		for _, r := range results {
+			err = stream.Send(r)
+			if err != nil {
+				return errors.Wrap(err, "Error sending result")
+			}
+		}
+		sent = append(sent, results...)
+		batch = batch[:0]
+		return nil
+	}
+
+	for cur.Next(ctx) {
+		item := map[string]interface{}{}
+		err := cur.Decode(item)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error decoding aggregate-result")
+		}
+		batch = append(batch, item)
+		if len(batch) == streamBatchSize {
+			err = sendBatch()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	err := cur.Err()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading aggregation-cursor")
+	}
+	if len(batch) > 0 {
+		err = sendBatch()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sent, nil
+}
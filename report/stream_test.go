@@ -0,0 +1,105 @@
+package report
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/TerrexTech/uuuid"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeCursor is a resultCursor over aggregate-results in memory.
+type fakeCursor struct {
+	items []map[string]interface{}
+	next  int
+	err   error
+}
+
+func (c *fakeCursor) Next(ctx context.Context) bool {
+	if c.next >= len(c.items) {
+		return false
+	}
+	c.next++
+	return true
+}
+
+func (c *fakeCursor) Decode(v interface{}) error {
+	item := v.(map[string]interface{})
+	for k, value := range c.items[c.next-1] {
+		item[k] = value
+	}
+	return nil
+}
+
+func (c *fakeCursor) Err() error {
+	return c.err
+}
+
+// recordingStream is a ResultStream recording the sent results, along with
+// how many aggregate-results were read from the cursor when each was sent.
+type recordingStream struct {
+	cur    *fakeCursor
+	sent   []ReportResult
+	readAt []int
+}
+
+func (s *recordingStream) Start(reportID uuuid.UUID) error {
+	return nil
+}
+
+func (s *recordingStream) Send(r ReportResult) error {
+	s.sent = append(s.sent, r)
+	s.readAt = append(s.readAt, s.cur.next)
+	return nil
+}
+
+var _ = Describe("Report streaming", func() {
+	aggResults := func(count int) []map[string]interface{} {
+		items := []map[string]interface{}{}
+		for i := 0; i < count; i++ {
+			items = append(items, map[string]interface{}{
+				"_id": map[string]interface{}{
+					"sku":  fmt.Sprintf("sku%d", i),
+					"name": fmt.Sprintf("name%d", i),
+				},
+				"sum_waste":        float64(2),
+				"sum_total":        float64(10),
+				"sum_cost":         float64(3),
+				"sum_priced_waste": float64(2),
+			})
+		}
+		return items
+	}
+
+	It("sends priced results before the cursor is read to the end", func() {
+		cur := &fakeCursor{items: aggResults(streamBatchSize*2 + 5)}
+		stream := &recordingStream{cur: cur}
+
+		results, err := streamResults(context.Background(), cur, Pricing{Currency: "USD"}, stream)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(streamBatchSize*2 + 5))
+		Expect(stream.sent).To(Equal(results))
+
+		// The first batch is sent once it is read
+		Expect(stream.readAt[0]).To(Equal(streamBatchSize))
+		Expect(stream.readAt[streamBatchSize]).To(Equal(streamBatchSize * 2))
+		Expect(stream.sent[0].UnitCost).To(Equal(1.5))
+		Expect(stream.sent[0].WasteValue).To(Equal(float64(3)))
+		Expect(stream.sent[0].Currency).To(Equal("USD"))
+	})
+
+	It("returns cursor errors", func() {
+		cur := &fakeCursor{
+			items: aggResults(3),
+			err:   errors.New("cursor failed"),
+		}
+		_, err := streamResults(
+			context.Background(), cur, Pricing{Currency: "USD"}, &recordingStream{cur: cur},
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("cursor failed"))
+	})
+})
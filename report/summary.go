@@ -0,0 +1,70 @@
+package report
+
+import "sort"
+
+// TopOffendersCount is the number of ReportResults with highest waste-weight
+// included in a ReportSummary.
+const TopOffendersCount = 10
+
+// ReportSummary is the overview of ReportResults, used for report-documents
+// such as workbooks.
+type ReportSummary struct {
+	WasteWeight float64
+	TotalWeight float64
+	// WasteRatio is WasteWeight/TotalWeight, and is 0 if TotalWeight is 0.
+	WasteRatio float64
+	// Unit is the weight-unit of the results, if known.
+	Unit string
+	// WasteValue and TotalValue sum the values of priced results, in Currency.
+	// Currency is blank if no result is priced.
+	WasteValue   float64
+	TotalValue   float64
+	Currency     string
+	TopOffenders []ReportResult
+	// ByDisposal is the waste-weight per disposal-method, and is only set
+	// if the results are grouped by disposal-method.
+	ByDisposal []DisposalShare
+}
+
+// Summarize calculates the totals for ReportResults, and picks upto topCount
+// results with the highest waste-weight. Subtotal results are left out.
+// The results' weights are the sums over their waste-events, so the totals
+// are the whole weight wasted, and offenders are ranked by it.
+func Summarize(results []ReportResult, topCount int) ReportSummary {
+	summary := ReportSummary{}
+	rows := make([]ReportResult, 0, len(results))
+	for _, r := range results {
+		if !r.Subtotal {
+			rows = append(rows, r)
+		}
+	}
+	results = rows
+
+	for _, r := range results {
+		summary.WasteWeight += r.WasteWeight
+		summary.TotalWeight += r.TotalWeight
+		if r.Unit != "" {
+			summary.Unit = r.Unit
+		}
+		summary.WasteValue += r.WasteValue
+		summary.TotalValue += r.TotalValue
+		if r.Currency != "" {
+			summary.Currency = r.Currency
+		}
+	}
+	if summary.TotalWeight != 0 {
+		summary.WasteRatio = summary.WasteWeight / summary.TotalWeight
+	}
+
+	summary.ByDisposal = disposalShares(results, summary.WasteWeight)
+
+	sorted := append([]ReportResult{}, results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].WasteWeight > sorted[j].WasteWeight
+	})
+	if len(sorted) > topCount {
+		sorted = sorted[:topCount]
+	}
+	summary.TopOffenders = sorted
+	return summary
+}
@@ -0,0 +1,32 @@
+package report
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Summary", func() {
+	It("totals and ranks the summed weights of aggregate-results", func() {
+		// test-sku1 has 10 waste-events of 3kg, test-sku2 has one of 20kg
+		results, err := ResultsFromAggregate([]interface{}{
+			map[string]interface{}{
+				"_id":       map[string]interface{}{"sku": "test-sku1"},
+				"sum_waste": float64(30),
+				"sum_total": float64(300),
+			},
+			map[string]interface{}{
+				"_id":       map[string]interface{}{"sku": "test-sku2"},
+				"sum_waste": float64(20),
+				"sum_total": float64(50),
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		summary := Summarize(results, 1)
+		Expect(summary.WasteWeight).To(Equal(float64(50)))
+		Expect(summary.TotalWeight).To(Equal(float64(350)))
+		Expect(summary.WasteRatio).To(BeNumerically("~", 50.0/350, 1e-9))
+		Expect(summary.TopOffenders).To(HaveLen(1))
+		Expect(summary.TopOffenders[0].SKU).To(Equal("test-sku1"))
+	})
+})
@@ -0,0 +1,115 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Weight-units supported for waste-items and report-results.
+const (
+	UnitKilogram = "kg"
+	UnitPound    = "lb"
+	UnitGram     = "g"
+)
+
+// CanonicalUnit is the weight-unit WasteItems are normalized to, and
+// reports are aggregated and stored in.
+const CanonicalUnit = UnitKilogram
+
+// kgPerUnit is the number of kilograms in one of each weight-unit.
+var kgPerUnit = map[string]float64{
+	UnitKilogram: 1,
+	UnitPound:    0.45359237,
+	UnitGram:     0.001,
+}
+
+// ValidUnit checks if the weight-unit is supported.
+// A blank unit is valid, and means the CanonicalUnit.
+func ValidUnit(unit string) bool {
+	if unit == "" {
+		return true
+	}
+	_, ok := kgPerUnit[unit]
+	return ok
+}
+
+// knownUnits returns the weight-units unitFactor accepts, sorted,
+// including the blank unit.
+func knownUnits() []string {
+	units := []string{""}
+	for unit := range kgPerUnit {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	return units
+}
+
+// unitFactor returns the kilograms in one of unit, treating blank units as
+// the CanonicalUnit.
+func unitFactor(unit string) (float64, error) {
+	if unit == "" {
+		unit = CanonicalUnit
+	}
+	factor, ok := kgPerUnit[unit]
+	if !ok {
+		return 0, errors.Errorf("Unsupported weight-unit: %s", unit)
+	}
+	return factor, nil
+}
+
+// ConvertWeight converts the weight between the weight-units.
+func ConvertWeight(weight float64, from string, to string) (float64, error) {
+	fromFactor, err := unitFactor(from)
+	if err != nil {
+		return 0, err
+	}
+	toFactor, err := unitFactor(to)
+	if err != nil {
+		return 0, err
+	}
+	return weight * fromFactor / toFactor, nil
+}
+
+// Normalize converts the WasteItem's weights and unit-cost to the CanonicalUnit.
+// Items without a unit are already considered to be in the CanonicalUnit.
+func (s *WasteItem) Normalize() error {
+	factor, err := unitFactor(s.Unit)
+	if err != nil {
+		return err
+	}
+	s.Weight *= factor
+	s.TotalWeight *= factor
+	s.UnitCost /= factor
+	s.Unit = CanonicalUnit
+	return nil
+}
+
+// InUnit returns a copy of the WasteReport with the results' weights and
+// unit-costs converted to the weight-unit. Values are not affected.
+// Results without a unit are considered to be in the CanonicalUnit.
+func (s *WasteReport) InUnit(unit string) (*WasteReport, error) {
+	if unit == "" {
+		unit = CanonicalUnit
+	}
+	toFactor, err := unitFactor(unit)
+	if err != nil {
+		return nil, err
+	}
+
+	report := *s
+	report.ReportResult = make([]ReportResult, len(s.ReportResult))
+	for i, r := range s.ReportResult {
+		fromFactor, err := unitFactor(r.Unit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting result at index %d", i)
+		}
+		ratio := fromFactor / toFactor
+		r.WasteWeight *= ratio
+		r.TotalWeight *= ratio
+		r.UnitCost /= ratio
+		r.Unit = unit
+		report.ReportResult[i] = r
+	}
+	return &report, nil
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Weight-units", func() {
+	It("converts weights between units", func() {
+		w, err := ConvertWeight(2, UnitKilogram, UnitGram)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w).To(Equal(float64(2000)))
+
+		w, err = ConvertWeight(1, UnitPound, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w).To(BeNumerically("~", 0.45359237, 1e-9))
+
+		_, err = ConvertWeight(1, "oz", UnitKilogram)
+		Expect(err).To(HaveOccurred())
+		Expect(ValidUnit("oz")).To(BeFalse())
+		Expect(ValidUnit("")).To(BeTrue())
+	})
+
+	It("normalizes WasteItems to the canonical unit", func() {
+		item := WasteItem{
+			Weight:      500,
+			TotalWeight: 2000,
+			Unit:        UnitGram,
+			UnitCost:    0.004,
+		}
+		Expect(item.Normalize()).To(Succeed())
+		Expect(item.Unit).To(Equal(CanonicalUnit))
+		Expect(item.Weight).To(BeNumerically("~", 0.5, 1e-9))
+		Expect(item.TotalWeight).To(BeNumerically("~", 2, 1e-9))
+		Expect(item.UnitCost).To(BeNumerically("~", 4, 1e-9))
+
+		item = WasteItem{Weight: 3}
+		Expect(item.Normalize()).To(Succeed())
+		Expect(item.Weight).To(Equal(float64(3)))
+
+		item = WasteItem{Weight: 3, Unit: "stone"}
+		Expect(item.Normalize()).ToNot(Succeed())
+	})
+
+	It("converts reports to the requested unit", func() {
+		wasteReport := &WasteReport{
+			ReportResult: []ReportResult{
+				ReportResult{
+					SKU:         "test-sku1",
+					WasteWeight: 1,
+					TotalWeight: 10,
+					Unit:        UnitKilogram,
+					UnitCost:    2,
+					WasteValue:  2,
+				},
+				// Reports stored before units were added have no unit
+				ReportResult{
+					SKU:         "test-sku2",
+					WasteWeight: 2,
+					TotalWeight: 4,
+				},
+			},
+		}
+
+		inGrams, err := wasteReport.InUnit(UnitGram)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(inGrams.ReportResult[0].WasteWeight).To(BeNumerically("~", 1000, 1e-9))
+		Expect(inGrams.ReportResult[0].UnitCost).To(BeNumerically("~", 0.002, 1e-12))
+		Expect(inGrams.ReportResult[0].WasteValue).To(Equal(float64(2)))
+		Expect(inGrams.ReportResult[1].TotalWeight).To(BeNumerically("~", 4000, 1e-9))
+		for _, r := range inGrams.ReportResult {
+			Expect(r.Unit).To(Equal(UnitGram))
+		}
+		Expect(wasteReport.ReportResult[0].WasteWeight).To(Equal(float64(1)))
+
+		inKg, err := wasteReport.InUnit("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(inKg.ReportResult[1].Unit).To(Equal(UnitKilogram))
+
+		_, err = wasteReport.InUnit("oz")
+		Expect(err).To(HaveOccurred())
+	})
+})
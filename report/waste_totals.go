@@ -0,0 +1,123 @@
+package report
+
+import (
+	"log"
+	"sort"
+
+	util "github.com/TerrexTech/go-commonutils/commonutil"
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// WasteTotal is the summed waste for a single group, such as a SKU or lot.
+type WasteTotal struct {
+	Key         string
+	WasteWeight float64
+	TotalWeight float64
+}
+
+// WasteRatio returns WasteWeight/TotalWeight, or 0 if TotalWeight is 0.
+func (t WasteTotal) WasteRatio() float64 {
+	if t.TotalWeight == 0 {
+		return 0
+	}
+	return t.WasteWeight / t.TotalWeight
+}
+
+// WasteTotals sums the waste of WasteItems newer than the "since" unix-timestamp,
+// grouped by groupField (such as "sku" or "lot"). The weights are summed in
+// the CanonicalUnit, and an error is returned if any WasteItem has an
+// unsupported weight-unit. The totals are sorted by highest waste-weight first.
+func WasteTotals(
+	itemWasteColl *mongo.Collection,
+	groupField string,
+	since int64,
+) ([]WasteTotal, error) {
+	pipeline := []*bson.Document{
+		bson.NewDocument(
+			bson.EC.SubDocument("$match", bson.NewDocument(
+				bson.EC.SubDocument("timestamp", bson.NewDocument(
+					bson.EC.Int64("$gt", since),
+				)),
+			)),
+		),
+		bson.NewDocument(
+			bson.EC.SubDocument("$group", bson.NewDocument(
+				bson.EC.String("_id", "$"+groupField),
+				bson.EC.SubDocumentFromElements("wasteWeight", canonicalSum("weight")),
+				bson.EC.SubDocumentFromElements("totalWeight", canonicalSum("totalWeight")),
+				bson.EC.SubDocument(unknownUnitsField, unknownUnitsSum()),
+			)),
+		),
+	}
+
+	aggResults, err := itemWasteColl.Aggregate(pipeline)
+	if err != nil {
+		err = errors.Wrapf(err, "WasteTotals: Error aggregating totals by %s", groupField)
+		log.Println(err)
+		return nil, err
+	}
+
+	totals := make([]WasteTotal, 0, len(aggResults))
+	for _, v := range aggResults {
+		m, assertOK := v.(map[string]interface{})
+		if !assertOK {
+			return nil, errors.New("WasteTotals: Error asserting aggregate-result to map")
+		}
+		key, _ := m["_id"].(string)
+		err := checkUnknownUnits(m)
+		if err != nil {
+			err = errors.Wrapf(err, "WasteTotals: Error in %s-total of %s", groupField, key)
+			return nil, err
+		}
+		wasteWeight, err := util.AssertFloat64(m["wasteWeight"])
+		if err != nil {
+			err = errors.Wrap(err, "WasteTotals: Error asserting wasteWeight")
+			return nil, err
+		}
+		totalWeight, err := util.AssertFloat64(m["totalWeight"])
+		if err != nil {
+			err = errors.Wrap(err, "WasteTotals: Error asserting totalWeight")
+			return nil, err
+		}
+
+		totals = append(totals, WasteTotal{
+			Key:         key,
+			WasteWeight: wasteWeight,
+			TotalWeight: totalWeight,
+		})
+	}
+
+	sort.SliceStable(totals, func(i, j int) bool {
+		return totals[i].WasteWeight > totals[j].WasteWeight
+	})
+	return totals, nil
+}
+
+// canonicalSum returns the $sum of the weight-field, converted from each
+// WasteItem's weight-unit to the CanonicalUnit.
+func canonicalSum(field string) *bson.Element {
+	return bson.EC.SubDocumentFromElements("$sum", bson.EC.ArrayFromElements(
+		"$multiply", bson.VC.String("$"+field), bson.VC.Document(unitFactorExpr()),
+	))
+}
+
+// LimitWasteTotals keeps the first limit totals, and sums the rest into
+// a single WasteTotal with the provided otherKey. This is used to cap the
+// number of distinct groups, such as for metric-labels.
+func LimitWasteTotals(totals []WasteTotal, limit int, otherKey string) []WasteTotal {
+	if len(totals) <= limit {
+		return totals
+	}
+
+	other := WasteTotal{
+		Key: otherKey,
+	}
+	for _, t := range totals[limit:] {
+		other.WasteWeight += t.WasteWeight
+		other.TotalWeight += t.TotalWeight
+	}
+	limited := append([]WasteTotal{}, totals[:limit]...)
+	return append(limited, other)
+}
@@ -0,0 +1,33 @@
+package report
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WasteTotals", func() {
+	totals := []WasteTotal{
+		WasteTotal{Key: "a", WasteWeight: 30, TotalWeight: 100},
+		WasteTotal{Key: "b", WasteWeight: 20, TotalWeight: 100},
+		WasteTotal{Key: "c", WasteWeight: 10, TotalWeight: 50},
+		WasteTotal{Key: "d", WasteWeight: 5, TotalWeight: 50},
+	}
+
+	It("returns totals unchanged within limit", func() {
+		Expect(LimitWasteTotals(totals, 4, "other")).To(Equal(totals))
+	})
+
+	It("sums totals beyond limit into otherKey", func() {
+		limited := LimitWasteTotals(totals, 2, "other")
+		Expect(limited).To(Equal([]WasteTotal{
+			totals[0],
+			totals[1],
+			WasteTotal{Key: "other", WasteWeight: 15, TotalWeight: 100},
+		}))
+		Expect(limited[2].WasteRatio()).To(Equal(0.15))
+	})
+
+	It("returns zero ratio for zero total-weight", func() {
+		Expect(WasteTotal{WasteWeight: 5}.WasteRatio()).To(BeZero())
+	})
+})
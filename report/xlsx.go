@@ -0,0 +1,153 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx"
+)
+
+// weightNumFmt is the number-format for weight-cells in workbooks.
+const weightNumFmt = "#,##0.00"
+
+// ratioNumFmt is the number-format for ratio-cells in workbooks.
+const ratioNumFmt = "0.00%"
+
+// moneyNumFmt is the number-format for monetary cells in workbooks,
+// which is followed by the currency using currencyNumFmt.
+const moneyNumFmt = "#,##0.00"
+
+// currencyNumFmt returns the number-format for monetary cells in the currency,
+// showing the currency-code after the value.
+func currencyNumFmt(currency string) string {
+	if currency == "" {
+		return moneyNumFmt
+	}
+	return moneyNumFmt + ` "` + currency + `"`
+}
+
+// WriteXLSX writes the WasteReport as an Excel-workbook to the provided writer.
+// The workbook has a "Summary" sheet with the report-window, filters, totals
+// and top offenders, and a "Detail" sheet with a row for each ReportResult.
+func WriteXLSX(w io.Writer, wasteReport *WasteReport) error {
+	file := xlsx.NewFile()
+
+	summarySheet, err := file.AddSheet("Summary")
+	if err != nil {
+		err = errors.Wrap(err, "WriteXLSX: Error adding Summary sheet")
+		return err
+	}
+	err = writeSummarySheet(summarySheet, wasteReport)
+	if err != nil {
+		err = errors.Wrap(err, "WriteXLSX: Error writing Summary sheet")
+		return err
+	}
+
+	detailSheet, err := file.AddSheet("Detail")
+	if err != nil {
+		err = errors.Wrap(err, "WriteXLSX: Error adding Detail sheet")
+		return err
+	}
+	writeResultRows(
+		detailSheet, wasteReport.resultColumns(), wasteReport.ReportResult,
+	)
+
+	err = file.Write(w)
+	if err != nil {
+		err = errors.Wrap(err, "WriteXLSX: Error writing workbook")
+		return err
+	}
+	return nil
+}
+
+func writeSummarySheet(sheet *xlsx.Sheet, wasteReport *WasteReport) error {
+	addLabelRow(sheet, "Report ID").AddCell().SetString(wasteReport.ReportID.String())
+
+	ts := wasteReport.SearchQuery.Timestamp
+	if ts != nil {
+		addLabelRow(sheet, "Window From").AddCell().SetDateTime(
+			time.Unix(int64(ts.Gt), 0).UTC(),
+		)
+		addLabelRow(sheet, "Window To").AddCell().SetDateTime(
+			time.Unix(int64(ts.Lt), 0).UTC(),
+		)
+	}
+
+	filters, err := json.Marshal(wasteReport.SearchQuery)
+	if err != nil {
+		err = errors.Wrap(err, "Error marshalling SearchQuery")
+		return err
+	}
+	addLabelRow(sheet, "Filters").AddCell().SetString(string(filters))
+	sheet.AddRow()
+
+	summary := Summarize(wasteReport.ReportResult, TopOffendersCount)
+	addLabelRow(sheet, weightLabel("Total Waste Weight", summary.Unit)).AddCell().SetFloatWithFormat(
+		summary.WasteWeight, weightNumFmt,
+	)
+	addLabelRow(sheet, weightLabel("Total Weight", summary.Unit)).AddCell().SetFloatWithFormat(
+		summary.TotalWeight, weightNumFmt,
+	)
+	addLabelRow(sheet, "Waste Ratio").AddCell().SetFloatWithFormat(
+		summary.WasteRatio, ratioNumFmt,
+	)
+	if summary.Currency != "" {
+		row := addLabelRow(sheet, "Total Waste Value")
+		row.AddCell().SetFloatWithFormat(summary.WasteValue, currencyNumFmt(summary.Currency))
+		row.AddCell().SetString(summary.Currency)
+		row = addLabelRow(sheet, "Total Value")
+		row.AddCell().SetFloatWithFormat(summary.TotalValue, currencyNumFmt(summary.Currency))
+		row.AddCell().SetString(summary.Currency)
+	}
+	sheet.AddRow()
+
+	if len(summary.ByDisposal) > 0 {
+		addLabelRow(sheet, "Waste by Disposal")
+		for _, d := range summary.ByDisposal {
+			row := addLabelRow(sheet, d.Disposal)
+			row.AddCell().SetFloatWithFormat(d.WasteWeight, weightNumFmt)
+			row.AddCell().SetFloatWithFormat(d.Share, ratioNumFmt)
+		}
+		sheet.AddRow()
+	}
+
+	addLabelRow(sheet, "Top Offenders")
+	writeResultRows(
+		sheet, wasteReport.resultColumns(), summary.TopOffenders,
+	)
+	return sheet.SetColWidth(0, 0, 20)
+}
+
+// addLabelRow adds a new row to sheet with its first cell set to label.
+func addLabelRow(sheet *xlsx.Sheet, label string) *xlsx.Row {
+	row := sheet.AddRow()
+	row.AddCell().SetString(label)
+	return row
+}
+
+// writeResultRows adds a header-row followed by a row for each ReportResult,
+// using numeric cells for metric-columns. Monetary cells are formatted in
+// the result's currency.
+func writeResultRows(sheet *xlsx.Sheet, columns []resultColumn, results []ReportResult) {
+
+	headerRow := sheet.AddRow()
+	for _, c := range columns {
+		headerRow.AddCell().SetString(c.Header)
+	}
+
+	for _, r := range results {
+		row := sheet.AddRow()
+		for _, c := range columns {
+			cell := row.AddCell()
+			if c.Money {
+				cell.SetFloatWithFormat(c.Metric(r), currencyNumFmt(r.Currency))
+			} else if c.Metric != nil {
+				cell.SetFloatWithFormat(c.Metric(r), weightNumFmt)
+			} else {
+				cell.SetString(c.Text(r))
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/TerrexTech/uuuid"
+	"github.com/tealeg/xlsx"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("XLSX export", func() {
+	It("writes summary and detail sheets", func() {
+		reportID, err := uuuid.NewV4()
+		Expect(err).ToNot(HaveOccurred())
+
+		wasteReport := &WasteReport{
+			ReportID: reportID,
+			SearchQuery: WasteItemParams{
+				Timestamp: &Comparator{
+					Gt: 1529315000,
+					Lt: 1551997372,
+				},
+			},
+			ReportResult: []ReportResult{
+				ReportResult{
+					SKU:         "test-sku1",
+					Name:        "test-name1",
+					WasteWeight: 10,
+					TotalWeight: 100,
+				},
+				ReportResult{
+					SKU:         "test-sku2",
+					Name:        "test-name2",
+					WasteWeight: 30,
+					TotalWeight: 100,
+				},
+			},
+		}
+
+		buf := &bytes.Buffer{}
+		err = WriteXLSX(buf, wasteReport)
+		Expect(err).ToNot(HaveOccurred())
+
+		file, err := xlsx.OpenBinary(buf.Bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Sheets).To(HaveLen(2))
+
+		detail := file.Sheet["Detail"]
+		Expect(detail).ToNot(BeNil())
+		// Header-row and a row per result
+		Expect(detail.Rows).To(HaveLen(3))
+		Expect(detail.Cell(1, 0).String()).To(Equal("test-sku1"))
+		waste, err := detail.Cell(2, 2).Float()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(waste).To(Equal(float64(30)))
+
+		summary := file.Sheet["Summary"]
+		Expect(summary).ToNot(BeNil())
+		Expect(summary.Cell(0, 1).String()).To(Equal(reportID.String()))
+	})
+
+	It("formats monetary cells in the currency", func() {
+		wasteReport := &WasteReport{
+			ReportResult: []ReportResult{
+				ReportResult{
+					SKU:         "test-sku1",
+					Name:        "test-name1",
+					WasteWeight: 10,
+					TotalWeight: 100,
+					UnitCost:    2.5,
+					WasteValue:  25,
+					TotalValue:  250,
+					Currency:    "EUR",
+				},
+			},
+		}
+
+		buf := &bytes.Buffer{}
+		err := WriteXLSX(buf, wasteReport)
+		Expect(err).ToNot(HaveOccurred())
+		file, err := xlsx.OpenBinary(buf.Bytes())
+		Expect(err).ToNot(HaveOccurred())
+
+		// Number-formats are lowercased when read
+		moneyFmt := strings.ToLower(`#,##0.00 "EUR"`)
+		detail := file.Sheet["Detail"]
+		headers := []string{}
+		for _, cell := range detail.Rows[0].Cells {
+			headers = append(headers, cell.String())
+		}
+		for i, header := range headers {
+			switch header {
+			case "wasteWeight", "totalWeight":
+				Expect(detail.Cell(1, i).GetNumberFormat()).To(Equal(weightNumFmt))
+			case "unitCost", "wasteValue", "totalValue":
+				Expect(detail.Cell(1, i).GetNumberFormat()).To(Equal(moneyFmt))
+			}
+		}
+
+		summary := file.Sheet["Summary"]
+		found := false
+		for _, row := range summary.Rows {
+			if len(row.Cells) > 1 && row.Cells[0].String() == "Total Waste Value" {
+				found = true
+				Expect(row.Cells[1].GetNumberFormat()).To(Equal(moneyFmt))
+			}
+		}
+		Expect(found).To(BeTrue())
+	})
+})
@@ -0,0 +1,185 @@
+// Package schedule contains the report-schedules, which run a WasteReport
+// on a cron-expression and publish it, and the cron-expressions themselves.
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cronMacros are the shorthands allowed in place of the five cron-fields.
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// cronField is the range of values allowed in a cron-field.
+type cronField struct {
+	name     string
+	min, max uint
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	// 7 is also Sunday, and is folded into 0
+	{"day-of-week", 0, 7},
+}
+
+// maxCronYears limits how far Next searches, so expressions that can
+// never match (such as "0 0 30 2 *") don't loop forever.
+const maxCronYears = 5
+
+// Cron is a parsed cron-expression, having five space-separated fields:
+// "minute hour day-of-month month day-of-week". Each field is "*", a number,
+// a range "a-b", a list "a,b", or a step "*/n" or "a-b/n". Days of the week
+// are 0 (Sunday) to 6, or 7 for Sunday. As in the standard cron, a day
+// matches if either the day-of-month or the day-of-week matches, when both
+// are restricted. The macros "@hourly", "@daily", "@weekly" and "@monthly"
+// are also accepted. Times skipped by daylight-saving never match.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar mark unrestricted day-fields
+	domStar, dowStar bool
+}
+
+// ParseCron parses the cron-expression.
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, errors.Errorf(
+			"Cron-expression must have %d fields, got: %q", len(cronFields), expr,
+		)
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = b
+	}
+	// Sunday can be 0 or 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	return &Cron{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField returns the bitset of the values matched by the field.
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, uint(1)
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || n == 0 {
+				return 0, errors.Errorf("Invalid step in %s-field: %q", f.name, part)
+			}
+			rangePart, step = part[:i], uint(n)
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			n, err := strconv.ParseUint(bounds[0], 10, 8)
+			if err != nil {
+				return 0, errors.Errorf("Invalid value in %s-field: %q", f.name, part)
+			}
+			low, high = uint(n), uint(n)
+			if len(bounds) == 2 {
+				n, err = strconv.ParseUint(bounds[1], 10, 8)
+				if err != nil {
+					return 0, errors.Errorf("Invalid range in %s-field: %q", f.name, part)
+				}
+				high = uint(n)
+			} else if step > 1 {
+				// "a/n" is from a to the max
+				high = f.max
+			}
+		}
+		if low < f.min || high > f.max || low > high {
+			return 0, errors.Errorf(
+				"Values in %s-field must be from %d to %d, got: %q", f.name, f.min, f.max, part,
+			)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time matching the Cron strictly after t, in t's
+// location. The zero time is returned if nothing matches within
+// maxCronYears, such as for "0 0 30 2 *".
+func (c *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + maxCronYears
+
+	for t.Year() <= limit {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !c.matchDay(t) {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = nextHour(t)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// nextHour returns the start of the hour after t. The minutes are added
+// to t, since time.Date moves times skipped by daylight-saving backwards.
+func nextHour(t time.Time) time.Time {
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+// advance returns next if it is after t, and otherwise the next hour,
+// such as when next is a midnight skipped by daylight-saving.
+func advance(t time.Time, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return nextHour(t)
+}
+
+func (c *Cron) matchDay(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
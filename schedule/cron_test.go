@@ -0,0 +1,63 @@
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cron", func() {
+	// Monday
+	start := time.Date(2019, 3, 4, 10, 30, 0, 0, time.UTC)
+
+	next := func(expr string, t time.Time) time.Time {
+		c, err := ParseCron(expr)
+		Expect(err).ToNot(HaveOccurred())
+		return c.Next(t)
+	}
+
+	It("finds the next matching time after the time", func() {
+		Expect(next("0 6 * * *", start)).To(Equal(time.Date(2019, 3, 5, 6, 0, 0, 0, time.UTC)))
+		Expect(next("30 10 * * *", start)).To(Equal(time.Date(2019, 3, 5, 10, 30, 0, 0, time.UTC)))
+		Expect(next("*/15 * * * *", start)).To(Equal(time.Date(2019, 3, 4, 10, 45, 0, 0, time.UTC)))
+		Expect(next("0 9-17/4 * * 1-5", start)).To(Equal(time.Date(2019, 3, 4, 13, 0, 0, 0, time.UTC)))
+		Expect(next("@monthly", start)).To(Equal(time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)))
+		// Sunday is 0 or 7
+		Expect(next("0 0 * * 7", start)).To(Equal(time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("matches either restricted day-field", func() {
+		// The 15th is a Friday, and the 8th is the first Friday
+		Expect(next("0 0 15 * 5", start)).To(Equal(time.Date(2019, 3, 8, 0, 0, 0, 0, time.UTC)))
+		Expect(next("0 0 15 * *", start)).To(Equal(time.Date(2019, 3, 15, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("finds the time in the location", func() {
+		toronto, err := time.LoadLocation("America/Toronto")
+		Expect(err).ToNot(HaveOccurred())
+
+		// Clocks moved forward on 2019-03-10
+		t := next("0 6 * * *", time.Date(2019, 3, 9, 12, 0, 0, 0, toronto))
+		Expect(t.UTC()).To(Equal(time.Date(2019, 3, 10, 10, 0, 0, 0, time.UTC)))
+		t = next("0 6 * * *", time.Date(2019, 3, 8, 12, 0, 0, 0, toronto))
+		Expect(t.UTC()).To(Equal(time.Date(2019, 3, 9, 11, 0, 0, 0, time.UTC)))
+		// Times skipped by daylight-saving don't run
+		t = next("30 2 * * *", time.Date(2019, 3, 9, 12, 0, 0, 0, toronto))
+		Expect(t).To(Equal(time.Date(2019, 3, 11, 2, 30, 0, 0, toronto)))
+	})
+
+	It("returns the zero time if nothing matches", func() {
+		Expect(next("0 0 30 2 *", start).IsZero()).To(BeTrue())
+	})
+
+	It("returns error on invalid expressions", func() {
+		for _, expr := range []string{
+			"", "0 6 * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+			"* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *",
+		} {
+			_, err := ParseCron(expr)
+			Expect(err).To(HaveOccurred(), expr)
+		}
+	})
+})
@@ -0,0 +1,175 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/pkg/errors"
+)
+
+// Windows are the periods before each run that a scheduled report covers,
+// in the Schedule's timezone.
+const (
+	// WindowPreviousDay is the day before the run.
+	WindowPreviousDay = "previousDay"
+	// WindowPreviousWeek is the seven days before the run's day.
+	WindowPreviousWeek = "previousWeek"
+	// WindowPreviousMonth is the calendar-month before the run's month.
+	WindowPreviousMonth = "previousMonth"
+)
+
+// Schedule is a report-definition, which is run on its Cron-expression
+// and published to the distribution-topic.
+type Schedule struct {
+	ScheduleID string `json:"scheduleID"`
+	Name       string `json:"name"`
+	// Cron is the cron-expression of the runs, in Timezone.
+	Cron string `json:"cron"`
+	// Timezone is the IANA-name of the timezone of Cron and Window, such as
+	// "America/Toronto". Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Window is the period before each run the report covers.
+	Window string `json:"window"`
+	// Params are the report's filters and grouping. The Timestamp is
+	// replaced by the Window on each run.
+	Params report.WasteItemParams `json:"params"`
+	Format string                 `json:"format,omitempty"`
+	Unit   string                 `json:"unit,omitempty"`
+	// ShowCosts keeps the cost-fields in the published reports.
+	ShowCosts bool `json:"showCosts,omitempty"`
+	Disabled  bool `json:"disabled,omitempty"`
+	// CreatedBy is the subject of the claims that saved the Schedule.
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	// NextRunAt, LastRunAt and the rest are set by the service, as
+	// unix-timestamps.
+	NextRunAt    int64  `json:"nextRunAt,omitempty"`
+	LastRunAt    int64  `json:"lastRunAt,omitempty"`
+	LastReportID string `json:"lastReportID,omitempty"`
+	// LastError is the error of the last run, if it failed.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Validate checks the Schedule's fields, and that the Params can be run.
+func (s *Schedule) Validate() error {
+	if s.Name == "" {
+		return errors.New("Schedule-name is required")
+	}
+	_, err := ParseCron(s.Cron)
+	if err != nil {
+		return err
+	}
+	_, err = s.location()
+	if err != nil {
+		return err
+	}
+	switch s.Window {
+	case WindowPreviousDay, WindowPreviousWeek, WindowPreviousMonth:
+	default:
+		return errors.Errorf("Unknown window: %s", s.Window)
+	}
+	if !report.ValidFormat(s.Format) || !report.ValidUnit(s.Unit) {
+		return errors.Errorf("Unsupported output-format or weight-unit: %s, %s", s.Format, s.Unit)
+	}
+	if s.Params.Timestamp != nil {
+		return errors.New("Params must not have a timestamp, since it is set by the window")
+	}
+	return s.Params.Validate()
+}
+
+func (s *Schedule) location() (*time.Location, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unknown timezone: %s", s.Timezone)
+	}
+	return loc, nil
+}
+
+// NextRun returns the first run of the Schedule after the time.
+// The Schedule must be valid.
+func (s *Schedule) NextRun(after time.Time) time.Time {
+	cron, _ := ParseCron(s.Cron)
+	loc, _ := s.location()
+	return cron.Next(after.In(loc))
+}
+
+// ReportWindow returns the timestamp-filter of the report for the run at
+// runAt. The Schedule must be valid.
+func (s *Schedule) ReportWindow(runAt time.Time) *report.Comparator {
+	loc, _ := s.location()
+	runAt = runAt.In(loc)
+	end := time.Date(runAt.Year(), runAt.Month(), runAt.Day(), 0, 0, 0, 0, loc)
+
+	var start time.Time
+	switch s.Window {
+	case WindowPreviousWeek:
+		start = end.AddDate(0, 0, -7)
+	case WindowPreviousMonth:
+		end = end.AddDate(0, 0, 1-end.Day())
+		start = end.AddDate(0, -1, 0)
+	default:
+		start = end.AddDate(0, 0, -1)
+	}
+	return &report.Comparator{
+		// Timestamps are integers, so this includes the first second
+		Gt: float64(start.Unix() - 1),
+		Lt: float64(end.Unix()),
+	}
+}
+
+// Stores returns the stores the Schedule's reports are limited to,
+// or nil for all stores.
+func (s *Schedule) Stores() []string {
+	return s.Params.Stores()
+}
+
+// Run is published to the distribution-topic for each run of a Schedule.
+type Run struct {
+	ScheduleID string `json:"scheduleID"`
+	Name       string `json:"name"`
+	// RunAt is the unix-timestamp the run was scheduled at, and From and To
+	// are the bounds of the report's timestamps.
+	RunAt    int64  `json:"runAt"`
+	From     int64  `json:"from"`
+	To       int64  `json:"to"`
+	ReportID string `json:"reportID"`
+	Format   string `json:"format"`
+	// Document is the report in the Format.
+	Document []byte `json:"document"`
+}
+
+// scheduleBSON is the stored Schedule. Its Params are stored the same
+// as the SearchQuery of WasteReports.
+type scheduleBSON struct {
+	ScheduleID   string                 `bson:"scheduleID"`
+	Name         string                 `bson:"name"`
+	Cron         string                 `bson:"cron"`
+	Timezone     string                 `bson:"timezone"`
+	Window       string                 `bson:"window"`
+	Params       report.WasteItemParams `bson:"params"`
+	Format       string                 `bson:"format"`
+	Unit         string                 `bson:"unit"`
+	ShowCosts    bool                   `bson:"showCosts"`
+	Disabled     bool                   `bson:"disabled"`
+	CreatedBy    string                 `bson:"createdBy"`
+	NextRunAt    int64                  `bson:"nextRunAt"`
+	LastRunAt    int64                  `bson:"lastRunAt"`
+	LastReportID string                 `bson:"lastReportID"`
+	LastError    string                 `bson:"lastError"`
+}
+
+func (s Schedule) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(scheduleBSON(s))
+}
+
+func (s *Schedule) UnmarshalBSON(in []byte) error {
+	sb := &scheduleBSON{}
+	err := bson.Unmarshal(in, sb)
+	if err != nil {
+		err = errors.Wrap(err, "UnmarshalBSON Error")
+		return err
+	}
+	*s = Schedule(*sb)
+	return nil
+}
@@ -0,0 +1,88 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/TerrexTech/agg-itemwaste-report/report"
+	"github.com/mongodb/mongo-go-driver/bson"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schedule", func() {
+	var s Schedule
+
+	BeforeEach(func() {
+		s = Schedule{
+			ScheduleID: "schedule-1",
+			Name:       "daily",
+			Cron:       "0 6 * * *",
+			Timezone:   "America/Toronto",
+			Window:     WindowPreviousDay,
+			Params: report.WasteItemParams{
+				StoreID: &report.InComparator{
+					In: []string{"store-1"},
+				},
+				GroupByStore: true,
+			},
+			Format: report.FormatCSV,
+		}
+	})
+
+	It("validates the schedule", func() {
+		Expect(s.Validate()).To(Succeed())
+
+		invalid := s
+		invalid.Cron = "0 6 * *"
+		Expect(invalid.Validate()).ToNot(Succeed())
+		invalid = s
+		invalid.Timezone = "Mars/Olympus"
+		Expect(invalid.Validate()).ToNot(Succeed())
+		invalid = s
+		invalid.Window = "yesterday"
+		Expect(invalid.Validate()).ToNot(Succeed())
+		invalid = s
+		invalid.Params.Timestamp = &report.Comparator{Gt: 1, Lt: 2}
+		Expect(invalid.Validate()).ToNot(Succeed())
+	})
+
+	It("runs in the schedule's timezone", func() {
+		toronto, _ := time.LoadLocation("America/Toronto")
+		next := s.NextRun(time.Date(2019, 3, 4, 12, 0, 0, 0, time.UTC))
+		Expect(next).To(Equal(time.Date(2019, 3, 5, 6, 0, 0, 0, toronto)))
+	})
+
+	It("reports on the window before the run", func() {
+		toronto, _ := time.LoadLocation("America/Toronto")
+		runAt := time.Date(2019, 3, 5, 6, 0, 0, 0, toronto)
+		dayStart := time.Date(2019, 3, 4, 0, 0, 0, 0, toronto).Unix()
+		dayEnd := time.Date(2019, 3, 5, 0, 0, 0, 0, toronto).Unix()
+
+		Expect(s.ReportWindow(runAt)).To(Equal(&report.Comparator{
+			Gt: float64(dayStart - 1),
+			Lt: float64(dayEnd),
+		}))
+
+		s.Window = WindowPreviousWeek
+		Expect(s.ReportWindow(runAt).Gt).To(
+			Equal(float64(time.Date(2019, 2, 26, 0, 0, 0, 0, toronto).Unix() - 1)),
+		)
+
+		s.Window = WindowPreviousMonth
+		Expect(s.ReportWindow(runAt)).To(Equal(&report.Comparator{
+			Gt: float64(time.Date(2019, 2, 1, 0, 0, 0, 0, toronto).Unix() - 1),
+			Lt: float64(time.Date(2019, 3, 1, 0, 0, 0, 0, toronto).Unix()),
+		}))
+	})
+
+	It("round-trips through BSON", func() {
+		s.NextRunAt = 1551783600
+		s.LastError = "no results"
+
+		data, err := bson.Marshal(s)
+		Expect(err).ToNot(HaveOccurred())
+		decoded := &Schedule{}
+		Expect(bson.Unmarshal(data, decoded)).To(Succeed())
+		Expect(*decoded).To(Equal(s))
+	})
+})
@@ -0,0 +1,182 @@
+package schedule
+
+import (
+	"log"
+	"time"
+
+	"github.com/TerrexTech/go-mongoutils/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/findopt"
+	"github.com/pkg/errors"
+)
+
+// Find finds the stored Schedule with the provided scheduleID.
+func Find(schedColl *mongo.Collection, scheduleID string) (*Schedule, error) {
+	findResult, err := schedColl.FindOne(map[string]interface{}{
+		"scheduleID": scheduleID,
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Error finding schedule with ID: %s", scheduleID)
+		log.Println(err)
+		return nil, err
+	}
+
+	s, assertOK := findResult.(*Schedule)
+	if !assertOK {
+		err = errors.New("Error asserting find-result to Schedule")
+		log.Println(err)
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the stored Schedules matching the filter, by name.
+func List(schedColl *mongo.Collection, filter map[string]interface{}) ([]*Schedule, error) {
+	findResults, err := schedColl.Find(
+		filter,
+		findopt.Sort(map[string]interface{}{
+			"name": 1,
+		}),
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error listing schedules")
+		log.Println(err)
+		return nil, err
+	}
+
+	schedules := make([]*Schedule, 0, len(findResults))
+	for _, v := range findResults {
+		s, assertOK := v.(*Schedule)
+		if !assertOK {
+			err = errors.New("Error asserting find-result to Schedule")
+			log.Println(err)
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, nil
+}
+
+// Due returns the enabled Schedules whose next run is at or before now.
+func Due(schedColl *mongo.Collection, now time.Time) ([]*Schedule, error) {
+	return List(schedColl, map[string]interface{}{
+		"nextRunAt": map[string]interface{}{
+			"$gt":  0,
+			"$lte": now.Unix(),
+		},
+		"disabled": map[string]interface{}{
+			"$ne": true,
+		},
+	})
+}
+
+// Save stores the Schedule, replacing the definition of the stored Schedule
+// with the same ScheduleID if isNew is false. The run-history of a replaced
+// Schedule is kept.
+func Save(schedColl *mongo.Collection, s *Schedule, isNew bool) error {
+	if isNew {
+		_, err := schedColl.InsertOne(s)
+		if err != nil {
+			err = errors.Wrap(err, "Error inserting schedule")
+			log.Println(err)
+		}
+		return err
+	}
+
+	result, err := schedColl.UpdateMany(
+		map[string]interface{}{
+			"scheduleID": s.ScheduleID,
+		},
+		map[string]interface{}{
+			"name":      s.Name,
+			"cron":      s.Cron,
+			"timezone":  s.Timezone,
+			"window":    s.Window,
+			"params":    s.Params,
+			"format":    s.Format,
+			"unit":      s.Unit,
+			"showCosts": s.ShowCosts,
+			"disabled":  s.Disabled,
+			"nextRunAt": s.NextRunAt,
+		},
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error updating schedule")
+		log.Println(err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.Errorf("No schedule with ID: %s", s.ScheduleID)
+	}
+	return nil
+}
+
+// Claim moves the Schedule's next run to next, if it is still at the run
+// the Schedule was found with. This makes sure only one replica runs each
+// due Schedule, and returns false if another replica already claimed it.
+func Claim(schedColl *mongo.Collection, s *Schedule, next time.Time) (bool, error) {
+	var nextRunAt int64
+	if !next.IsZero() {
+		nextRunAt = next.Unix()
+	}
+	result, err := schedColl.UpdateMany(
+		map[string]interface{}{
+			"scheduleID": s.ScheduleID,
+			"nextRunAt":  s.NextRunAt,
+		},
+		map[string]interface{}{
+			"nextRunAt": nextRunAt,
+		},
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error claiming schedule")
+		log.Println(err)
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// RecordRun stores the outcome of the Schedule's run at runAt.
+// The runErr is nil if the report was published.
+func RecordRun(
+	schedColl *mongo.Collection,
+	scheduleID string,
+	runAt time.Time,
+	reportID string,
+	runErr error,
+) error {
+	lastError := ""
+	if runErr != nil {
+		lastError = runErr.Error()
+	}
+	_, err := schedColl.UpdateMany(
+		map[string]interface{}{
+			"scheduleID": scheduleID,
+		},
+		map[string]interface{}{
+			"lastRunAt":    runAt.Unix(),
+			"lastReportID": reportID,
+			"lastError":    lastError,
+		},
+	)
+	if err != nil {
+		err = errors.Wrap(err, "Error recording schedule-run")
+		log.Println(err)
+	}
+	return err
+}
+
+// Delete deletes the Schedule with the provided scheduleID.
+func Delete(schedColl *mongo.Collection, scheduleID string) error {
+	result, err := schedColl.DeleteMany(map[string]interface{}{
+		"scheduleID": scheduleID,
+	})
+	if err != nil {
+		err = errors.Wrap(err, "Error deleting schedule")
+		log.Println(err)
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.Errorf("No schedule with ID: %s", scheduleID)
+	}
+	return nil
+}
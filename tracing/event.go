@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/TerrexTech/go-eventstore-models/model"
+	"github.com/TerrexTech/uuuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventContext returns a copy of ctx having the event as remote parent-span.
+// Events do not carry headers, so the trace-context is derived from the event:
+// the TraceID is the event's CorrelationID, and the parent SpanID is the first
+// 8 bytes of the event's UUID. Since KafkaResponses carry the same
+// CorrelationID, the requesting service can continue the same trace.
+// If the event has no CorrelationID, ctx is returned as is.
+func EventContext(ctx context.Context, event *model.Event) context.Context {
+	if event.CorrelationID == (uuuid.UUID{}) {
+		return ctx
+	}
+
+	var spanID trace.SpanID
+	copy(spanID[:], event.UUID.Bytes())
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID(event.CorrelationID.UUID),
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	if !spanCtx.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, spanCtx)
+}
+
+// EventAttributes returns the span-attributes identifying the event.
+func EventAttributes(event *model.Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("event.uuid", event.UUID.String()),
+		attribute.String("event.correlation_id", event.CorrelationID.String()),
+		attribute.String("event.service_action", event.ServiceAction),
+		attribute.String("event.action", event.EventAction),
+	}
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/TerrexTech/go-eventstore-models/model"
+	"github.com/TerrexTech/uuuid"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Event context", func() {
+	var event *model.Event
+
+	BeforeEach(func() {
+		eventID, err := uuuid.NewV4()
+		Expect(err).ToNot(HaveOccurred())
+		correlationID, err := uuuid.NewV4()
+		Expect(err).ToNot(HaveOccurred())
+		event = &model.Event{
+			UUID:          eventID,
+			CorrelationID: correlationID,
+			ServiceAction: "ItemWasteReport",
+		}
+	})
+
+	It("uses the event's CorrelationID as TraceID of spans", func() {
+		ctx := EventContext(context.Background(), event)
+
+		parent := trace.SpanContextFromContext(ctx)
+		Expect(parent.IsRemote()).To(BeTrue())
+		Expect(parent.TraceID()).To(Equal(trace.TraceID(event.CorrelationID.UUID)))
+		// The parent SpanID is the first 8 bytes of the event's UUID
+		var spanID trace.SpanID
+		copy(spanID[:], event.UUID.Bytes())
+		Expect(parent.SpanID()).To(Equal(spanID))
+
+		provider := sdktrace.NewTracerProvider()
+		defer provider.Shutdown(context.Background())
+		_, span := provider.Tracer("test").Start(ctx, "query")
+		defer span.End()
+		Expect(span.SpanContext().TraceID()).To(Equal(trace.TraceID(event.CorrelationID.UUID)))
+		Expect(span.SpanContext().SpanID()).ToNot(Equal(parent.SpanID()))
+	})
+
+	It("returns the context as is without a CorrelationID", func() {
+		event.CorrelationID = uuuid.UUID{}
+		ctx := context.Background()
+		Expect(EventContext(ctx, event)).To(Equal(ctx))
+	})
+
+	It("identifies the event in the span-attributes", func() {
+		attrs := map[string]string{}
+		for _, kv := range EventAttributes(event) {
+			attrs[string(kv.Key)] = kv.Value.AsString()
+		}
+		Expect(attrs).To(HaveKeyWithValue("event.uuid", event.UUID.String()))
+		Expect(attrs).To(HaveKeyWithValue("event.correlation_id", event.CorrelationID.String()))
+		Expect(attrs).To(HaveKeyWithValue("event.service_action", "ItemWasteReport"))
+	})
+})
@@ -0,0 +1,133 @@
+// Package tracing sets up OpenTelemetry tracing for the service.
+// Spans are exported using the exporter chosen in Config, and exporters
+// other than the built-in ones can be added using RegisterExporter.
+package tracing
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/TerrexTech/agg-itemwaste-report"
+
+// Config is the configuration for Init.
+type Config struct {
+	// ServiceName is set as the "service.name" of exported spans.
+	ServiceName string
+	// Exporter is the name of a registered exporter, such as "stdout" or "file".
+	// Tracing is disabled if this is blank.
+	Exporter string
+	// FilePath is the file spans are appended to when using the "file" exporter.
+	FilePath string
+}
+
+// ExporterFactory creates a SpanExporter from Config.
+type ExporterFactory func(cfg Config) (sdktrace.SpanExporter, error)
+
+var (
+	exportersLock sync.RWMutex
+	exporters     = map[string]ExporterFactory{
+		"stdout": newStdoutExporter,
+		"file":   newFileExporter,
+	}
+)
+
+// RegisterExporter makes an exporter available to Init by the provided name.
+// Registering an existing name replaces the previous exporter.
+func RegisterExporter(name string, factory ExporterFactory) {
+	exportersLock.Lock()
+	defer exportersLock.Unlock()
+	exporters[name] = factory
+}
+
+// Init sets up the global TracerProvider with the configured exporter.
+// The returned function flushes any pending spans and stops the exporter.
+// If no exporter is configured, spans are not recorded.
+func Init(cfg Config) (func(context.Context) error, error) {
+	if cfg.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exportersLock.RLock()
+	factory, exists := exporters[cfg.Exporter]
+	exportersLock.RUnlock()
+	if !exists {
+		return nil, errors.Errorf("Unknown trace-exporter: %s", cfg.Exporter)
+	}
+
+	exporter, err := factory(cfg)
+	if err != nil {
+		err = errors.Wrapf(err, "Error creating trace-exporter: %s", cfg.Exporter)
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", cfg.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the Tracer used for the service's spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// RecordError marks the span as failed with the provided error.
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func newStdoutExporter(cfg Config) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithPrettyPrint())
+}
+
+// fileExporter closes the file once the exporter is shutdown.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	closeErr := e.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func newFileExporter(cfg Config) (sdktrace.SpanExporter, error) {
+	if cfg.FilePath == "" {
+		return nil, errors.New("FilePath is required for file trace-exporter")
+	}
+	file, err := os.OpenFile(cfg.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		err = errors.Wrap(err, "Error opening trace-file")
+		return nil, err
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileExporter{
+		SpanExporter: exporter,
+		file:         file,
+	}, nil
+}
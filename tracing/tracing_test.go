@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracing", func() {
+	It("is disabled without an exporter", func() {
+		shutdown, err := Init(Config{ServiceName: "test-service"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shutdown(context.Background())).To(Succeed())
+	})
+
+	It("returns error on unknown exporters", func() {
+		_, err := Init(Config{ServiceName: "test-service", Exporter: "jaeger"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unknown trace-exporter: jaeger"))
+	})
+
+	It("exports to stdout", func() {
+		shutdown, err := Init(Config{ServiceName: "test-service", Exporter: "stdout"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shutdown(context.Background())).To(Succeed())
+	})
+
+	It("requires the FilePath for the file exporter", func() {
+		_, err := Init(Config{ServiceName: "test-service", Exporter: "file"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("FilePath"))
+	})
+
+	It("appends spans to the file", func() {
+		file, err := ioutil.TempFile("", "traces")
+		Expect(err).ToNot(HaveOccurred())
+		file.Close()
+		defer os.Remove(file.Name())
+
+		for _, name := range []string{"first-span", "second-span"} {
+			shutdown, err := Init(Config{
+				ServiceName: "test-service",
+				Exporter:    "file",
+				FilePath:    file.Name(),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			_, span := Tracer().Start(context.Background(), name)
+			span.End()
+			Expect(shutdown(context.Background())).To(Succeed())
+		}
+
+		traces, err := ioutil.ReadFile(file.Name())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(traces)).To(ContainSubstring("first-span"))
+		Expect(string(traces)).To(ContainSubstring("second-span"))
+		Expect(string(traces)).To(ContainSubstring("test-service"))
+	})
+
+	It("uses registered exporters", func() {
+		created := false
+		RegisterExporter("test", func(cfg Config) (sdktrace.SpanExporter, error) {
+			created = true
+			return newStdoutExporter(cfg)
+		})
+
+		shutdown, err := Init(Config{ServiceName: "test-service", Exporter: "test"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(BeTrue())
+		Expect(shutdown(context.Background())).To(Succeed())
+	})
+})
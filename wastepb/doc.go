@@ -0,0 +1,5 @@
+// Package wastepb contains the protobuf messages and gRPC service definition
+// for waste-reports.
+package wastepb
+
+//go:generate protoc --go_out=plugins=grpc:. itemwaste.proto
@@ -0,0 +1,743 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: itemwaste.proto
+
+package wastepb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// Comparator is a range-filter on a numeric field.
+// Unlike the JSON query-params, equality-matches are not supported.
+type Comparator struct {
+	Lt                   float64  `protobuf:"fixed64,1,opt,name=lt,proto3" json:"lt,omitempty"`
+	Gt                   float64  `protobuf:"fixed64,2,opt,name=gt,proto3" json:"gt,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Comparator) Reset()         { *m = Comparator{} }
+func (m *Comparator) String() string { return proto.CompactTextString(m) }
+func (*Comparator) ProtoMessage()    {}
+func (*Comparator) Descriptor() ([]byte, []int) {
+	return fileDescriptor_itemwaste_a06c9facfe62f069, []int{0}
+}
+func (m *Comparator) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Comparator.Unmarshal(m, b)
+}
+func (m *Comparator) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Comparator.Marshal(b, m, deterministic)
+}
+func (dst *Comparator) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Comparator.Merge(dst, src)
+}
+func (m *Comparator) XXX_Size() int {
+	return xxx_messageInfo_Comparator.Size(m)
+}
+func (m *Comparator) XXX_DiscardUnknown() {
+	xxx_messageInfo_Comparator.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Comparator proto.InternalMessageInfo
+
+func (m *Comparator) GetLt() float64 {
+	if m != nil {
+		return m.Lt
+	}
+	return 0
+}
+
+func (m *Comparator) GetGt() float64 {
+	if m != nil {
+		return m.Gt
+	}
+	return 0
+}
+
+// WasteItemParams are the filters for generating a report.
+type WasteItemParams struct {
+	Timestamp *Comparator `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// store_ids limits the report to the listed stores, all stores
+	// are included if empty.
+	StoreIds []string `protobuf:"bytes,2,rep,name=store_ids,json=storeIds,proto3" json:"store_ids,omitempty"`
+	// group_by_store gives each SKU a result per store.
+	GroupByStore bool `protobuf:"varint,3,opt,name=group_by_store,json=groupByStore,proto3" json:"group_by_store,omitempty"`
+	// reasons and disposals limit the report to the listed waste-reasons
+	// and disposal-methods, all are included if empty.
+	Reasons         []string `protobuf:"bytes,4,rep,name=reasons,proto3" json:"reasons,omitempty"`
+	Disposals       []string `protobuf:"bytes,5,rep,name=disposals,proto3" json:"disposals,omitempty"`
+	GroupByReason   bool     `protobuf:"varint,6,opt,name=group_by_reason,json=groupByReason,proto3" json:"group_by_reason,omitempty"`
+	GroupByDisposal bool     `protobuf:"varint,7,opt,name=group_by_disposal,json=groupByDisposal,proto3" json:"group_by_disposal,omitempty"`
+	// skus limits the report to the listed SKUs, all SKUs are included if empty.
+	Skus []string `protobuf:"bytes,8,rep,name=skus,proto3" json:"skus,omitempty"`
+	// category drills-down the report to the SKUs within it in the
+	// product-catalogue.
+	Category *CategoryPath `protobuf:"bytes,9,opt,name=category,proto3" json:"category,omitempty"`
+	// roll_up is the hierarchy-level the results are summed at, one of
+	// "department", "category", "subcategory" or "sku", followed by
+	// subtotals for each broader level.
+	RollUp               string   `protobuf:"bytes,10,opt,name=roll_up,json=rollUp,proto3" json:"roll_up,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WasteItemParams) Reset()         { *m = WasteItemParams{} }
+func (m *WasteItemParams) String() string { return proto.CompactTextString(m) }
+func (*WasteItemParams) ProtoMessage()    {}
+func (*WasteItemParams) Descriptor() ([]byte, []int) {
+	return fileDescriptor_itemwaste_a06c9facfe62f069, []int{1}
+}
+func (m *WasteItemParams) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WasteItemParams.Unmarshal(m, b)
+}
+func (m *WasteItemParams) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WasteItemParams.Marshal(b, m, deterministic)
+}
+func (dst *WasteItemParams) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WasteItemParams.Merge(dst, src)
+}
+func (m *WasteItemParams) XXX_Size() int {
+	return xxx_messageInfo_WasteItemParams.Size(m)
+}
+func (m *WasteItemParams) XXX_DiscardUnknown() {
+	xxx_messageInfo_WasteItemParams.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WasteItemParams proto.InternalMessageInfo
+
+func (m *WasteItemParams) GetTimestamp() *Comparator {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+func (m *WasteItemParams) GetStoreIds() []string {
+	if m != nil {
+		return m.StoreIds
+	}
+	return nil
+}
+
+func (m *WasteItemParams) GetGroupByStore() bool {
+	if m != nil {
+		return m.GroupByStore
+	}
+	return false
+}
+
+func (m *WasteItemParams) GetReasons() []string {
+	if m != nil {
+		return m.Reasons
+	}
+	return nil
+}
+
+func (m *WasteItemParams) GetDisposals() []string {
+	if m != nil {
+		return m.Disposals
+	}
+	return nil
+}
+
+func (m *WasteItemParams) GetGroupByReason() bool {
+	if m != nil {
+		return m.GroupByReason
+	}
+	return false
+}
+
+func (m *WasteItemParams) GetGroupByDisposal() bool {
+	if m != nil {
+		return m.GroupByDisposal
+	}
+	return false
+}
+
+func (m *WasteItemParams) GetSkus() []string {
+	if m != nil {
+		return m.Skus
+	}
+	return nil
+}
+
+func (m *WasteItemParams) GetCategory() *CategoryPath {
+	if m != nil {
+		return m.Category
+	}
+	return nil
+}
+
+func (m *WasteItemParams) GetRollUp() string {
+	if m != nil {
+		return m.RollUp
+	}
+	return ""
+}
+
+// CategoryPath is a position in the product-category hierarchy.
+type CategoryPath struct {
+	Department           string   `protobuf:"bytes,1,opt,name=department,proto3" json:"department,omitempty"`
+	Category             string   `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	Subcategory          string   `protobuf:"bytes,3,opt,name=subcategory,proto3" json:"subcategory,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CategoryPath) Reset()         { *m = CategoryPath{} }
+func (m *CategoryPath) String() string { return proto.CompactTextString(m) }
+func (*CategoryPath) ProtoMessage()    {}
+func (*CategoryPath) Descriptor() ([]byte, []int) {
+	return fileDescriptor_itemwaste_a06c9facfe62f069, []int{2}
+}
+func (m *CategoryPath) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CategoryPath.Unmarshal(m, b)
+}
+func (m *CategoryPath) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CategoryPath.Marshal(b, m, deterministic)
+}
+func (dst *CategoryPath) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CategoryPath.Merge(dst, src)
+}
+func (m *CategoryPath) XXX_Size() int {
+	return xxx_messageInfo_CategoryPath.Size(m)
+}
+func (m *CategoryPath) XXX_DiscardUnknown() {
+	xxx_messageInfo_CategoryPath.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CategoryPath proto.InternalMessageInfo
+
+func (m *CategoryPath) GetDepartment() string {
+	if m != nil {
+		return m.Department
+	}
+	return ""
+}
+
+func (m *CategoryPath) GetCategory() string {
+	if m != nil {
+		return m.Category
+	}
+	return ""
+}
+
+func (m *CategoryPath) GetSubcategory() string {
+	if m != nil {
+		return m.Subcategory
+	}
+	return ""
+}
+
+// ReportResult is a single row of a report.
+type ReportResult struct {
+	Sku         string  `protobuf:"bytes,1,opt,name=sku,proto3" json:"sku,omitempty"`
+	Name        string  `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	WasteWeight float64 `protobuf:"fixed64,3,opt,name=waste_weight,json=wasteWeight,proto3" json:"waste_weight,omitempty"`
+	TotalWeight float64 `protobuf:"fixed64,4,opt,name=total_weight,json=totalWeight,proto3" json:"total_weight,omitempty"`
+	// store_id is only set if the report is grouped by store.
+	StoreId string `protobuf:"bytes,5,opt,name=store_id,json=storeId,proto3" json:"store_id,omitempty"`
+	// The cost-fields are only set if the unit-cost of the SKU is known.
+	UnitCost   float64 `protobuf:"fixed64,6,opt,name=unit_cost,json=unitCost,proto3" json:"unit_cost,omitempty"`
+	WasteValue float64 `protobuf:"fixed64,7,opt,name=waste_value,json=wasteValue,proto3" json:"waste_value,omitempty"`
+	TotalValue float64 `protobuf:"fixed64,8,opt,name=total_value,json=totalValue,proto3" json:"total_value,omitempty"`
+	Currency   string  `protobuf:"bytes,9,opt,name=currency,proto3" json:"currency,omitempty"`
+	// unit is the weight-unit of the weights, always "kg".
+	Unit string `protobuf:"bytes,10,opt,name=unit,proto3" json:"unit,omitempty"`
+	// reason and disposal are only set if the report is grouped by them.
+	Reason   string `protobuf:"bytes,11,opt,name=reason,proto3" json:"reason,omitempty"`
+	Disposal string `protobuf:"bytes,12,opt,name=disposal,proto3" json:"disposal,omitempty"`
+	// department, category and subcategory are only set if the report
+	// is rolled-up.
+	Department  string `protobuf:"bytes,13,opt,name=department,proto3" json:"department,omitempty"`
+	Category    string `protobuf:"bytes,14,opt,name=category,proto3" json:"category,omitempty"`
+	Subcategory string `protobuf:"bytes,15,opt,name=subcategory,proto3" json:"subcategory,omitempty"`
+	// subtotal marks the sum of the preceding results in its hierarchy-level.
+	Subtotal             bool     `protobuf:"varint,16,opt,name=subtotal,proto3" json:"subtotal,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReportResult) Reset()         { *m = ReportResult{} }
+func (m *ReportResult) String() string { return proto.CompactTextString(m) }
+func (*ReportResult) ProtoMessage()    {}
+func (*ReportResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_itemwaste_a06c9facfe62f069, []int{3}
+}
+func (m *ReportResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReportResult.Unmarshal(m, b)
+}
+func (m *ReportResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReportResult.Marshal(b, m, deterministic)
+}
+func (dst *ReportResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReportResult.Merge(dst, src)
+}
+func (m *ReportResult) XXX_Size() int {
+	return xxx_messageInfo_ReportResult.Size(m)
+}
+func (m *ReportResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReportResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReportResult proto.InternalMessageInfo
+
+func (m *ReportResult) GetSku() string {
+	if m != nil {
+		return m.Sku
+	}
+	return ""
+}
+
+func (m *ReportResult) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ReportResult) GetWasteWeight() float64 {
+	if m != nil {
+		return m.WasteWeight
+	}
+	return 0
+}
+
+func (m *ReportResult) GetTotalWeight() float64 {
+	if m != nil {
+		return m.TotalWeight
+	}
+	return 0
+}
+
+func (m *ReportResult) GetStoreId() string {
+	if m != nil {
+		return m.StoreId
+	}
+	return ""
+}
+
+func (m *ReportResult) GetUnitCost() float64 {
+	if m != nil {
+		return m.UnitCost
+	}
+	return 0
+}
+
+func (m *ReportResult) GetWasteValue() float64 {
+	if m != nil {
+		return m.WasteValue
+	}
+	return 0
+}
+
+func (m *ReportResult) GetTotalValue() float64 {
+	if m != nil {
+		return m.TotalValue
+	}
+	return 0
+}
+
+func (m *ReportResult) GetCurrency() string {
+	if m != nil {
+		return m.Currency
+	}
+	return ""
+}
+
+func (m *ReportResult) GetUnit() string {
+	if m != nil {
+		return m.Unit
+	}
+	return ""
+}
+
+func (m *ReportResult) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *ReportResult) GetDisposal() string {
+	if m != nil {
+		return m.Disposal
+	}
+	return ""
+}
+
+func (m *ReportResult) GetDepartment() string {
+	if m != nil {
+		return m.Department
+	}
+	return ""
+}
+
+func (m *ReportResult) GetCategory() string {
+	if m != nil {
+		return m.Category
+	}
+	return ""
+}
+
+func (m *ReportResult) GetSubcategory() string {
+	if m != nil {
+		return m.Subcategory
+	}
+	return ""
+}
+
+func (m *ReportResult) GetSubtotal() bool {
+	if m != nil {
+		return m.Subtotal
+	}
+	return false
+}
+
+// WasteReport is a generated report, as stored by the service.
+type WasteReport struct {
+	ReportId             string           `protobuf:"bytes,1,opt,name=report_id,json=reportId,proto3" json:"report_id,omitempty"`
+	SearchQuery          *WasteItemParams `protobuf:"bytes,2,opt,name=search_query,json=searchQuery,proto3" json:"search_query,omitempty"`
+	ReportResult         []*ReportResult  `protobuf:"bytes,3,rep,name=report_result,json=reportResult,proto3" json:"report_result,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *WasteReport) Reset()         { *m = WasteReport{} }
+func (m *WasteReport) String() string { return proto.CompactTextString(m) }
+func (*WasteReport) ProtoMessage()    {}
+func (*WasteReport) Descriptor() ([]byte, []int) {
+	return fileDescriptor_itemwaste_a06c9facfe62f069, []int{4}
+}
+func (m *WasteReport) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WasteReport.Unmarshal(m, b)
+}
+func (m *WasteReport) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WasteReport.Marshal(b, m, deterministic)
+}
+func (dst *WasteReport) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WasteReport.Merge(dst, src)
+}
+func (m *WasteReport) XXX_Size() int {
+	return xxx_messageInfo_WasteReport.Size(m)
+}
+func (m *WasteReport) XXX_DiscardUnknown() {
+	xxx_messageInfo_WasteReport.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WasteReport proto.InternalMessageInfo
+
+func (m *WasteReport) GetReportId() string {
+	if m != nil {
+		return m.ReportId
+	}
+	return ""
+}
+
+func (m *WasteReport) GetSearchQuery() *WasteItemParams {
+	if m != nil {
+		return m.SearchQuery
+	}
+	return nil
+}
+
+func (m *WasteReport) GetReportResult() []*ReportResult {
+	if m != nil {
+		return m.ReportResult
+	}
+	return nil
+}
+
+type GetReportRequest struct {
+	ReportId             string   `protobuf:"bytes,1,opt,name=report_id,json=reportId,proto3" json:"report_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetReportRequest) Reset()         { *m = GetReportRequest{} }
+func (m *GetReportRequest) String() string { return proto.CompactTextString(m) }
+func (*GetReportRequest) ProtoMessage()    {}
+func (*GetReportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_itemwaste_a06c9facfe62f069, []int{5}
+}
+func (m *GetReportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetReportRequest.Unmarshal(m, b)
+}
+func (m *GetReportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetReportRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetReportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetReportRequest.Merge(dst, src)
+}
+func (m *GetReportRequest) XXX_Size() int {
+	return xxx_messageInfo_GetReportRequest.Size(m)
+}
+func (m *GetReportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetReportRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetReportRequest proto.InternalMessageInfo
+
+func (m *GetReportRequest) GetReportId() string {
+	if m != nil {
+		return m.ReportId
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Comparator)(nil), "itemwaste.Comparator")
+	proto.RegisterType((*WasteItemParams)(nil), "itemwaste.WasteItemParams")
+	proto.RegisterType((*CategoryPath)(nil), "itemwaste.CategoryPath")
+	proto.RegisterType((*ReportResult)(nil), "itemwaste.ReportResult")
+	proto.RegisterType((*WasteReport)(nil), "itemwaste.WasteReport")
+	proto.RegisterType((*GetReportRequest)(nil), "itemwaste.GetReportRequest")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ItemWasteReportClient is the client API for ItemWasteReport service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ItemWasteReportClient interface {
+	// GenerateReport runs and stores a new report, and returns it whole.
+	GenerateReport(ctx context.Context, in *WasteItemParams, opts ...grpc.CallOption) (*WasteReport, error)
+	// StreamReport runs a new report, and streams its results one row at a
+	// time as they are read from the aggregation. The reportID is sent in the
+	// "report-id" header, and the report is stored once all rows are sent.
+	// Rolled-up reports are built in full first, for their subtotals.
+	StreamReport(ctx context.Context, in *WasteItemParams, opts ...grpc.CallOption) (ItemWasteReport_StreamReportClient, error)
+	// GetReport fetches a stored report.
+	GetReport(ctx context.Context, in *GetReportRequest, opts ...grpc.CallOption) (*WasteReport, error)
+}
+
+type itemWasteReportClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewItemWasteReportClient(cc *grpc.ClientConn) ItemWasteReportClient {
+	return &itemWasteReportClient{cc}
+}
+
+func (c *itemWasteReportClient) GenerateReport(ctx context.Context, in *WasteItemParams, opts ...grpc.CallOption) (*WasteReport, error) {
+	out := new(WasteReport)
+	err := c.cc.Invoke(ctx, "/itemwaste.ItemWasteReport/GenerateReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *itemWasteReportClient) StreamReport(ctx context.Context, in *WasteItemParams, opts ...grpc.CallOption) (ItemWasteReport_StreamReportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_ItemWasteReport_serviceDesc.Streams[0], "/itemwaste.ItemWasteReport/StreamReport", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &itemWasteReportStreamReportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ItemWasteReport_StreamReportClient interface {
+	Recv() (*ReportResult, error)
+	grpc.ClientStream
+}
+
+type itemWasteReportStreamReportClient struct {
+	grpc.ClientStream
+}
+
+func (x *itemWasteReportStreamReportClient) Recv() (*ReportResult, error) {
+	m := new(ReportResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *itemWasteReportClient) GetReport(ctx context.Context, in *GetReportRequest, opts ...grpc.CallOption) (*WasteReport, error) {
+	out := new(WasteReport)
+	err := c.cc.Invoke(ctx, "/itemwaste.ItemWasteReport/GetReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ItemWasteReportServer is the server API for ItemWasteReport service.
+type ItemWasteReportServer interface {
+	// GenerateReport runs and stores a new report, and returns it whole.
+	GenerateReport(context.Context, *WasteItemParams) (*WasteReport, error)
+	// StreamReport runs a new report, and streams its results one row at a
+	// time as they are read from the aggregation. The reportID is sent in the
+	// "report-id" header, and the report is stored once all rows are sent.
+	// Rolled-up reports are built in full first, for their subtotals.
+	StreamReport(*WasteItemParams, ItemWasteReport_StreamReportServer) error
+	// GetReport fetches a stored report.
+	GetReport(context.Context, *GetReportRequest) (*WasteReport, error)
+}
+
+func RegisterItemWasteReportServer(s *grpc.Server, srv ItemWasteReportServer) {
+	s.RegisterService(&_ItemWasteReport_serviceDesc, srv)
+}
+
+func _ItemWasteReport_GenerateReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WasteItemParams)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemWasteReportServer).GenerateReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/itemwaste.ItemWasteReport/GenerateReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemWasteReportServer).GenerateReport(ctx, req.(*WasteItemParams))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ItemWasteReport_StreamReport_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WasteItemParams)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ItemWasteReportServer).StreamReport(m, &itemWasteReportStreamReportServer{stream})
+}
+
+type ItemWasteReport_StreamReportServer interface {
+	Send(*ReportResult) error
+	grpc.ServerStream
+}
+
+type itemWasteReportStreamReportServer struct {
+	grpc.ServerStream
+}
+
+func (x *itemWasteReportStreamReportServer) Send(m *ReportResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ItemWasteReport_GetReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemWasteReportServer).GetReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/itemwaste.ItemWasteReport/GetReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemWasteReportServer).GetReport(ctx, req.(*GetReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ItemWasteReport_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "itemwaste.ItemWasteReport",
+	HandlerType: (*ItemWasteReportServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GenerateReport",
+			Handler:    _ItemWasteReport_GenerateReport_Handler,
+		},
+		{
+			MethodName: "GetReport",
+			Handler:    _ItemWasteReport_GetReport_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReport",
+			Handler:       _ItemWasteReport_StreamReport_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "itemwaste.proto",
+}
+
+func init() { proto.RegisterFile("itemwaste.proto", fileDescriptor_itemwaste_a06c9facfe62f069) }
+
+var fileDescriptor_itemwaste_a06c9facfe62f069 = []byte{
+	// 665 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xd1, 0x6e, 0xd3, 0x3c,
+	0x14, 0x56, 0x9a, 0xad, 0x4d, 0x4e, 0xb2, 0x76, 0xbf, 0xa5, 0x7f, 0x33, 0x1b, 0x82, 0x52, 0x21,
+	0x54, 0x21, 0x34, 0xd0, 0x76, 0x0b, 0x12, 0xda, 0x86, 0xa6, 0xdd, 0x0d, 0x4f, 0x30, 0x89, 0x9b,
+	0xc8, 0x6d, 0xad, 0xae, 0x5a, 0x52, 0x67, 0xb6, 0xc3, 0xd4, 0x27, 0xe2, 0x7d, 0x78, 0x02, 0xde,
+	0x82, 0x5b, 0xe4, 0x63, 0x37, 0x8d, 0x8a, 0x56, 0x71, 0xe7, 0xf3, 0x9d, 0xef, 0x7c, 0xc7, 0xc7,
+	0xdf, 0x49, 0xa0, 0x37, 0x33, 0xa2, 0x78, 0xe0, 0xda, 0x88, 0xa3, 0x52, 0x49, 0x23, 0x49, 0x5c,
+	0x03, 0x83, 0x37, 0x00, 0x67, 0xb2, 0x28, 0xb9, 0xe2, 0x46, 0x2a, 0xd2, 0x85, 0x56, 0x6e, 0x68,
+	0xd0, 0x0f, 0x86, 0x01, 0x6b, 0xe5, 0xc6, 0xc6, 0x53, 0x43, 0x5b, 0x2e, 0x9e, 0x9a, 0xc1, 0xef,
+	0x16, 0xf4, 0x6e, 0x6c, 0xdd, 0xa5, 0x11, 0xc5, 0x15, 0x57, 0xbc, 0xd0, 0xe4, 0x04, 0x62, 0x33,
+	0x2b, 0x84, 0x36, 0xbc, 0x28, 0xb1, 0x34, 0x39, 0xfe, 0xff, 0x68, 0xd5, 0x71, 0xa5, 0xce, 0x56,
+	0x3c, 0x72, 0x08, 0xb1, 0x36, 0x52, 0x89, 0x6c, 0x36, 0xd1, 0xb4, 0xd5, 0x0f, 0x87, 0x31, 0x8b,
+	0x10, 0xb8, 0x9c, 0x68, 0xf2, 0x12, 0xba, 0x53, 0x25, 0xab, 0x32, 0x1b, 0x2d, 0x32, 0x04, 0x69,
+	0xd8, 0x0f, 0x86, 0x11, 0x4b, 0x11, 0x3d, 0x5d, 0x5c, 0x5b, 0x8c, 0x50, 0xe8, 0x28, 0xc1, 0xb5,
+	0x9c, 0x6b, 0xba, 0x85, 0x02, 0xcb, 0x90, 0x3c, 0x85, 0x78, 0x32, 0xd3, 0xa5, 0xd4, 0x3c, 0xd7,
+	0x74, 0x1b, 0x73, 0x2b, 0x80, 0xbc, 0x82, 0x5e, 0xad, 0xee, 0x2a, 0x68, 0x1b, 0xe5, 0x77, 0xbc,
+	0x3c, 0x43, 0x90, 0xbc, 0x86, 0xff, 0x6a, 0xde, 0xb2, 0x9a, 0x76, 0x90, 0xd9, 0xf3, 0xcc, 0x73,
+	0x0f, 0x13, 0x02, 0x5b, 0xfa, 0xae, 0xd2, 0x34, 0xc2, 0x66, 0x78, 0x26, 0x27, 0x10, 0x8d, 0xb9,
+	0x11, 0x53, 0xa9, 0x16, 0x34, 0xc6, 0x67, 0xd9, 0x6f, 0x3e, 0x8b, 0x4f, 0x5d, 0x71, 0x73, 0xcb,
+	0x6a, 0x22, 0xd9, 0x87, 0x8e, 0x92, 0x79, 0x9e, 0x55, 0x25, 0x85, 0x7e, 0x30, 0x8c, 0x59, 0xdb,
+	0x86, 0x5f, 0xca, 0x41, 0x0e, 0x69, 0xb3, 0x84, 0x3c, 0x03, 0x98, 0x88, 0x92, 0x2b, 0x53, 0x88,
+	0xb9, 0x73, 0x2c, 0x66, 0x0d, 0x84, 0x1c, 0x34, 0xba, 0xb7, 0x30, 0xbb, 0x6a, 0xd2, 0x87, 0x44,
+	0x57, 0xa3, 0x3a, 0x1d, 0x62, 0xba, 0x09, 0x0d, 0x7e, 0x86, 0x90, 0x32, 0x51, 0x4a, 0x65, 0x98,
+	0xd0, 0x55, 0x6e, 0xc8, 0x2e, 0x84, 0xfa, 0xae, 0xf2, 0x7d, 0xec, 0xd1, 0x8e, 0x3c, 0xe7, 0x85,
+	0xf0, 0xe2, 0x78, 0x26, 0x2f, 0x20, 0xc5, 0xe9, 0xb2, 0x07, 0x31, 0x9b, 0xde, 0x1a, 0x54, 0x0e,
+	0x58, 0x82, 0xd8, 0x0d, 0x42, 0x96, 0x62, 0xa4, 0xe1, 0xf9, 0x92, 0xb2, 0xe5, 0x28, 0x88, 0x79,
+	0xca, 0x13, 0x88, 0x96, 0xbb, 0x41, 0xb7, 0x51, 0xbd, 0xe3, 0x57, 0xc3, 0xae, 0x4d, 0x35, 0x9f,
+	0x99, 0x6c, 0x2c, 0xb5, 0x41, 0xd7, 0x02, 0x16, 0x59, 0xe0, 0x4c, 0x6a, 0x43, 0x9e, 0x83, 0xeb,
+	0x94, 0x7d, 0xe7, 0x79, 0x25, 0xd0, 0xaa, 0x80, 0x01, 0x42, 0x5f, 0x2d, 0x62, 0x09, 0xae, 0xb7,
+	0x23, 0x44, 0x8e, 0x80, 0x90, 0x23, 0xd8, 0x47, 0xab, 0x94, 0x12, 0xf3, 0xb1, 0xb3, 0x2c, 0x66,
+	0x75, 0x6c, 0xe7, 0xb5, 0x9d, 0xbc, 0x2d, 0x78, 0x26, 0x7b, 0xd0, 0xf6, 0x1b, 0x94, 0x78, 0xb3,
+	0x30, 0xb2, 0x3a, 0xf5, 0xc6, 0xa4, 0x4e, 0x67, 0x19, 0xaf, 0x19, 0xb7, 0xb3, 0xd1, 0xb8, 0xee,
+	0x66, 0xe3, 0x7a, 0x7f, 0x19, 0x67, 0xab, 0x75, 0x35, 0xc2, 0x91, 0xe8, 0x2e, 0xee, 0x6a, 0x1d,
+	0x0f, 0x7e, 0x04, 0x90, 0xe0, 0xc7, 0xeb, 0x9c, 0xb5, 0x8f, 0xa9, 0xf0, 0x64, 0x1f, 0xda, 0x39,
+	0x1b, 0x39, 0xe0, 0x72, 0x42, 0x3e, 0x40, 0xaa, 0x05, 0x57, 0xe3, 0xdb, 0xec, 0xbe, 0x12, 0x7e,
+	0x87, 0x92, 0xe3, 0x83, 0xc6, 0x06, 0xaf, 0xfd, 0x07, 0x58, 0xe2, 0xf8, 0x9f, 0x2d, 0x9d, 0xbc,
+	0x87, 0x1d, 0xaf, 0xad, 0x70, 0x81, 0x68, 0xd8, 0x0f, 0xd7, 0xbe, 0x80, 0xe6, 0x7e, 0xb1, 0x54,
+	0x35, 0xa2, 0xc1, 0x5b, 0xd8, 0xbd, 0x10, 0x66, 0x49, 0xb8, 0xaf, 0x84, 0xde, 0x7c, 0xdb, 0xe3,
+	0x5f, 0x01, 0xf4, 0xec, 0x55, 0x9a, 0xe3, 0x9d, 0x43, 0xf7, 0x42, 0xcc, 0x85, 0xe2, 0x35, 0xb2,
+	0xe1, 0xf6, 0x07, 0x7b, 0xeb, 0x39, 0x5f, 0xf3, 0x09, 0xd2, 0x6b, 0xa3, 0x04, 0x2f, 0xfe, 0x41,
+	0xe3, 0xb1, 0xe9, 0xde, 0x05, 0xe4, 0x23, 0xc4, 0xf5, 0x44, 0xe4, 0xb0, 0xc1, 0x5b, 0x9f, 0xf3,
+	0xb1, 0x8b, 0x9c, 0xc6, 0xdf, 0x3a, 0x08, 0x96, 0xa3, 0x51, 0x1b, 0xff, 0xe2, 0x27, 0x7f, 0x06,
+	0x00, 0x12, 0x9a, 0x6c, 0x3a, 0xd8, 0x05, 0x00, 0x00,
+}